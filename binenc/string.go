@@ -69,6 +69,13 @@ func CString16(data []byte) string {
 func CStringSet16(data []byte, s string) int {
 	zeros(data)
 	data16 := utf16.Encode([]rune(s))
+	if n := len(data) / 2; len(data16) > n {
+		if n > 0 && data16[n-1] >= 0xd800 && data16[n-1] < 0xdc00 {
+			// do not split surrogate pairs
+			n--
+		}
+		data16 = data16[:n]
+	}
 	for i, v := range data16 {
 		binary.LittleEndian.PutUint16(data[2*i:], v)
 	}
@@ -88,7 +95,7 @@ type String struct {
 
 func (s *String) Encode(data []byte) {
 	i := CStringSet(data, s.Value)
-	if len(s.Junk) != 0 {
+	if len(s.Junk) != 0 && i < len(data) {
 		copy(data[i+1:], s.Junk)
 	}
 }
@@ -96,7 +103,7 @@ func (s *String) Encode(data []byte) {
 func (s *String) Decode(data []byte) {
 	s.Value = CString(data)
 	s.Junk = nil
-	if i := len(s.Value); !allZeros(data[i+1:]) {
+	if i := len(s.Value); i < len(data) && !allZeros(data[i+1:]) {
 		s.Junk = make([]byte, len(data)-i-1)
 		copy(s.Junk, data[i+1:])
 	}
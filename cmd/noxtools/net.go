@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/opennox/libs/noxnet/wireshark"
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "net command",
+		Short: "Tools for working with Nox network protocol",
+	}
+	Root.AddCommand(cmd)
+
+	cmdWireshark := &cobra.Command{
+		Use:     "wireshark [output]",
+		Short:   "Generates Wireshark Lua dissector for Nox network protocol",
+		Aliases: []string{"ws"},
+	}
+	cmd.AddCommand(cmdWireshark)
+	fPort := cmdWireshark.Flags().Int("port", 0, "default game port for the dissector")
+	cmdWireshark.RunE = func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("expected zero or one argument")
+		}
+		var buf bytes.Buffer
+		if err := wireshark.Generate(&buf, &wireshark.Options{Port: *fPort}); err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			_, err := os.Stdout.Write(buf.Bytes())
+			return err
+		}
+		return os.WriteFile(args[0], buf.Bytes(), 0644)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/opennox/libs/common"
+	"github.com/opennox/libs/noxnet"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+var (
+	fServer   = flag.String("server", "", "server address to test; if empty, an in-process server is used")
+	fClients  = flag.Int("clients", common.MaxPlayers, "number of simulated clients")
+	fRamp     = flag.Duration("ramp", 5*time.Second, "time to spread client connections over")
+	fDuration = flag.Duration("duration", 30*time.Second, "time to keep clients in game after the ramp")
+	fTimeout  = flag.Duration("timeout", 10*time.Second, "join timeout for each client")
+	fScript   = flag.String("script", "", "YAML file with inputs to replay; clients walk in circles by default")
+	fTick     = flag.Duration("tick", noxnet.DefaultBotTick, "interval between input packets")
+	fName     = flag.String("name", "Bot", "player name prefix")
+	fPass     = flag.String("pass", "", "server password")
+	fLoss     = flag.Float64("loss", 0, "packet loss probability for the in-process server, in [0, 1]")
+	fLatency  = flag.Duration("latency", 0, "one-way latency for the in-process server")
+	fMaxFail  = flag.Int("max-failed", 0, "exit with an error if more clients failed to join; negative disables the check")
+	fVerbose  = flag.Bool("v", false, "verbose logging")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	level := slog.LevelError
+	if *fVerbose {
+		level = slog.LevelDebug
+	}
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	script := defaultScript
+	if *fScript != "" {
+		var err error
+		script, err = readScript(*fScript)
+		if err != nil {
+			return err
+		}
+	}
+
+	var (
+		addr netip.AddrPort
+		dial func(i int) (udpconn.PacketConn, error)
+		srv  *pipeServer
+	)
+	if *fServer == "" {
+		srv = newPipeServer(log, udpconn.Impairment{
+			Seed:    1,
+			Loss:    *fLoss,
+			Latency: *fLatency,
+		})
+		defer srv.Close()
+		addr = srv.Addr()
+		dial = srv.Dial
+	} else {
+		var err error
+		addr, err = netip.ParseAddrPort(*fServer)
+		if err != nil {
+			return err
+		}
+		dial = dialUDP
+	}
+
+	r := &runner{
+		log:      log,
+		addr:     addr,
+		dial:     dial,
+		script:   script,
+		ramp:     *fRamp,
+		duration: *fDuration,
+		timeout:  *fTimeout,
+		tick:     *fTick,
+		name:     *fName,
+		pass:     *fPass,
+	}
+	rep := r.Run(ctx, *fClients)
+	if srv != nil {
+		rep.Server = srv.Stats()
+	}
+	rep.Print(os.Stdout)
+	if *fMaxFail >= 0 && rep.Failed() > *fMaxFail {
+		return fmt.Errorf("%d clients failed to join", rep.Failed())
+	}
+	return nil
+}
+
+func dialUDP(_ int) (udpconn.PacketConn, error) {
+	return net.ListenUDP("udp4", nil)
+}
+
+type runner struct {
+	log    *slog.Logger
+	addr   netip.AddrPort
+	dial   func(i int) (udpconn.PacketConn, error)
+	script Script
+
+	ramp     time.Duration // time to spread client connections over
+	duration time.Duration // time to keep clients in game after the ramp
+	timeout  time.Duration // join timeout for each client
+	tick     time.Duration // interval between input packets
+	name     string        // player name prefix
+	pass     string        // server password
+}
+
+// Run connects n clients, spreading connections over the ramp duration, and keeps them in game for the test duration.
+func (r *runner) Run(ctx context.Context, n int) *Report {
+	rep := &Report{Clients: make([]ClientReport, n)}
+	var step time.Duration
+	if n > 1 {
+		step = r.ramp / time.Duration(n-1)
+	}
+	start := time.Now()
+	end := start.Add(r.ramp + r.duration)
+	ctx, cancel := context.WithDeadline(ctx, end)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := range n {
+		if i != 0 && step > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Until(start.Add(time.Duration(i) * step))):
+			}
+		}
+		if ctx.Err() != nil {
+			rep.Clients[i].Err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.runClient(ctx, i, &rep.Clients[i])
+		}()
+	}
+	wg.Wait()
+	rep.Duration = time.Since(start)
+	return rep
+}
+
+func (r *runner) runClient(ctx context.Context, i int, rep *ClientReport) {
+	conn, err := r.dial(i)
+	if err != nil {
+		rep.Err = err
+		return
+	}
+	name := fmt.Sprintf("%s%d", r.name, i+1)
+	b := noxnet.NewBot(r.log.With("bot", name), conn, &noxnet.BotOptions{
+		Join: noxnet.MsgServerTryJoin{
+			PlayerName:  name,
+			PlayerClass: byte(i % 3),
+		},
+		Password: r.pass,
+		Accept: noxnet.MsgClientAccept{
+			PlayerInfo: noxnet.PlayerInfo{
+				PlayerName:  name,
+				PlayerClass: byte(i % 3),
+			},
+			Screen: image.Pt(1024, 768),
+		},
+		Tick: r.tick,
+	})
+	defer func() {
+		rep.Stats = b.Client().Port.Stats().Total
+		b.Close()
+		_ = conn.Close()
+	}()
+
+	jctx, cancel := context.WithTimeout(ctx, r.timeout)
+	start := time.Now()
+	err = b.Join(jctx, r.addr)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = errors.New("test ended before join")
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			err = errors.New("join timeout")
+		}
+		rep.Err = err
+		r.log.Error("join failed", "bot", name, "err", err)
+		return
+	}
+	rep.Joined = true
+	rep.JoinTime = time.Since(start)
+	r.script.Play(ctx, b, i, r.tick)
+}
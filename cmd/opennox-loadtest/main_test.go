@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+func TestRun(t *testing.T) {
+	srv := newPipeServer(slog.Default(), udpconn.Impairment{})
+	t.Cleanup(srv.Close)
+
+	r := &runner{
+		log:      slog.Default(),
+		addr:     srv.Addr(),
+		dial:     srv.Dial,
+		script:   defaultScript,
+		ramp:     100 * time.Millisecond,
+		duration: 200 * time.Millisecond,
+		timeout:  5 * time.Second,
+		tick:     20 * time.Millisecond,
+		name:     "Bot",
+	}
+	const n = 4
+	rep := r.Run(context.Background(), n)
+	rep.Server = srv.Stats()
+	for i, c := range rep.Clients {
+		must.NoError(t, c.Err, must.Sprintf("client %d", i))
+	}
+	must.EqOp(t, 0, rep.Failed())
+	must.EqOp(t, n, rep.Server.Joined)
+	must.Positive(t, rep.Server.Inputs)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+
+	"github.com/opennox/libs/binenc"
+	"github.com/opennox/libs/common"
+	"github.com/opennox/libs/noxnet"
+	"github.com/opennox/libs/noxnet/discover"
+	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+// pipeBuffer is the number of packets buffered in each direction of the pipe.
+const pipeBuffer = 64
+
+// pipeMux joins server ends of multiple pipes into a single packet connection.
+type pipeMux struct {
+	addr   netip.AddrPort
+	recv   chan pipePacket
+	closed chan struct{}
+
+	mu     sync.RWMutex
+	byAddr map[netip.AddrPort]*udpconn.PipeConn
+}
+
+type pipePacket struct {
+	data []byte
+	addr netip.AddrPort
+}
+
+func newPipeMux(addr netip.AddrPort) *pipeMux {
+	return &pipeMux{
+		addr:   addr,
+		recv:   make(chan pipePacket, pipeBuffer),
+		closed: make(chan struct{}),
+		byAddr: make(map[netip.AddrPort]*udpconn.PipeConn),
+	}
+}
+
+// Add server end of the pipe, connected to a client with a given address.
+func (m *pipeMux) Add(srv *udpconn.PipeConn, addr netip.AddrPort) {
+	m.mu.Lock()
+	m.byAddr[addr] = srv
+	m.mu.Unlock()
+	go func() {
+		var buf [4096]byte
+		for {
+			n, _, err := srv.ReadFromUDPAddrPort(buf[:])
+			if err != nil {
+				m.mu.Lock()
+				delete(m.byAddr, addr)
+				m.mu.Unlock()
+				return
+			}
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			select {
+			case m.recv <- pipePacket{data: data, addr: addr}:
+			case <-m.closed:
+				return
+			}
+		}
+	}()
+}
+
+func (m *pipeMux) LocalAddr() net.Addr {
+	return &net.UDPAddr{
+		IP:   m.addr.Addr().AsSlice(),
+		Port: int(m.addr.Port()),
+	}
+}
+
+func (m *pipeMux) WriteToUDPAddrPort(data []byte, addr netip.AddrPort) (int, error) {
+	m.mu.RLock()
+	c := m.byAddr[udpconn.NormalizeAddr(addr)]
+	m.mu.RUnlock()
+	if c == nil {
+		return len(data), nil // ignore
+	}
+	return c.WriteToUDPAddrPort(data, addr)
+}
+
+func (m *pipeMux) ReadFromUDPAddrPort(data []byte) (int, netip.AddrPort, error) {
+	select {
+	case <-m.closed:
+		return 0, netip.AddrPort{}, io.ErrClosedPipe
+	case p := <-m.recv:
+		if len(p.data) > len(data) {
+			return 0, netip.AddrPort{}, io.ErrShortBuffer
+		}
+		n := copy(data, p.data)
+		return n, p.addr, nil
+	}
+}
+
+func (m *pipeMux) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-m.closed:
+	default:
+		close(m.closed)
+	}
+	for _, c := range m.byAddr {
+		_ = c.Close()
+	}
+	return nil
+}
+
+// pipeServer is an in-process server which accepts simulated clients over pipes.
+type pipeServer struct {
+	log *slog.Logger
+	imp udpconn.Impairment
+	mux *pipeMux
+	srv *noxnet.Server
+	eng *pipeEngine
+}
+
+func newPipeServer(log *slog.Logger, imp udpconn.Impairment) *pipeServer {
+	addr := netip.AddrPortFrom(netip.AddrFrom4([4]byte{1, 1, 1, 1}), udpconn.DefaultPort)
+	mux := newPipeMux(addr)
+	eng := &pipeEngine{}
+	return &pipeServer{
+		log: log,
+		imp: imp,
+		mux: mux,
+		eng: eng,
+		srv: noxnet.NewServer(log, mux, eng, nil),
+	}
+}
+
+func (s *pipeServer) Addr() netip.AddrPort {
+	return s.mux.addr
+}
+
+// Dial creates a new pipe to the server for i-th client.
+func (s *pipeServer) Dial(i int) (udpconn.PacketConn, error) {
+	srv, cli := udpconn.NewPipe(s.log, pipeBuffer)
+	srv.Addr = s.mux.addr
+	cli.Addr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{2, 2, 2, 2}), uint16(20000+i))
+	if !s.imp.IsZero() {
+		imp := s.imp
+		imp.Seed += uint64(2 * i)
+		srv.Impair(imp)
+		imp.Seed++
+		cli.Impair(imp)
+	}
+	s.mux.Add(srv, cli.Addr)
+	return cli, nil
+}
+
+// Stats returns server transport statistics and the number of handled player messages.
+func (s *pipeServer) Stats() *ServerReport {
+	return &ServerReport{
+		Stats:  s.srv.Port.Stats().Total,
+		Inputs: s.eng.inputs.Load(),
+		Joined: s.eng.joined.Load(),
+	}
+}
+
+func (s *pipeServer) Close() {
+	s.srv.Close()
+	_ = s.mux.Close()
+}
+
+// pipeEngine is a minimal game engine that lets bots join and counts their inputs.
+type pipeEngine struct {
+	players atomic.Int32
+	joined  atomic.Int32
+	inputs  atomic.Uint64
+}
+
+type pipePlayer struct {
+	e    *pipeEngine
+	id   noxnet.PlayerID
+	done atomic.Bool
+}
+
+func (p *pipePlayer) PlayerID() noxnet.PlayerID {
+	return p.id
+}
+
+func (p *pipePlayer) PlayerName() string {
+	return ""
+}
+
+func (p *pipePlayer) Disconnect() {
+	if p.done.CompareAndSwap(false, true) {
+		p.e.players.Add(-1)
+	}
+}
+
+func (e *pipeEngine) ServerInfo(addr netip.AddrPort) *discover.MsgServerInfo {
+	return &discover.MsgServerInfo{
+		PlayersCur: byte(e.players.Load()),
+		PlayersMax: common.MaxPlayers,
+		MapName:    "estate",
+		ServerName: "Load test",
+	}
+}
+
+func (e *pipeEngine) PreJoin(addr netip.AddrPort, req *noxnet.MsgServerTryJoin) error {
+	if e.players.Load() >= common.MaxPlayers {
+		return noxnet.ErrFull
+	}
+	return nil
+}
+
+func (e *pipeEngine) CheckPass(addr netip.AddrPort, pass string) error {
+	return nil
+}
+
+func (e *pipeEngine) Connect(addr netip.AddrPort) (noxnet.Player, error) {
+	n := e.players.Add(1)
+	if n > common.MaxPlayers {
+		e.players.Add(-1)
+		return nil, noxnet.ErrFull
+	}
+	return &pipePlayer{e: e, id: noxnet.PlayerID(addr.Port())}, nil
+}
+
+func (e *pipeEngine) PlayerMessage(s udpconn.Stream, p noxnet.Player, m netmsg.Message) bool {
+	switch m := m.(type) {
+	case *noxnet.MsgClientAccept:
+		s.QueueReliable(udpconn.Options{},
+			&noxnet.MsgUseMap{MapName: binenc.String{Value: "estate"}},
+			&noxnet.MsgJoinData{NetCode: noxnet.NetCode(p.PlayerID())},
+		)
+		_ = s.SendQueue()
+		return true
+	case *noxnet.MsgImportantSrv:
+		e.joined.Add(1)
+		_ = s.SendUnreliable(&noxnet.MsgImportantAckCli{ID: m.ID})
+		return true
+	case *noxnet.MsgPlayerInput, *noxnet.MsgMouse:
+		e.inputs.Add(1)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+// ClientReport contains results for a single client.
+type ClientReport struct {
+	Joined   bool
+	JoinTime time.Duration
+	Err      error
+	Stats    udpconn.Stats
+}
+
+// ServerReport contains results collected by the in-process server.
+type ServerReport struct {
+	Stats  udpconn.Stats
+	Joined int32
+	Inputs uint64
+}
+
+// Report contains results of the load test.
+type Report struct {
+	Duration time.Duration
+	Clients  []ClientReport
+	Server   *ServerReport
+}
+
+// Failed returns the number of clients that didn't join.
+func (r *Report) Failed() int {
+	n := 0
+	for _, c := range r.Clients {
+		if !c.Joined {
+			n++
+		}
+	}
+	return n
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(sorted)-1))
+	return sorted[i]
+}
+
+func ratio(a, b uint64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return 100 * float64(a) / float64(b)
+}
+
+func (r *Report) Print(w io.Writer) {
+	var (
+		joins []time.Duration
+		total udpconn.Stats
+		errs  = make(map[string]int)
+	)
+	for _, c := range r.Clients {
+		if c.Joined {
+			joins = append(joins, c.JoinTime)
+		} else if c.Err != nil {
+			errs[c.Err.Error()]++
+		}
+		s := c.Stats
+		total.PacketsIn += s.PacketsIn
+		total.PacketsOut += s.PacketsOut
+		total.BytesIn += s.BytesIn
+		total.BytesOut += s.BytesOut
+		total.Resends += s.Resends
+		total.Timeouts += s.Timeouts
+		total.Dropped += s.Dropped
+		total.Duplicates += s.Duplicates
+		total.Errors += s.Errors
+	}
+	slices.Sort(joins)
+
+	fmt.Fprintf(w, "duration:   %v\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "clients:    %d joined, %d failed\n", len(joins), r.Failed())
+	if len(errs) != 0 {
+		keys := make([]string, 0, len(errs))
+		for k := range errs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %4d x %s\n", errs[k], k)
+		}
+	}
+	if len(joins) != 0 {
+		var sum time.Duration
+		for _, d := range joins {
+			sum += d
+		}
+		fmt.Fprintf(w, "join time:  min %v, avg %v, p50 %v, p95 %v, max %v\n",
+			joins[0].Round(time.Microsecond),
+			(sum / time.Duration(len(joins))).Round(time.Microsecond),
+			percentile(joins, 0.50).Round(time.Microsecond),
+			percentile(joins, 0.95).Round(time.Microsecond),
+			joins[len(joins)-1].Round(time.Microsecond),
+		)
+	}
+	fmt.Fprintf(w, "packets:    %d sent (%d bytes), %d received (%d bytes)\n",
+		total.PacketsOut, total.BytesOut, total.PacketsIn, total.BytesIn)
+	fmt.Fprintf(w, "resends:    %d (%.2f%% of sent), %d timed out\n",
+		total.Resends, ratio(total.Resends, total.PacketsOut), total.Timeouts)
+	fmt.Fprintf(w, "dropped:    %d (%d duplicates), %d decode errors\n",
+		total.Dropped, total.Duplicates, total.Errors)
+	if s := r.Server; s != nil {
+		// packets are only lost on the pipe, so the difference between both ends is the real loss
+		up := total.PacketsOut - min(total.PacketsOut, s.Stats.PacketsIn)
+		down := s.Stats.PacketsOut - min(s.Stats.PacketsOut, total.PacketsIn)
+		fmt.Fprintf(w, "loss:       %.2f%% to server, %.2f%% to clients\n",
+			ratio(up, total.PacketsOut), ratio(down, s.Stats.PacketsOut))
+		fmt.Fprintf(w, "server:     %d joined, %d input messages, %d resends\n",
+			s.Joined, s.Inputs, s.Stats.Resends)
+	} else {
+		// only reliable packets can be observed as lost from the client side
+		fmt.Fprintf(w, "loss:       ~%.2f%% (estimated from resends)\n",
+			ratio(total.Resends, total.PacketsOut))
+	}
+}
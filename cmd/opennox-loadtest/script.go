@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/opennox/libs/noxnet"
+)
+
+// Script is a list of input steps replayed by each client in a loop.
+type Script []Step
+
+// Step changes the inputs of the client and waits before the next step.
+//
+// Inputs are written as control code names, optionally followed by a value: "MoveForward" or "Orientation=32".
+type Step struct {
+	Hold    []string      `yaml:"hold,omitempty"`
+	Release []string      `yaml:"release,omitempty"`
+	Press   []string      `yaml:"press,omitempty"`
+	Mouse   []uint16      `yaml:"mouse,omitempty"`
+	Wait    time.Duration `yaml:"wait,omitempty"`
+
+	hold    []noxnet.PlayerInput
+	release []noxnet.CtrlCode
+	press   []noxnet.PlayerInput
+}
+
+// defaultScript makes clients walk in circles around the map center.
+var defaultScript = func() Script {
+	const (
+		steps  = 16
+		radius = 200
+		cx, cy = 2500, 2500
+	)
+	s := Script{{Hold: []string{"MoveForward"}}}
+	for i := range steps {
+		a := 2 * math.Pi * float64(i) / steps
+		s = append(s, Step{
+			Mouse: []uint16{uint16(cx + radius*math.Cos(a)), uint16(cy + radius*math.Sin(a))},
+			Wait:  250 * time.Millisecond,
+		})
+	}
+	s = append(s, Step{Press: []string{"Action"}})
+	if err := s.compile(); err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+func readScript(path string) (Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Script
+	if err = yaml.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, fmt.Errorf("script %q is empty", path)
+	}
+	if err = s.compile(); err != nil {
+		return nil, fmt.Errorf("script %q: %w", path, err)
+	}
+	return s, nil
+}
+
+func parseInput(s string) (noxnet.PlayerInput, error) {
+	name, sval, hasVal := strings.Cut(s, "=")
+	code, ok := noxnet.ParseCtrlCode(strings.TrimSpace(name))
+	if !ok {
+		return nil, fmt.Errorf("unknown control code: %q", name)
+	}
+	var val uint64
+	if hasVal {
+		var err error
+		val, err = strconv.ParseUint(strings.TrimSpace(sval), 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %v: %w", code, err)
+		}
+	}
+	switch code.DataSize() {
+	case 0:
+		if hasVal {
+			return nil, fmt.Errorf("%v doesn't accept a value", code)
+		}
+		return &noxnet.PlayerInput0{Code: code}, nil
+	case 1:
+		if val > math.MaxUint8 {
+			return nil, fmt.Errorf("value for %v is out of range: %d", code, val)
+		}
+		return &noxnet.PlayerInput1{Code: code, Val: byte(val)}, nil
+	default:
+		return &noxnet.PlayerInput4{Code: code, Val: uint32(val)}, nil
+	}
+}
+
+// compile parses inputs of all steps.
+func (s Script) compile() error {
+	for i := range s {
+		st := &s[i]
+		if len(st.Mouse) != 0 && len(st.Mouse) != 2 {
+			return fmt.Errorf("step %d: mouse must be a pair of coordinates", i)
+		}
+		for _, v := range st.Hold {
+			in, err := parseInput(v)
+			if err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+			st.hold = append(st.hold, in)
+		}
+		for _, v := range st.Press {
+			in, err := parseInput(v)
+			if err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+			st.press = append(st.press, in)
+		}
+		for _, v := range st.Release {
+			code, ok := noxnet.ParseCtrlCode(v)
+			if !ok {
+				return fmt.Errorf("step %d: unknown control code: %q", i, v)
+			}
+			st.release = append(st.release, code)
+		}
+	}
+	return nil
+}
+
+// Play replays the script in a loop until the context is canceled.
+// Clients start from different steps, so they don't send the same inputs at the same time.
+// Steps without a wait time last for one tick.
+func (s Script) Play(ctx context.Context, b *noxnet.Bot, offset int, tick time.Duration) {
+	if len(s) == 0 {
+		<-ctx.Done()
+		return
+	}
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for i := offset % len(s); ; i = (i + 1) % len(s) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		st := &s[i]
+		if len(st.release) != 0 {
+			b.Release(st.release...)
+		}
+		if len(st.hold) != 0 {
+			b.Hold(st.hold...)
+		}
+		if len(st.press) != 0 {
+			b.Press(st.press...)
+		}
+		if len(st.Mouse) == 2 {
+			b.SetMouse(st.Mouse[0], st.Mouse[1])
+		}
+		wait := st.Wait
+		if wait <= 0 {
+			wait = tick
+		}
+		t.Reset(wait)
+	}
+}
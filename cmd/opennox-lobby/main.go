@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/opennox/libs/noxnet/lobby"
+)
+
+var (
+	fHost     = flag.String("host", fmt.Sprintf(":%d", lobby.DefaultPort), "host to listen on")
+	fTTL      = flag.Duration("ttl", lobby.DefaultTTL, "time after which servers that stopped sending heartbeats are removed")
+	fMaxPerIP = flag.Int("max-per-ip", 8, "max number of servers registered from a single IP; zero means no limit")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	srv := lobby.NewServer(slog.Default(), &lobby.ServerOptions{
+		TTL:      *fTTL,
+		MaxPerIP: *fMaxPerIP,
+	})
+	slog.Info("serving lobby", "host", *fHost)
+	return http.ListenAndServe(*fHost, srv)
+}
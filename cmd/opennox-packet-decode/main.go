@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"encoding/hex"
 	"encoding/json"
 	"flag"
@@ -8,14 +9,17 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/opennox/libs/common"
 	_ "github.com/opennox/libs/noxnet"
 	"github.com/opennox/libs/noxnet/netmsg"
 )
 
 var (
-	fIn  = flag.String("i", "network.jsonl", "input file with packet capture")
-	fOut = flag.String("o", "network-dec.jsonl", "output file for decoded packets")
+	fIn   = flag.String("i", "network.jsonl", "input file with packet capture (proxy JSONL, pcap or pcapng)")
+	fOut  = flag.String("o", "network-dec.jsonl", "output file for decoded packets")
+	fPort = flag.Int("port", common.GamePort, "game server port to filter pcap/pcapng captures on")
 )
 
 func main() {
@@ -32,7 +36,7 @@ func run() error {
 		return err
 	}
 	defer f.Close()
-	dec := json.NewDecoder(f)
+	br := bufio.NewReader(f)
 
 	w, err := os.Create(*fOut)
 	if err != nil {
@@ -42,28 +46,44 @@ func run() error {
 	enc := json.NewEncoder(w)
 
 	var mdec netmsg.State
+	emit := func(r RecordIn) error {
+		return enc.Encode(r.Decode(&mdec))
+	}
+	if isCapture(br) {
+		err = readCapture(br, uint16(*fPort), emit)
+	} else {
+		err = readRecords(br, emit)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// readRecords reads JSONL records written by opennox-proxy.
+func readRecords(r io.Reader, fnc func(r RecordIn) error) error {
+	dec := json.NewDecoder(r)
 	for {
-		var r RecordIn
-		err := dec.Decode(&r)
+		var rec RecordIn
+		err := dec.Decode(&rec)
 		if err == io.EOF {
-			break
+			return nil
 		} else if err != nil {
 			return err
 		}
-		r2 := r.Decode(&mdec)
-		if err = enc.Encode(r2); err != nil {
+		if err = fnc(rec); err != nil {
 			return err
 		}
 	}
-	return w.Close()
 }
 
 type RecordIn struct {
-	SrcID uint32 `json:"src_id"`
-	DstID uint32 `json:"dst_id"`
-	Src   string `json:"src"`
-	Dst   string `json:"dst"`
-	Data  string `json:"data"`
+	Time  time.Time `json:"time"`
+	SrcID uint32    `json:"src_id"`
+	DstID uint32    `json:"dst_id"`
+	Src   string    `json:"src"`
+	Dst   string    `json:"dst"`
+	Data  string    `json:"data"`
 }
 
 func isUnknown(m netmsg.Message) bool {
@@ -79,6 +99,10 @@ func (r RecordIn) Decode(dec *netmsg.State) RecordOut {
 		Dst:   r.Dst,
 		Data:  r.Data,
 	}
+	if !r.Time.IsZero() {
+		t := r.Time
+		o.Time = &t
+	}
 	raw, err := hex.DecodeString(r.Data)
 	if err != nil {
 		return o
@@ -96,7 +120,7 @@ func (r RecordIn) Decode(dec *netmsg.State) RecordOut {
 	} else {
 		o.Ack = &seq
 	}
-	dec.IsClient = o.SrcID != 0
+	dec.IsClient = o.SrcID == 0 // decode messages sent to the client
 	if len(data) == 1 {
 		op := netmsg.Op(data[0])
 		if _, _, err := dec.DecodeNext(data); err != nil {
@@ -129,6 +153,7 @@ func (r RecordIn) Decode(dec *netmsg.State) RecordOut {
 		data = data[sz:]
 		m := Msg{
 			Op:     op.String(),
+			Frame:  dec.Frame(),
 			Len:    len(msg),
 			Data:   hex.EncodeToString(msg),
 			Fields: v,
@@ -145,23 +170,25 @@ func (r RecordIn) Decode(dec *netmsg.State) RecordOut {
 }
 
 type RecordOut struct {
-	SrcID uint32   `json:"src_id"`
-	DstID uint32   `json:"dst_id"`
-	Src   string   `json:"src"`
-	Dst   string   `json:"dst"`
-	Hdr   string   `json:"hdr"`
-	SID   byte     `json:"sid"`
-	Syn   *byte    `json:"syn,omitempty"`
-	Ack   *byte    `json:"ack,omitempty"`
-	Len   int      `json:"len"`
-	Op    *string  `json:"op,omitempty"`
-	Ops   []string `json:"ops,omitempty"`
-	Msgs  []Msg    `json:"msgs,omitempty"`
-	Data  string   `json:"data,omitempty"`
+	Time  *time.Time `json:"time,omitempty"`
+	SrcID uint32     `json:"src_id"`
+	DstID uint32     `json:"dst_id"`
+	Src   string     `json:"src"`
+	Dst   string     `json:"dst"`
+	Hdr   string     `json:"hdr"`
+	SID   byte       `json:"sid"`
+	Syn   *byte      `json:"syn,omitempty"`
+	Ack   *byte      `json:"ack,omitempty"`
+	Len   int        `json:"len"`
+	Op    *string    `json:"op,omitempty"`
+	Ops   []string   `json:"ops,omitempty"`
+	Msgs  []Msg      `json:"msgs,omitempty"`
+	Data  string     `json:"data,omitempty"`
 }
 
 type Msg struct {
 	Op     string `json:"op,omitempty"`
+	Frame  uint32 `json:"frame,omitempty"`
 	Fields any    `json:"fields,omitempty"`
 	Len    int    `json:"len"`
 	Data   string `json:"data"`
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/netip"
+	"time"
+
+	"github.com/opennox/libs/noxnet"
+)
+
+const (
+	pcapMagicUS   = 0xa1b2c3d4
+	pcapMagicNS   = 0xa1b23c4d
+	pcapngSHB     = 0x0a0d0d0a
+	pcapngBOM     = 0x1a2b3c4d
+	pcapngIDB     = 0x00000001
+	pcapngPB      = 0x00000002 // obsolete packet block
+	pcapngSPB     = 0x00000003
+	pcapngEPB     = 0x00000006
+	pcapngTSResol = 9
+)
+
+// Link types, see https://www.tcpdump.org/linktypes.html.
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkRawAlt1  = 12 // DLT_RAW on some platforms
+	linkRawAlt2  = 14 // DLT_RAW on some platforms
+	linkLoop     = 108
+	linkSLL      = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+// frame is a link-layer frame read from a capture file.
+type frame struct {
+	Time time.Time
+	Link uint32
+	Data []byte
+}
+
+// captureReader reads frames from a capture file.
+type captureReader interface {
+	Next() (*frame, error)
+}
+
+// isCapture checks if the data starts with pcap or pcapng magic.
+func isCapture(r *bufio.Reader) bool {
+	b, err := r.Peek(4)
+	if err != nil {
+		return false
+	}
+	for _, v := range []uint32{binary.LittleEndian.Uint32(b), binary.BigEndian.Uint32(b)} {
+		switch v {
+		case pcapMagicUS, pcapMagicNS, pcapngSHB:
+			return true
+		}
+	}
+	return false
+}
+
+// newCaptureReader detects the capture format and returns a reader for it.
+func newCaptureReader(r io.Reader) (captureReader, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(b[:]) == pcapngSHB {
+		p := &pcapngReader{r: r}
+		if err := p.readSHB(); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	p := &pcapReader{r: r}
+	switch {
+	case binary.LittleEndian.Uint32(b[:]) == pcapMagicUS:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(b[:]) == pcapMagicUS:
+		p.order = binary.BigEndian
+	case binary.LittleEndian.Uint32(b[:]) == pcapMagicNS:
+		p.order, p.nano = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(b[:]) == pcapMagicNS:
+		p.order, p.nano = binary.BigEndian, true
+	default:
+		return nil, errors.New("unsupported capture format")
+	}
+	var hdr [20]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	p.link = p.order.Uint32(hdr[16:20]) & 0x0fffffff // upper bits contain FCS info
+	return p, nil
+}
+
+// pcapReader reads libpcap files.
+type pcapReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	nano  bool
+	link  uint32
+	buf   []byte
+}
+
+func (p *pcapReader) Next() (*frame, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(p.r, hdr[:]); err == io.ErrUnexpectedEOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, err
+	}
+	sec := int64(p.order.Uint32(hdr[0:4]))
+	sub := int64(p.order.Uint32(hdr[4:8]))
+	n := p.order.Uint32(hdr[8:12])
+	if n > 1<<20 {
+		return nil, fmt.Errorf("packet is too large: %d", n)
+	}
+	if !p.nano {
+		sub *= 1000
+	}
+	if cap(p.buf) < int(n) {
+		p.buf = make([]byte, n)
+	}
+	data := p.buf[:n]
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return nil, err
+	}
+	return &frame{Time: time.Unix(sec, sub), Link: p.link, Data: data}, nil
+}
+
+type pcapngIface struct {
+	link uint32
+	// timestamp resolution
+	mul, div int64
+}
+
+// pcapngReader reads pcapng files.
+type pcapngReader struct {
+	r      io.Reader
+	order  binary.ByteOrder
+	ifaces []pcapngIface
+	buf    []byte
+}
+
+// readSHB reads section header block. Block type must be already consumed.
+func (p *pcapngReader) readSHB() error {
+	var b [8]byte
+	if _, err := io.ReadFull(p.r, b[:]); err != nil {
+		return err
+	}
+	switch {
+	case binary.LittleEndian.Uint32(b[4:8]) == pcapngBOM:
+		p.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(b[4:8]) == pcapngBOM:
+		p.order = binary.BigEndian
+	default:
+		return errors.New("invalid pcapng byte order")
+	}
+	n := p.order.Uint32(b[0:4])
+	if n < 12+16 || n%4 != 0 {
+		return fmt.Errorf("invalid pcapng section header size: %d", n)
+	}
+	p.ifaces = p.ifaces[:0]
+	// skip the rest of the header
+	_, err := io.CopyN(io.Discard, p.r, int64(n-12))
+	return err
+}
+
+func (p *pcapngReader) readBlock() (uint32, []byte, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(p.r, b[:4]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, nil, err
+	}
+	typ := p.order.Uint32(b[:4])
+	if typ == pcapngSHB {
+		return typ, nil, p.readSHB()
+	}
+	if _, err := io.ReadFull(p.r, b[4:8]); err != nil {
+		return 0, nil, err
+	}
+	n := p.order.Uint32(b[4:8])
+	if n < 12 || n%4 != 0 || n > 1<<24 {
+		return 0, nil, fmt.Errorf("invalid pcapng block size: %d", n)
+	}
+	if cap(p.buf) < int(n-8) {
+		p.buf = make([]byte, n-8)
+	}
+	data := p.buf[:n-8]
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return 0, nil, err
+	}
+	return typ, data[:len(data)-4], nil
+}
+
+func (p *pcapngReader) readIDB(data []byte) error {
+	if len(data) < 8 {
+		return errors.New("invalid pcapng interface block")
+	}
+	iface := pcapngIface{link: uint32(p.order.Uint16(data[0:2])), mul: 1000, div: 1}
+	for opts := data[8:]; len(opts) >= 4; {
+		code, sz := p.order.Uint16(opts[0:2]), int(p.order.Uint16(opts[2:4]))
+		opts = opts[4:]
+		if code == 0 || sz > len(opts) {
+			break
+		}
+		if code == pcapngTSResol && sz >= 1 {
+			if units, ok := pcapngUnits(opts[0]); ok {
+				// nanoseconds per tick
+				iface.mul, iface.div = 1_000_000_000, units
+			}
+		}
+		// options are padded to 4 bytes, but the last one may be truncated
+		opts = opts[min((sz+3)&^3, len(opts)):]
+	}
+	p.ifaces = append(p.ifaces, iface)
+	return nil
+}
+
+// pcapngUnits returns the number of timestamp ticks per second for if_tsresol option.
+// It returns false if the value doesn't fit into int64.
+func pcapngUnits(v byte) (int64, bool) {
+	var units int64 = 1
+	if v&0x80 != 0 {
+		if v&0x7f > 62 {
+			return 0, false
+		}
+		return units << (v & 0x7f), true
+	}
+	if v > 18 {
+		return 0, false
+	}
+	for range v {
+		units *= 10
+	}
+	return units, true
+}
+
+func (p *pcapngReader) timestamp(iface pcapngIface, hi, lo uint32) time.Time {
+	ts := uint64(hi)<<32 | uint64(lo)
+	if iface.div == 1 {
+		return time.Unix(0, int64(ts)*iface.mul)
+	}
+	div := uint64(iface.div)
+	sec, sub := ts/div, ts%div
+	// sub*mul may not fit into 64 bits for high resolutions
+	nh, nl := bits.Mul64(sub, uint64(iface.mul))
+	nsec, _ := bits.Div64(nh, nl, div)
+	return time.Unix(int64(sec), int64(nsec))
+}
+
+func (p *pcapngReader) Next() (*frame, error) {
+	for {
+		typ, data, err := p.readBlock()
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case pcapngIDB:
+			if err := p.readIDB(data); err != nil {
+				return nil, err
+			}
+		case pcapngEPB, pcapngPB:
+			if len(data) < 20 {
+				return nil, errors.New("invalid pcapng packet block")
+			}
+			var id uint32
+			if typ == pcapngEPB {
+				id = p.order.Uint32(data[0:4])
+			} else {
+				id = uint32(p.order.Uint16(data[0:2]))
+			}
+			if int(id) >= len(p.ifaces) {
+				return nil, fmt.Errorf("pcapng packet for unknown interface: %d", id)
+			}
+			iface := p.ifaces[id]
+			n := p.order.Uint32(data[12:16])
+			if int(n) > len(data)-20 {
+				return nil, errors.New("invalid pcapng packet size")
+			}
+			return &frame{
+				Time: p.timestamp(iface, p.order.Uint32(data[4:8]), p.order.Uint32(data[8:12])),
+				Link: iface.link,
+				Data: data[20 : 20+n],
+			}, nil
+		case pcapngSPB:
+			if len(data) < 4 || len(p.ifaces) == 0 {
+				return nil, errors.New("invalid pcapng simple packet block")
+			}
+			n := min(int(p.order.Uint32(data[0:4])), len(data)-4)
+			return &frame{Link: p.ifaces[0].link, Data: data[4 : 4+n]}, nil
+		}
+	}
+}
+
+// parseUDP extracts UDP datagram from a link-layer frame.
+func parseUDP(link uint32, data []byte) (src, dst netip.AddrPort, payload []byte, ok bool) {
+	var ip []byte
+	switch link {
+	case linkEthernet:
+		if len(data) < 14 {
+			return
+		}
+		typ, off := binary.BigEndian.Uint16(data[12:14]), 14
+		for (typ == 0x8100 || typ == 0x88a8) && len(data) >= off+4 {
+			// VLAN tags
+			typ, off = binary.BigEndian.Uint16(data[off+2:off+4]), off+4
+		}
+		if typ != 0x0800 && typ != 0x86dd {
+			return
+		}
+		ip = data[off:]
+	case linkSLL:
+		if len(data) < 16 {
+			return
+		}
+		ip = data[16:]
+	case linkSLL2:
+		if len(data) < 20 {
+			return
+		}
+		ip = data[20:]
+	case linkNull, linkLoop:
+		if len(data) < 4 {
+			return
+		}
+		ip = data[4:]
+	case linkRaw, linkRawAlt1, linkRawAlt2, linkIPv4, linkIPv6:
+		ip = data
+	default:
+		return
+	}
+	if len(ip) < 1 {
+		return
+	}
+	var (
+		srcIP, dstIP netip.Addr
+		udp          []byte
+	)
+	switch ip[0] >> 4 {
+	case 4:
+		if len(ip) < 20 {
+			return
+		}
+		hl := int(ip[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(ip[2:4]))
+		if hl < 20 || total < hl || len(ip) < hl {
+			return
+		}
+		if frag := binary.BigEndian.Uint16(ip[6:8]); frag&0x3fff != 0 {
+			return // fragmented
+		}
+		if ip[9] != 17 {
+			return
+		}
+		srcIP = netip.AddrFrom4([4]byte(ip[12:16]))
+		dstIP = netip.AddrFrom4([4]byte(ip[16:20]))
+		udp = ip[hl:min(total, len(ip))]
+	case 6:
+		if len(ip) < 40 {
+			return
+		}
+		next, off := ip[6], 40
+		for next == 0 || next == 43 || next == 60 {
+			// hop-by-hop, routing and destination options
+			if len(ip) < off+8 {
+				return
+			}
+			next, off = ip[off], off+8+int(ip[off+1])*8
+		}
+		if next != 17 || len(ip) < off {
+			return
+		}
+		end := min(40+int(binary.BigEndian.Uint16(ip[4:6])), len(ip))
+		if end < off {
+			return // extension headers exceed the payload
+		}
+		srcIP = netip.AddrFrom16([16]byte(ip[8:24]))
+		dstIP = netip.AddrFrom16([16]byte(ip[24:40]))
+		udp = ip[off:end]
+	default:
+		return
+	}
+	if len(udp) < 8 {
+		return
+	}
+	n := int(binary.BigEndian.Uint16(udp[4:6]))
+	if n < 8 || n > len(udp) {
+		n = len(udp)
+	}
+	src = netip.AddrPortFrom(srcIP, binary.BigEndian.Uint16(udp[0:2]))
+	dst = netip.AddrPortFrom(dstIP, binary.BigEndian.Uint16(udp[2:4]))
+	return src, dst, udp[8:n], true
+}
+
+type flow struct {
+	id  uint32
+	xor byte
+}
+
+// flowTracker assigns client IDs to UDP flows and tracks xor keys.
+type flowTracker struct {
+	port    uint16
+	servers map[netip.AddrPort]bool
+	clients map[netip.AddrPort]*flow
+	lastID  uint32
+}
+
+func newFlowTracker(port uint16) *flowTracker {
+	return &flowTracker{
+		port:    port,
+		servers: make(map[netip.AddrPort]bool),
+		clients: make(map[netip.AddrPort]*flow),
+	}
+}
+
+// Record converts a datagram to a record. It returns false if the datagram isn't related to the game.
+//
+// Servers are detected by the game port: the first host that receives a packet on it is considered a server.
+// Clients are identified by their address, even if they talk to multiple servers.
+func (t *flowTracker) Record(ts time.Time, src, dst netip.AddrPort, data []byte) (RecordIn, bool) {
+	fromSrv := false
+	switch {
+	case t.servers[src]:
+		fromSrv = true
+	case t.servers[dst]:
+	case dst.Port() == t.port:
+		if ip := dst.Addr(); !ip.IsMulticast() && ip != netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+			t.servers[dst] = true
+		}
+	case src.Port() == t.port:
+		t.servers[src] = true
+		fromSrv = true
+	default:
+		return RecordIn{}, false
+	}
+	cli := src
+	if fromSrv {
+		cli = dst
+	}
+	f := t.clients[cli]
+	if f == nil {
+		t.lastID++
+		f = &flow{id: t.lastID}
+		t.clients[cli] = f
+	}
+	data = append([]byte{}, data...)
+	if f.xor != 0 {
+		for i := range data {
+			data[i] ^= f.xor
+		}
+	}
+	r := RecordIn{Time: ts, Data: hex.EncodeToString(data)}
+	if fromSrv {
+		r.SrcID, r.Src = 0, "SRV"
+		r.DstID, r.Dst = f.id, fmt.Sprintf("CLI%d", f.id)
+		if key, ok := noxnet.ServerXorKey(data); ok {
+			f.xor = key
+		}
+	} else {
+		r.SrcID, r.Src = f.id, fmt.Sprintf("CLI%d", f.id)
+		r.DstID, r.Dst = 0, "SRV"
+	}
+	return r, true
+}
+
+// readCapture reads game packets from pcap or pcapng file.
+func readCapture(r io.Reader, port uint16, fnc func(r RecordIn) error) error {
+	cr, err := newCaptureReader(r)
+	if err != nil {
+		return err
+	}
+	t := newFlowTracker(port)
+	for {
+		f, err := cr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		src, dst, data, ok := parseUDP(f.Link, f.Data)
+		if !ok {
+			continue
+		}
+		rec, ok := t.Record(f.Time, src, dst, data)
+		if !ok {
+			continue
+		}
+		if err = fnc(rec); err != nil {
+			return err
+		}
+	}
+}
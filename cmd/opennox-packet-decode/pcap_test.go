@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/noxnet"
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+var (
+	testSrv4 = netip.MustParseAddrPort("10.0.0.1:18590")
+	testCli4 = netip.MustParseAddrPort("10.0.0.2:40000")
+	testSrv6 = netip.MustParseAddrPort("[fd00::1]:18590")
+	testCli6 = netip.MustParseAddrPort("[fd00::2]:40000")
+)
+
+// udpDatagram builds an IPv4 or IPv6 packet with a UDP datagram.
+func udpDatagram(src, dst netip.AddrPort, payload []byte) []byte {
+	udp := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint16(udp[0:2], src.Port())
+	binary.BigEndian.PutUint16(udp[2:4], dst.Port())
+	binary.BigEndian.PutUint16(udp[4:6], uint16(len(udp)))
+	copy(udp[8:], payload)
+	if src.Addr().Is4() {
+		ip := make([]byte, 20, 20+len(udp))
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(udp)))
+		ip[8] = 64
+		ip[9] = 17
+		s, d := src.Addr().As4(), dst.Addr().As4()
+		copy(ip[12:16], s[:])
+		copy(ip[16:20], d[:])
+		return append(ip, udp...)
+	}
+	ip := make([]byte, 40, 40+len(udp))
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:6], uint16(len(udp)))
+	ip[6] = 17
+	ip[7] = 64
+	s, d := src.Addr().As16(), dst.Addr().As16()
+	copy(ip[8:24], s[:])
+	copy(ip[24:40], d[:])
+	return append(ip, udp...)
+}
+
+func ethernetFrame(ip []byte) []byte {
+	typ := uint16(0x0800)
+	if ip[0]>>4 == 6 {
+		typ = 0x86dd
+	}
+	b := make([]byte, 14, 14+len(ip))
+	binary.BigEndian.PutUint16(b[12:14], typ)
+	return append(b, ip...)
+}
+
+func vlanFrame(ip []byte) []byte {
+	b := make([]byte, 18, 18+len(ip))
+	binary.BigEndian.PutUint16(b[12:14], 0x8100)
+	binary.BigEndian.PutUint16(b[16:18], 0x0800)
+	return append(b, ip...)
+}
+
+func sllFrame(ip []byte) []byte {
+	b := make([]byte, 16, 16+len(ip))
+	binary.BigEndian.PutUint16(b[14:16], 0x0800)
+	return append(b, ip...)
+}
+
+func TestParseUDP(t *testing.T) {
+	payload := []byte{0x00, 0x00, 0x0c}
+	ip4 := udpDatagram(testCli4, testSrv4, payload)
+	ip6 := udpDatagram(testCli6, testSrv6, payload)
+
+	// IPv6 with a destination options header larger than the payload
+	badExt := udpDatagram(testCli6, testSrv6, payload)
+	badExt[6] = 60
+	binary.BigEndian.PutUint16(badExt[4:6], 4)
+	badExt[40], badExt[41] = 17, 0
+
+	cases := []struct {
+		name string
+		link uint32
+		data []byte
+		src  netip.AddrPort
+		dst  netip.AddrPort
+		ok   bool
+	}{
+		{name: "ethernet ipv4", link: linkEthernet, data: ethernetFrame(ip4), src: testCli4, dst: testSrv4, ok: true},
+		{name: "ethernet vlan", link: linkEthernet, data: vlanFrame(ip4), src: testCli4, dst: testSrv4, ok: true},
+		{name: "ethernet ipv6", link: linkEthernet, data: ethernetFrame(ip6), src: testCli6, dst: testSrv6, ok: true},
+		{name: "sll", link: linkSLL, data: sllFrame(ip4), src: testCli4, dst: testSrv4, ok: true},
+		{name: "raw ipv6", link: linkRaw, data: ip6, src: testCli6, dst: testSrv6, ok: true},
+		{name: "null", link: linkNull, data: append([]byte{2, 0, 0, 0}, ip4...), src: testCli4, dst: testSrv4, ok: true},
+		{name: "ipv6 bad ext", link: linkRaw, data: badExt},
+		{name: "ipv4 truncated", link: linkRaw, data: ip4[:24]},
+		{name: "ipv6 truncated", link: linkRaw, data: ip6[:30]},
+		{name: "ethernet short", link: linkEthernet, data: ethernetFrame(ip4)[:10]},
+		{name: "sll short", link: linkSLL, data: ip4[:10]},
+		{name: "unknown link", link: 9999, data: ip4},
+		{name: "empty", link: linkRaw},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			src, dst, data, ok := parseUDP(c.link, c.data)
+			must.EqOp(t, c.ok, ok)
+			if !c.ok {
+				return
+			}
+			must.EqOp(t, c.src, src)
+			must.EqOp(t, c.dst, dst)
+			must.Eq(t, payload, data)
+		})
+	}
+}
+
+// writePcap writes a libpcap file with microsecond timestamps.
+func writePcap(order binary.ByteOrder, link uint32, start time.Time, frames ...[]byte) []byte {
+	var buf bytes.Buffer
+	hdr := make([]byte, 24)
+	order.PutUint32(hdr[0:4], pcapMagicUS)
+	order.PutUint16(hdr[4:6], 2)
+	order.PutUint16(hdr[6:8], 4)
+	order.PutUint32(hdr[16:20], 0xffff)
+	order.PutUint32(hdr[20:24], link)
+	buf.Write(hdr)
+	for i, f := range frames {
+		ts := start.Add(time.Duration(i) * time.Millisecond)
+		var ph [16]byte
+		order.PutUint32(ph[0:4], uint32(ts.Unix()))
+		order.PutUint32(ph[4:8], uint32(ts.Nanosecond()/1000))
+		order.PutUint32(ph[8:12], uint32(len(f)))
+		order.PutUint32(ph[12:16], uint32(len(f)))
+		buf.Write(ph[:])
+		buf.Write(f)
+	}
+	return buf.Bytes()
+}
+
+func pcapngBlock(typ uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	b := make([]byte, 8, 12+len(body))
+	binary.LittleEndian.PutUint32(b[0:4], typ)
+	binary.LittleEndian.PutUint32(b[4:8], uint32(12+len(body)))
+	b = append(b, body...)
+	return binary.LittleEndian.AppendUint32(b, uint32(12+len(body)))
+}
+
+// writePcapng writes a pcapng file with a single interface. Options are appended to the interface block as is.
+func writePcapng(link uint16, opts []byte, ticks func(i int) uint64, frames ...[]byte) []byte {
+	var buf bytes.Buffer
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:4], pcapngBOM)
+	binary.LittleEndian.PutUint16(shb[4:6], 1)
+	binary.LittleEndian.PutUint64(shb[8:16], ^uint64(0))
+	buf.Write(pcapngBlock(pcapngSHB, shb))
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:2], link)
+	buf.Write(pcapngBlock(pcapngIDB, append(idb, opts...)))
+	for i, f := range frames {
+		ts := ticks(i)
+		epb := make([]byte, 20, 20+len(f))
+		binary.LittleEndian.PutUint32(epb[4:8], uint32(ts>>32))
+		binary.LittleEndian.PutUint32(epb[8:12], uint32(ts))
+		binary.LittleEndian.PutUint32(epb[12:16], uint32(len(f)))
+		binary.LittleEndian.PutUint32(epb[16:20], uint32(len(f)))
+		buf.Write(pcapngBlock(pcapngEPB, append(epb, f...)))
+	}
+	return buf.Bytes()
+}
+
+func tsresol(v byte) []byte {
+	return []byte{pcapngTSResol, 0, 1, 0, v, 0, 0, 0}
+}
+
+func TestCaptureReader(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	f1 := ethernetFrame(udpDatagram(testCli4, testSrv4, []byte{1}))
+	f2 := ethernetFrame(udpDatagram(testSrv4, testCli4, []byte{2}))
+	micros := func(i int) uint64 {
+		return uint64(start.Add(time.Duration(i)*time.Millisecond).UnixNano() / 1000)
+	}
+	cases := []struct {
+		name  string
+		data  []byte
+		times []time.Time
+		err   bool
+	}{
+		{
+			name:  "pcap le",
+			data:  writePcap(binary.LittleEndian, linkEthernet, start, f1, f2),
+			times: []time.Time{start, start.Add(time.Millisecond)},
+		},
+		{
+			name:  "pcap be",
+			data:  writePcap(binary.BigEndian, linkEthernet, start, f1, f2),
+			times: []time.Time{start, start.Add(time.Millisecond)},
+		},
+		{
+			name:  "pcapng",
+			data:  writePcapng(linkEthernet, nil, micros, f1, f2),
+			times: []time.Time{start, start.Add(time.Millisecond)},
+		},
+		{
+			name: "pcapng nanoseconds",
+			data: writePcapng(linkEthernet, tsresol(9), func(i int) uint64 {
+				return uint64(start.Add(time.Duration(i) * time.Millisecond).UnixNano())
+			}, f1, f2),
+			times: []time.Time{start, start.Add(time.Millisecond)},
+		},
+		{
+			name: "pcapng binary resolution",
+			data: writePcapng(linkEthernet, tsresol(0x80|62), func(i int) uint64 {
+				return 3<<61 + uint64(i)<<60 // 1.5s + i*0.25s
+			}, f1, f2),
+			times: []time.Time{time.Unix(1, 500_000_000), time.Unix(1, 750_000_000)},
+		},
+		{
+			name:  "pcapng invalid resolution",
+			data:  writePcapng(linkEthernet, tsresol(0xff), micros, f1, f2),
+			times: []time.Time{start, start.Add(time.Millisecond)},
+		},
+		{
+			name: "unknown format",
+			data: []byte("not a capture file"),
+			err:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := newCaptureReader(bytes.NewReader(c.data))
+			if c.err {
+				must.Error(t, err)
+				return
+			}
+			must.NoError(t, err)
+			for i, exp := range [][]byte{f1, f2} {
+				f, err := r.Next()
+				must.NoError(t, err)
+				must.EqOp(t, uint32(linkEthernet), f.Link)
+				must.Eq(t, exp, f.Data)
+				must.True(t, c.times[i].Equal(f.Time), must.Sprintf("%v != %v", c.times[i], f.Time))
+			}
+			_, err = r.Next()
+			must.EqOp(t, io.EOF, err)
+		})
+	}
+}
+
+func TestReadIDBTruncated(t *testing.T) {
+	p := &pcapngReader{order: binary.LittleEndian}
+	// the last option is not padded
+	err := p.readIDB([]byte{linkEthernet, 0, 0, 0, 0, 0, 0, 0, 2, 0, 3, 0, 'a', 'b', 'c'})
+	must.NoError(t, err)
+	must.Len(t, 1, p.ifaces)
+	must.EqOp(t, uint32(linkEthernet), p.ifaces[0].link)
+}
+
+func TestReadCapture(t *testing.T) {
+	const key = 0x5a
+	accept, err := netmsg.Append([]byte{0x80, 0x00}, &noxnet.MsgAccept{ID: 1})
+	must.NoError(t, err)
+	accept, err = netmsg.Append(accept, &noxnet.MsgServerAccept{ID: 1, XorKey: key})
+	must.NoError(t, err)
+	ping := []byte{0x01, 0x00, byte(netmsg.MSG_TIMESTAMP), 1, 0}
+	encrypted := bytes.Clone(ping)
+	for i := range encrypted {
+		encrypted[i] ^= key
+	}
+
+	start := time.Unix(100, 0)
+	data := writePcap(binary.LittleEndian, linkEthernet, start,
+		ethernetFrame(udpDatagram(testCli4, testSrv4, []byte{0x00, 0x00, byte(netmsg.MSG_SERVER_CONNECT)})),
+		ethernetFrame(udpDatagram(testSrv4, testCli4, accept)),
+		ethernetFrame(udpDatagram(testSrv4, testCli4, encrypted)),
+		ethernetFrame(udpDatagram(testCli4, netip.MustParseAddrPort("10.0.0.3:53"), []byte{1})),
+	)
+	var got []RecordIn
+	err = readCapture(bytes.NewReader(data), testSrv4.Port(), func(r RecordIn) error {
+		got = append(got, r)
+		return nil
+	})
+	must.NoError(t, err)
+	must.Len(t, 3, got)
+	must.EqOp(t, "CLI1", got[0].Src)
+	must.EqOp(t, "SRV", got[0].Dst)
+	must.EqOp(t, hex.EncodeToString(accept), got[1].Data)
+	must.EqOp(t, "SRV", got[2].Src)
+	must.EqOp(t, "CLI1", got[2].Dst)
+	must.EqOp(t, hex.EncodeToString(ping), got[2].Data)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/opennox/libs/log"
+	"github.com/opennox/libs/noxnet"
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+const reliableFlag = 0x80
+
+// Direction of a packet passing through the proxy.
+type Direction int
+
+const (
+	ToServer = Direction(iota)
+	ToClient
+)
+
+func (d Direction) String() string {
+	switch d {
+	case ToServer:
+		return "server"
+	case ToClient:
+		return "client"
+	default:
+		return fmt.Sprintf("Direction(%d)", int(d))
+	}
+}
+
+// Packet is a datagram decoded by the proxy.
+type Packet struct {
+	Client uint32 // proxy client ID
+	Dir    Direction
+	Hdr    [2]byte
+	Msgs   []netmsg.Message
+}
+
+// Reliable checks if the packet is sent reliably.
+func (p *Packet) Reliable() bool {
+	return p.Hdr[0]&reliableFlag != 0
+}
+
+func (p *Packet) encode(st *netmsg.State) ([]byte, error) {
+	out := append([]byte{}, p.Hdr[:]...)
+	for _, m := range p.Msgs {
+		var err error
+		out, err = st.Append(out, m)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode %v: %w", m.NetOp(), err)
+		}
+	}
+	return out, nil
+}
+
+// Action is returned by the Hook to tell the proxy what to do with the packet.
+type Action int
+
+const (
+	// Pass forwards the packet unchanged.
+	Pass = Action(iota)
+	// Modified forwards the packet after encoding messages again.
+	Modified
+	// Drop drops the whole packet.
+	//
+	// Note that dropping reliable packets breaks the stream, since they will be resent forever.
+	// Consider removing messages from the packet instead.
+	Drop
+)
+
+// Hook inspects and rewrites packets passing through the proxy.
+//
+// Hooks are called in the order they were added, each one sees changes made by previous hooks.
+// Modified packets are encoded again only after all the hooks are called.
+type Hook func(p *Packet) Action
+
+// AddHook adds a hook for packets passing through the proxy. It must be called before Serve.
+func (p *Proxy) AddHook(h Hook) {
+	p.hooks = append(p.hooks, h)
+}
+
+// Inject sends messages to the client or to the server on behalf of the other side.
+//
+// Messages are sent in a separate unreliable packet on the stream of the last unreliable packet seen in that direction.
+func (p *Proxy) Inject(id uint32, dir Direction, msgs ...netmsg.Message) error {
+	var c *clientPort
+	p.cmu.RLock()
+	for _, c2 := range p.clients {
+		if c2.id == id {
+			c = c2
+			break
+		}
+	}
+	p.cmu.RUnlock()
+	if c == nil {
+		return fmt.Errorf("no client with id %d", id)
+	}
+	return c.Inject(dir, msgs...)
+}
+
+// Inject sends messages to the client or to the server on behalf of the other side.
+func (c *clientPort) Inject(dir Direction, msgs ...netmsg.Message) error {
+	c.smu.Lock()
+	hdr, ok := c.hdr[dir], c.hasHdr[dir]
+	c.smu.Unlock()
+	if !ok {
+		return fmt.Errorf("no packets sent to %v yet", dir)
+	}
+	pk := &Packet{Client: c.id, Dir: dir, Hdr: hdr, Msgs: msgs}
+	var st netmsg.State
+	st.IsClient = dir == ToClient
+	data, err := pk.encode(&st)
+	if err != nil {
+		return err
+	}
+	if dir == ToClient {
+		return c.p.sendToClient(c.id, c.realCli, data)
+	}
+	c.p.recordPacket(c.id, 0, data)
+	return c.SendToServer(data)
+}
+
+// process decodes the packet and passes it through the hook chain. It returns nil if the packet must be dropped.
+//
+// If there are no hooks and decoding is not requested, the packet is passed without changes.
+func (c *clientPort) process(dir Direction, data []byte) []byte {
+	hooks := c.p.hooks
+	if (len(hooks) == 0 && !*fDecode) || len(data) < 2 {
+		return data
+	}
+	pk := &Packet{Client: c.id, Dir: dir, Hdr: [2]byte(data[:2])}
+	st := &c.state[dir]
+	for left := data[2:]; len(left) > 0; {
+		m, n, err := st.DecodeNext(left)
+		if err != nil {
+			log.Printf("CLI%d: cannot decode packet to %v: %v: %x", c.id, dir, err, data)
+			return data
+		}
+		pk.Msgs = append(pk.Msgs, m)
+		left = left[n:]
+	}
+	if !pk.Reliable() {
+		c.smu.Lock()
+		c.hdr[dir], c.hasHdr[dir] = pk.Hdr, true
+		c.smu.Unlock()
+	}
+	if *fDecode {
+		for _, m := range pk.Msgs {
+			log.Printf("CLI%d (to %v): %v: %+v", c.id, dir, m.NetOp(), m)
+		}
+	}
+	act := Pass
+	for _, h := range hooks {
+		switch h(pk) {
+		case Drop:
+			log.Printf("CLI%d: dropped packet to %v: %x", c.id, dir, data)
+			return nil
+		case Modified:
+			act = Modified
+		}
+	}
+	if act == Pass {
+		return data
+	}
+	out, err := pk.encode(st)
+	if err != nil {
+		log.Printf("CLI%d: %v", c.id, err)
+		return data
+	}
+	return out
+}
+
+// parseOp parses message opcode by its name or number.
+func parseOp(s string) (netmsg.Op, error) {
+	if v, err := strconv.ParseUint(s, 0, 8); err == nil {
+		return netmsg.Op(v), nil
+	}
+	for i := range 256 {
+		if op := netmsg.Op(i); op.String() == s {
+			return op, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown message: %q", s)
+}
+
+// Rule is a simple rewrite hook that can be loaded from a file.
+type Rule struct {
+	// Dir limits the rule to packets sent to the "client" or to the "server". Empty value matches both.
+	Dir string `yaml:"dir,omitempty"`
+	// Op is the message name or number the rule applies to.
+	Op string `yaml:"op"`
+	// Drop removes the message from the packet.
+	Drop bool `yaml:"drop,omitempty"`
+	// Text replaces the text of MSG_TEXT_MESSAGE.
+	Text *string `yaml:"text,omitempty"`
+	// Inject is a hex-encoded sequence of raw messages which are added after the matched message.
+	Inject string `yaml:"inject,omitempty"`
+}
+
+// Hook compiles the rule to a hook.
+func (r *Rule) Hook() (Hook, error) {
+	op, err := parseOp(r.Op)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []Direction
+	switch r.Dir {
+	case "":
+		dirs = []Direction{ToServer, ToClient}
+	case "server":
+		dirs = []Direction{ToServer}
+	case "client":
+		dirs = []Direction{ToClient}
+	default:
+		return nil, fmt.Errorf("unsupported direction: %q", r.Dir)
+	}
+	if r.Text != nil && op != netmsg.MSG_TEXT_MESSAGE {
+		return nil, fmt.Errorf("text can only be set for %v", netmsg.MSG_TEXT_MESSAGE)
+	}
+	var inject []netmsg.Message
+	if r.Inject != "" {
+		data, err := hex.DecodeString(r.Inject)
+		if err != nil {
+			return nil, err
+		}
+		var st netmsg.State
+		st.IsClient = r.Dir == "client"
+		for len(data) > 0 {
+			m, n, err := st.DecodeNext(data)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode injected message: %w", err)
+			}
+			inject = append(inject, m)
+			data = data[n:]
+		}
+	}
+	if !r.Drop && r.Text == nil && len(inject) == 0 {
+		return nil, errors.New("rule has no actions")
+	}
+	return func(p *Packet) Action {
+		if !slices.Contains(dirs, p.Dir) {
+			return Pass
+		}
+		act := Pass
+		for i := 0; i < len(p.Msgs); i++ {
+			m := p.Msgs[i]
+			if m.NetOp() != op {
+				continue
+			}
+			act = Modified
+			if r.Drop {
+				p.Msgs = slices.Delete(p.Msgs, i, i+1)
+				i--
+				continue
+			}
+			if t, ok := m.(*noxnet.MsgText); ok && r.Text != nil {
+				t.SetText(*r.Text)
+			}
+			if len(inject) != 0 {
+				p.Msgs = slices.Insert(p.Msgs, i+1, inject...)
+				i += len(inject)
+			}
+		}
+		if act == Modified && len(p.Msgs) == 0 && !p.Reliable() {
+			return Drop
+		}
+		return act
+	}, nil
+}
+
+// ReadRules reads rewrite rules from a YAML file.
+func ReadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err = yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// addRules loads rules from a file and adds them as hooks to the proxy.
+func (p *Proxy) addRules(path string) error {
+	rules, err := ReadRules(path)
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		h, err := rules[i].Hook()
+		if err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+		p.AddHook(h)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/noxnet"
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func textMsg(s string) *noxnet.MsgText {
+	m := &noxnet.MsgText{Flags: noxnet.TextUTF8}
+	m.SetText(s)
+	return m
+}
+
+func TestRuleHook(t *testing.T) {
+	str := func(s string) *string { return &s }
+	ts, err := netmsg.Append(nil, &noxnet.MsgTimestamp{T: 5})
+	must.NoError(t, err)
+	cases := []struct {
+		name string
+		rule Rule
+		pk   Packet
+		act  Action
+		exp  []netmsg.Message
+	}{
+		{
+			name: "drop",
+			rule: Rule{Op: "MSG_TEXT_MESSAGE", Drop: true},
+			pk:   Packet{Msgs: []netmsg.Message{&noxnet.MsgTimestamp{T: 1}, textMsg("a")}},
+			act:  Modified,
+			exp:  []netmsg.Message{&noxnet.MsgTimestamp{T: 1}},
+		},
+		{
+			name: "drop all",
+			rule: Rule{Op: "168", Drop: true},
+			pk:   Packet{Msgs: []netmsg.Message{textMsg("a"), textMsg("b")}},
+			act:  Drop,
+			exp:  []netmsg.Message{},
+		},
+		{
+			name: "drop all reliable",
+			rule: Rule{Op: "168", Drop: true},
+			pk:   Packet{Hdr: [2]byte{reliableFlag, 1}, Msgs: []netmsg.Message{textMsg("a")}},
+			act:  Modified,
+			exp:  []netmsg.Message{},
+		},
+		{
+			name: "text",
+			rule: Rule{Op: "MSG_TEXT_MESSAGE", Text: str("bye")},
+			pk:   Packet{Msgs: []netmsg.Message{textMsg("hello")}},
+			act:  Modified,
+			exp:  []netmsg.Message{textMsg("bye")},
+		},
+		{
+			name: "inject",
+			rule: Rule{Dir: "client", Op: "MSG_TEXT_MESSAGE", Inject: hex.EncodeToString(ts)},
+			pk:   Packet{Dir: ToClient, Msgs: []netmsg.Message{textMsg("a"), textMsg("b")}},
+			act:  Modified,
+			exp: []netmsg.Message{
+				textMsg("a"), &noxnet.MsgTimestamp{T: 5},
+				textMsg("b"), &noxnet.MsgTimestamp{T: 5},
+			},
+		},
+		{
+			name: "other dir",
+			rule: Rule{Dir: "server", Op: "MSG_TEXT_MESSAGE", Drop: true},
+			pk:   Packet{Dir: ToClient, Msgs: []netmsg.Message{textMsg("a")}},
+			act:  Pass,
+			exp:  []netmsg.Message{textMsg("a")},
+		},
+		{
+			name: "other op",
+			rule: Rule{Op: "MSG_TIMESTAMP", Drop: true},
+			pk:   Packet{Msgs: []netmsg.Message{textMsg("a")}},
+			act:  Pass,
+			exp:  []netmsg.Message{textMsg("a")},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h, err := c.rule.Hook()
+			must.NoError(t, err)
+			pk := c.pk
+			must.EqOp(t, c.act, h(&pk))
+			must.Eq(t, c.exp, pk.Msgs)
+		})
+	}
+}
+
+func TestRuleHookErrors(t *testing.T) {
+	text := "a"
+	for _, r := range []Rule{
+		{Op: "MSG_UNKNOWN_NAME", Drop: true},
+		{Op: "MSG_TEXT_MESSAGE", Dir: "both", Drop: true},
+		{Op: "MSG_TIMESTAMP", Text: &text},
+		{Op: "MSG_TEXT_MESSAGE", Inject: "zz"},
+		{Op: "MSG_TEXT_MESSAGE"},
+	} {
+		_, err := r.Hook()
+		must.Error(t, err, must.Sprintf("%+v", r))
+	}
+}
+
+func listenLocal(t testing.TB) *net.UDPConn {
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	must.NoError(t, err)
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+	return c
+}
+
+func readPacket(t testing.TB, c *net.UDPConn) ([]byte, netip.AddrPort) {
+	t.Helper()
+	var buf [4096]byte
+	err := c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	must.NoError(t, err)
+	n, addr, err := c.ReadFromUDPAddrPort(buf[:])
+	must.NoError(t, err)
+	return buf[:n], addr
+}
+
+func TestProxyInject(t *testing.T) {
+	srv := listenLocal(t)
+	cli := listenLocal(t)
+	lis := listenLocal(t)
+
+	p := NewProxy(netip.MustParseAddrPort(srv.LocalAddr().String()))
+	t.Cleanup(func() {
+		_ = p.Close()
+	})
+	p.AddHook(func(p *Packet) Action { return Pass })
+	go p.Serve(lis)
+
+	err := p.Inject(1, ToServer, textMsg("early"))
+	must.Error(t, err)
+
+	// unreliable packets in both directions, so the proxy learns stream headers
+	hdrCli := []byte{0x01, 0x05}
+	_, err = cli.WriteTo(append(hdrCli, byte(netmsg.MSG_TIMESTAMP), 1, 0), lis.LocalAddr())
+	must.NoError(t, err)
+	_, from := readPacket(t, srv)
+
+	hdrSrv := []byte{0x00, 0x07}
+	_, err = srv.WriteTo(append(hdrSrv, byte(netmsg.MSG_TIMESTAMP), 2, 0), net.UDPAddrFromAddrPort(from))
+	must.NoError(t, err)
+	readPacket(t, cli)
+
+	err = p.Inject(1, ToServer, textMsg("to server"))
+	must.NoError(t, err)
+	data, _ := readPacket(t, srv)
+	exp, err := netmsg.Append(hdrCli, textMsg("to server"))
+	must.NoError(t, err)
+	must.Eq(t, exp, data)
+
+	err = p.Inject(1, ToClient, textMsg("to client"))
+	must.NoError(t, err)
+	data, _ = readPacket(t, cli)
+	exp, err = netmsg.Append(hdrSrv, textMsg("to client"))
+	must.NoError(t, err)
+	must.Eq(t, exp, data)
+
+	err = p.Inject(2, ToClient, textMsg("no client"))
+	must.Error(t, err)
+}
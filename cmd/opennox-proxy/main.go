@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/opennox/libs/log"
 	"github.com/opennox/libs/noxnet"
@@ -24,6 +25,14 @@ var (
 	fServer = flag.String("server", "127.0.0.1:18590", "server address to proxy requests to")
 	fHost   = flag.String("host", "0.0.0.0:18600", "address to host proxy on")
 	fFile   = flag.String("file", "", "file name to dump messages to")
+	fDecode = flag.Bool("decode", false, "decode and log messages passing through the proxy")
+	fRules  = flag.String("rules", "", "YAML file with message rewrite rules")
+
+	fReplay = flag.String("replay", "", "recording to replay instead of running the proxy")
+	fSide   = flag.String("side", "server", "side of the recording to replay: server (to real clients on host) or client (to a real server)")
+	fStop   = flag.Bool("stop", false, "stop the replay at the first packet that differs from the recording")
+	fExact  = flag.Bool("exact", false, "compare packet payloads exactly during the replay")
+	fWait   = flag.Duration("wait", 5*time.Second, "time to wait for each expected packet during the replay")
 )
 
 func main() {
@@ -35,12 +44,20 @@ func main() {
 }
 
 func run() error {
+	if *fReplay != "" {
+		return runReplay()
+	}
 	srv, err := netip.ParseAddrPort(*fServer)
 	if err != nil {
 		return err
 	}
 	p := NewProxy(srv)
 	defer p.Close()
+	if *fRules != "" {
+		if err = p.addRules(*fRules); err != nil {
+			return err
+		}
+	}
 	log.Printf("serving proxy %v -> %v", *fHost, srv)
 	return p.ListenAndServe(*fHost)
 }
@@ -56,6 +73,7 @@ func NewProxy(srv netip.AddrPort) *Proxy {
 type Proxy struct {
 	realSrv  netip.AddrPort
 	clientID uint32 // atomic
+	hooks    []Hook
 
 	emu   sync.Mutex
 	efile *os.File
@@ -146,8 +164,12 @@ func (p *Proxy) sendAsClient(realCli netip.AddrPort, data []byte) {
 		log.Printf("cannot host client %v: %v", realCli, err)
 		return
 	}
-	p.recordPacket(c.id, 0, data)
 	log.Printf("CLI%d(%v) -> SP(%v): [%d]: %x", c.id, realCli, p.lis.LocalAddr(), len(data), data)
+	data = c.process(ToServer, data)
+	if len(data) == 0 {
+		return
+	}
+	p.recordPacket(c.id, 0, data)
 	err = c.SendToServer(data)
 	if err != nil {
 		log.Printf("cannot send client %v packet: %v", realCli, err)
@@ -167,11 +189,13 @@ func (p *Proxy) sendToClient(id uint32, addr netip.AddrPort, data []byte) error
 
 func (p *Proxy) newClient(addr netip.AddrPort) *clientPort {
 	id := atomic.AddUint32(&p.clientID, 1)
-	return &clientPort{
+	c := &clientPort{
 		id:      id,
 		p:       p,
 		realCli: addr,
 	}
+	c.state[ToClient].IsClient = true
+	return c
 }
 
 type clientPort struct {
@@ -180,6 +204,13 @@ type clientPort struct {
 	realCli netip.AddrPort
 	xor     uint32 // atomic
 
+	// decoder state for each direction, only accessed by the goroutine serving that direction
+	state [2]netmsg.State
+
+	smu    sync.Mutex
+	hdr    [2][2]byte // last unreliable packet header for each direction
+	hasHdr [2]bool
+
 	wmu sync.Mutex
 	lis *net.UDPConn
 }
@@ -212,6 +243,7 @@ func (c *clientPort) serve() {
 			xorData(xor, data)
 		}
 		data = c.interceptServer(data)
+		data = c.process(ToClient, data)
 		if len(data) == 0 {
 			continue
 		}
@@ -253,42 +285,9 @@ func (c *clientPort) interceptServer(data []byte) []byte {
 			})
 		}
 	} else if data[0] == 0x80 && data[1] == 0 {
-		switch netmsg.Op(data[2]) {
-		case netmsg.MSG_SERVER_ACCEPT:
-			return modifyMessage(data, func(p *noxnet.MsgServerAccept) {
-				atomic.StoreUint32(&c.xor, uint32(p.XorKey))
-				p.XorKey = 0
-			})
-		case netmsg.MSG_ACCEPTED:
-			var accept noxnet.MsgAccept
-			left := data[2:]
-			n, err := accept.Decode(left[1:])
-			if err != nil {
-				return data
-			}
-			left = left[1+n:]
-
-			if len(left) == 0 || netmsg.Op(left[0]) != netmsg.MSG_SERVER_ACCEPT {
-				return data
-			}
-			var saccept noxnet.MsgServerAccept
-			n, err = saccept.Decode(left[1:])
-			if err != nil {
-				return data
-			}
-			atomic.StoreUint32(&c.xor, uint32(saccept.XorKey))
-			saccept.XorKey = 0
-
-			out := append([]byte{}, data[0], data[1])
-			out, err = netmsg.Append(out, &accept)
-			if err != nil {
-				return data
-			}
-			out, err = netmsg.Append(out, &saccept)
-			if err != nil {
-				return data
-			}
-			return out
+		// disable encryption for the client, proxy will handle it
+		if key, ok := noxnet.ClearServerXorKey(data); ok {
+			atomic.StoreUint32(&c.xor, uint32(key))
 		}
 	}
 	return data
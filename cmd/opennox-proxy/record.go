@@ -5,14 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 )
 
 type Record struct {
-	SrcID uint32 `json:"src_id"`
-	DstID uint32 `json:"dst_id"`
-	Src   string `json:"src"`
-	Dst   string `json:"dst"`
-	Data  string `json:"data"`
+	Time  time.Time `json:"time"`
+	SrcID uint32    `json:"src_id"`
+	DstID uint32    `json:"dst_id"`
+	Src   string    `json:"src"`
+	Dst   string    `json:"dst"`
+	Data  string    `json:"data"`
 }
 
 func srcName(id uint32) string {
@@ -37,6 +39,7 @@ func (p *Proxy) recordPacket(src, dst uint32, data []byte) {
 		p.enc = json.NewEncoder(f)
 	}
 	p.enc.Encode(&Record{
+		Time:  time.Now(),
 		SrcID: src, Src: srcName(src),
 		DstID: dst, Dst: srcName(dst),
 		Data: hex.EncodeToString(data),
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/opennox/libs/log"
+	"github.com/opennox/libs/noxnet"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+// ReadRecords reads all packet records from a JSONL file written by the proxy.
+func ReadRecords(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	var out []Record
+	for {
+		var r Record
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return out, err
+		}
+		out = append(out, r)
+	}
+}
+
+func runReplay() error {
+	recs, err := ReadRecords(*fReplay)
+	if err != nil {
+		return err
+	} else if len(recs) == 0 {
+		return errors.New("no records to replay")
+	}
+	opts := ReplayOptions{Stop: *fStop, Exact: *fExact, Wait: *fWait}
+	var r *Replayer
+	switch *fSide {
+	case "server":
+		host, err := netip.ParseAddrPort(*fHost)
+		if err != nil {
+			return err
+		}
+		log.Printf("replaying server side of %q on %v", *fReplay, host)
+		r, err = ReplayServer(host, recs, opts)
+		if err != nil {
+			return err
+		}
+	case "client":
+		srv, err := netip.ParseAddrPort(*fServer)
+		if err != nil {
+			return err
+		}
+		log.Printf("replaying client side of %q to %v", *fReplay, srv)
+		r, err = ReplayClient(srv, recs, opts)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported replay side: %q", *fSide)
+	}
+	defer r.Close()
+	return r.Run(context.Background())
+}
+
+// ReplayOptions controls how recorded packets are compared with real ones.
+type ReplayOptions struct {
+	// Stop the replay at the first packet that differs from the recording.
+	Stop bool
+	// Exact compares packet payloads byte-by-byte. Otherwise, only stream IDs, reliable flags and the first opcode are compared.
+	Exact bool
+	// Wait is the time to wait for each expected packet.
+	Wait time.Duration
+}
+
+// DiffError is returned when a packet received during the replay differs from the recording.
+type DiffError struct {
+	Index int
+	Rec   Record
+	Got   []byte // nil if the packet wasn't received
+}
+
+func (e *DiffError) Error() string {
+	if e.Got == nil {
+		return fmt.Sprintf("record %d: %s -> %s: packet not received: %s", e.Index, e.Rec.Src, e.Rec.Dst, e.Rec.Data)
+	}
+	return fmt.Sprintf("record %d: %s -> %s: expected %s, got %x", e.Index, e.Rec.Src, e.Rec.Dst, e.Rec.Data, e.Got)
+}
+
+// replayPeer is a real remote host that replaces one of the recorded clients.
+type replayPeer struct {
+	id    uint32
+	conn  *net.UDPConn
+	ready chan struct{} // closed when addr is known
+	addr  netip.AddrPort
+	recv  chan []byte
+
+	mu  sync.Mutex
+	xor byte
+}
+
+func newReplayPeer(id uint32) *replayPeer {
+	return &replayPeer{
+		id:    id,
+		ready: make(chan struct{}),
+		recv:  make(chan []byte, 256),
+	}
+}
+
+func (p *replayPeer) xorKey() byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.xor
+}
+
+func (p *replayPeer) setXorKey(key byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.xor = key
+}
+
+func (p *replayPeer) push(data []byte) {
+	select {
+	case p.recv <- bytes.Clone(data):
+	default:
+		log.Printf("replay: %s: dropping packet, queue is full", srcName(p.id))
+	}
+}
+
+// Replayer plays one side of a proxy recording to real clients or a real server.
+type Replayer struct {
+	server bool // play the server side
+	opts   ReplayOptions
+	recs   []Record
+	conn   *net.UDPConn // server side only
+	peers  map[uint32]*replayPeer
+	order  []*replayPeer // in order of the first appearance
+}
+
+func newReplayer(recs []Record, opts ReplayOptions) *Replayer {
+	if opts.Wait <= 0 {
+		opts.Wait = 5 * time.Second
+	}
+	r := &Replayer{
+		opts:  opts,
+		recs:  recs,
+		peers: make(map[uint32]*replayPeer),
+	}
+	for _, rec := range recs {
+		id := rec.SrcID
+		if id == 0 {
+			id = rec.DstID
+		}
+		if id == 0 || r.peers[id] != nil {
+			continue
+		}
+		p := newReplayPeer(id)
+		r.peers[id] = p
+		r.order = append(r.order, p)
+	}
+	return r
+}
+
+// ReplayServer plays the server side of the recording to real clients connecting to the host address.
+// Clients are matched with recorded ones in the order they send their first packet.
+func ReplayServer(host netip.AddrPort, recs []Record, opts ReplayOptions) (*Replayer, error) {
+	r := newReplayer(recs, opts)
+	r.server = true
+	conn, err := udpconn.Listen(host)
+	if err != nil {
+		return nil, err
+	}
+	r.conn = conn
+	go r.serveClients()
+	return r, nil
+}
+
+// ReplayClient plays the client side of the recording to a real server.
+// Each recorded client gets a separate port.
+func ReplayClient(srv netip.AddrPort, recs []Record, opts ReplayOptions) (*Replayer, error) {
+	r := newReplayer(recs, opts)
+	for _, p := range r.order {
+		conn, err := udpconn.Listen(netip.AddrPort{})
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		p.conn = conn
+		p.addr = srv
+		close(p.ready)
+		log.Printf("replay: %s uses %v", srcName(p.id), conn.LocalAddr())
+		go r.serveServer(p)
+	}
+	return r, nil
+}
+
+func (r *Replayer) Close() error {
+	if r.conn != nil {
+		_ = r.conn.Close()
+	}
+	for _, p := range r.order {
+		if p.conn != nil {
+			_ = p.conn.Close()
+		}
+	}
+	return nil
+}
+
+// serveClients accepts packets from real clients and assigns them to recorded ones.
+func (r *Replayer) serveClients() {
+	byAddr := make(map[netip.AddrPort]*replayPeer)
+	next := 0
+	var buf [4096]byte
+	for {
+		n, addr, err := r.conn.ReadFromUDPAddrPort(buf[:])
+		if err != nil {
+			return
+		}
+		addr = udpconn.NormalizeAddr(addr)
+		p := byAddr[addr]
+		if p == nil {
+			if next >= len(r.order) {
+				log.Printf("replay: ignoring packet from unknown client %v: %x", addr, buf[:n])
+				continue
+			}
+			p = r.order[next]
+			next++
+			p.addr = addr
+			byAddr[addr] = p
+			close(p.ready)
+			log.Printf("replay: %v is %s", addr, srcName(p.id))
+		}
+		p.push(buf[:n])
+	}
+}
+
+// serveServer accepts packets from the real server for a given recorded client.
+func (r *Replayer) serveServer(p *replayPeer) {
+	var buf [4096]byte
+	for {
+		n, addr, err := p.conn.ReadFromUDPAddrPort(buf[:])
+		if err != nil {
+			return
+		}
+		data := buf[:n]
+		if udpconn.NormalizeAddr(addr) != udpconn.NormalizeAddr(p.addr) {
+			log.Printf("replay: ignoring packet from %v: %x", addr, data)
+			continue
+		}
+		if xor := p.xorKey(); xor != 0 {
+			xorData(xor, data)
+		}
+		if key, ok := noxnet.ServerXorKey(data); ok {
+			p.setXorKey(key)
+		}
+		p.push(data)
+	}
+}
+
+func (r *Replayer) send(p *replayPeer, data []byte) error {
+	data = bytes.Clone(data)
+	if r.server {
+		_, err := r.conn.WriteToUDPAddrPort(data, p.addr)
+		return err
+	}
+	if xor := p.xorKey(); xor != 0 {
+		xorData(xor, data)
+	}
+	_, err := p.conn.WriteToUDPAddrPort(data, p.addr)
+	return err
+}
+
+// Run plays the recording until the end.
+//
+// Packets of the played side are sent with the same delays as in the recording, relative to the last
+// packet received from the real peer. Packets of the other side are expected from the real peer in the recorded order.
+func (r *Replayer) Run(ctx context.Context) error {
+	var (
+		start = time.Now()
+		t0    time.Time
+		diffs int
+	)
+	for i, rec := range r.recs {
+		data, err := hex.DecodeString(rec.Data)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		fromServer := rec.SrcID == 0
+		id := rec.SrcID
+		if fromServer {
+			id = rec.DstID
+		}
+		p := r.peers[id]
+		if p == nil {
+			continue
+		}
+		if fromServer != r.server {
+			got, err := r.expect(ctx, p)
+			if err != nil {
+				return err
+			}
+			start, t0 = time.Now(), rec.Time
+			if got != nil && samePacket(data, got, r.opts.Exact) {
+				continue
+			}
+			derr := &DiffError{Index: i, Rec: rec, Got: got}
+			if r.opts.Stop {
+				return derr
+			}
+			log.Printf("replay: %v", derr)
+			diffs++
+			continue
+		}
+		if !rec.Time.IsZero() {
+			if t0.IsZero() {
+				t0 = rec.Time
+			}
+			if dt := time.Until(start.Add(rec.Time.Sub(t0))); dt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(dt):
+				}
+			}
+		}
+		if err := r.waitReady(ctx, p); err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+		if err := r.send(p, data); err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	if diffs != 0 {
+		return fmt.Errorf("%d packets differ from the recording", diffs)
+	}
+	log.Printf("replay: done, %d records", len(r.recs))
+	return nil
+}
+
+func (r *Replayer) waitReady(ctx context.Context, p *replayPeer) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ready:
+		return nil
+	case <-time.After(r.opts.Wait):
+		return fmt.Errorf("%s didn't connect", srcName(p.id))
+	}
+}
+
+// expect waits for the next packet from the peer. It returns nil data on timeout.
+func (r *Replayer) expect(ctx context.Context, p *replayPeer) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case data := <-p.recv:
+		return data, nil
+	case <-time.After(r.opts.Wait):
+		return nil, nil
+	}
+}
+
+// samePacket compares a recorded packet with a real one. Sequence numbers are always ignored.
+func samePacket(exp, got []byte, exact bool) bool {
+	if len(exp) < 2 || len(got) < 2 {
+		return bytes.Equal(exp, got)
+	}
+	if exp[0] != got[0] {
+		return false
+	}
+	exp, got = exp[2:], got[2:]
+	if exact || len(exp) == 0 || len(got) == 0 {
+		return bytes.Equal(exp, got)
+	}
+	return exp[0] == got[0]
+}
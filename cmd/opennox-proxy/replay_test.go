@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func TestSamePacket(t *testing.T) {
+	ts := byte(netmsg.MSG_TIMESTAMP)
+	text := byte(netmsg.MSG_TEXT_MESSAGE)
+	cases := []struct {
+		name  string
+		exp   []byte
+		got   []byte
+		exact bool
+		same  bool
+	}{
+		{name: "equal", exp: []byte{0x01, 0x05, ts, 1, 0}, got: []byte{0x01, 0x05, ts, 1, 0}, same: true},
+		{name: "seq", exp: []byte{0x01, 0x05, ts, 1, 0}, got: []byte{0x01, 0x09, ts, 1, 0}, exact: true, same: true},
+		{name: "stream", exp: []byte{0x01, 0x05, ts, 1, 0}, got: []byte{0x02, 0x05, ts, 1, 0}},
+		{name: "reliable", exp: []byte{0x01, 0x05, ts, 1, 0}, got: []byte{0x81, 0x05, ts, 1, 0}},
+		{name: "op", exp: []byte{0x01, 0x05, ts, 1, 0}, got: []byte{0x01, 0x05, text, 1, 0}},
+		{name: "payload", exp: []byte{0x01, 0x05, ts, 1, 0}, got: []byte{0x01, 0x05, ts, 2, 0}, same: true},
+		{name: "payload exact", exp: []byte{0x01, 0x05, ts, 1, 0}, got: []byte{0x01, 0x05, ts, 2, 0}, exact: true},
+		{name: "header only", exp: []byte{0x01, 0x05}, got: []byte{0x01, 0x06}, same: true},
+		{name: "no payload", exp: []byte{0x01, 0x05}, got: []byte{0x01, 0x05, ts}},
+		{name: "short", exp: []byte{0x01}, got: []byte{0x01}, same: true},
+		{name: "short diff", exp: []byte{0x01}, got: []byte{0x01, 0x05}},
+		{name: "empty", exp: nil, got: []byte{}, same: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			must.EqOp(t, c.same, samePacket(c.exp, c.got, c.exact))
+		})
+	}
+}
+
+var (
+	replayCli1 = []byte{0x01, 0x05, byte(netmsg.MSG_TIMESTAMP), 1, 0}
+	replaySrv  = []byte{0x00, 0x07, byte(netmsg.MSG_TIMESTAMP), 2, 0}
+	replayCli2 = []byte{0x01, 0x06, byte(netmsg.MSG_TIMESTAMP), 3, 0}
+)
+
+func replayRecords() []Record {
+	rec := func(src, dst uint32, data []byte) Record {
+		return Record{
+			SrcID: src, Src: srcName(src),
+			DstID: dst, Dst: srcName(dst),
+			Data: hex.EncodeToString(data),
+		}
+	}
+	return []Record{
+		rec(1, 0, replayCli1),
+		rec(0, 1, replaySrv),
+		rec(1, 0, replayCli2),
+	}
+}
+
+func runReplayer(t testing.TB, r *Replayer) <-chan error {
+	t.Cleanup(func() {
+		_ = r.Close()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- r.Run(ctx)
+	}()
+	return errc
+}
+
+func TestReplayServer(t *testing.T) {
+	r, err := ReplayServer(netip.MustParseAddrPort("127.0.0.1:0"), replayRecords(), ReplayOptions{Exact: true})
+	must.NoError(t, err)
+	errc := runReplayer(t, r)
+	host := r.conn.LocalAddr()
+
+	cli := listenLocal(t)
+	_, err = cli.WriteTo(replayCli1, host)
+	must.NoError(t, err)
+	data, _ := readPacket(t, cli)
+	must.Eq(t, replaySrv, data)
+	_, err = cli.WriteTo(replayCli2, host)
+	must.NoError(t, err)
+	must.NoError(t, <-errc)
+}
+
+func TestReplayClient(t *testing.T) {
+	srv := listenLocal(t)
+	r, err := ReplayClient(netip.MustParseAddrPort(srv.LocalAddr().String()), replayRecords(), ReplayOptions{Exact: true})
+	must.NoError(t, err)
+	errc := runReplayer(t, r)
+
+	data, from := readPacket(t, srv)
+	must.Eq(t, replayCli1, data)
+	_, err = srv.WriteTo(replaySrv, net.UDPAddrFromAddrPort(from))
+	must.NoError(t, err)
+	data, _ = readPacket(t, srv)
+	must.Eq(t, replayCli2, data)
+	must.NoError(t, <-errc)
+}
+
+func TestReplayDiff(t *testing.T) {
+	srv := listenLocal(t)
+	r, err := ReplayClient(netip.MustParseAddrPort(srv.LocalAddr().String()), replayRecords(), ReplayOptions{Stop: true})
+	must.NoError(t, err)
+	errc := runReplayer(t, r)
+
+	_, from := readPacket(t, srv)
+	bad := []byte{0x00, 0x07, byte(netmsg.MSG_TEXT_MESSAGE), 2, 0}
+	_, err = srv.WriteTo(bad, net.UDPAddrFromAddrPort(from))
+	must.NoError(t, err)
+
+	err = <-errc
+	var derr *DiffError
+	must.True(t, errors.As(err, &derr))
+	must.EqOp(t, 1, derr.Index)
+	must.Eq(t, bad, derr.Got)
+	must.Eq(t, replayRecords()[1], derr.Rec)
+}
@@ -192,6 +192,14 @@ type Console struct {
 	}
 }
 
+// SetPrinter replaces the underlying Printer and returns the previous one.
+// It must not be called concurrently with Exec.
+func (cn *Console) SetPrinter(p Printer) Printer {
+	prev := cn.p
+	cn.p = p
+	return prev
+}
+
 // Print exposes underlying Printer.
 func (cn *Console) Print(cl Color, str string) {
 	if cn.p != nil {
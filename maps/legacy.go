@@ -39,6 +39,16 @@ type NativeDownloader struct {
 	last       *mapDownloadPart
 }
 
+// Dir returns the base directory for downloaded maps.
+func (d *NativeDownloader) Dir() string {
+	return d.dir
+}
+
+// Path returns the path of the file being downloaded.
+func (d *NativeDownloader) Path() string {
+	return d.path
+}
+
 func (d *NativeDownloader) WritePart(ind uint, data []byte) {
 	if len(data) == 0 {
 		return
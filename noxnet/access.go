@@ -0,0 +1,367 @@
+package noxnet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AccessRule is a single entry of the AccessList. It matches either a network prefix or a player name.
+type AccessRule struct {
+	// Addr is a network prefix matched by the rule. Single addresses are stored as /32 or /128 prefixes.
+	Addr netip.Prefix
+	// Name is a player name matched by the rule. Names are case-insensitive.
+	Name string
+	// Until is the time when the rule expires. Zero value means the rule is permanent.
+	Until time.Time
+	// Reason is an optional comment for administrators.
+	Reason string
+}
+
+// Expired checks if the rule is no longer active at a given time.
+func (r *AccessRule) Expired(now time.Time) bool {
+	return !r.Until.IsZero() && !now.Before(r.Until)
+}
+
+func (r *AccessRule) matchAddr(ip netip.Addr) bool {
+	return r.Addr.IsValid() && r.Addr.Contains(ip)
+}
+
+func (r *AccessRule) matchName(name string) bool {
+	return r.Name != "" && strings.EqualFold(r.Name, name)
+}
+
+// ParsePrefix parses a network prefix in CIDR notation, or a single IP address.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return p.Masked(), nil
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// NewAccessList creates an empty access list that allows everyone.
+func NewAccessList() *AccessList {
+	return &AccessList{}
+}
+
+// AccessList controls which addresses and player names are allowed to join the server.
+//
+// Bans reject matching addresses and names with ErrBanned. Addresses matching the allow list bypass address bans.
+// If the allow list is exclusive (see SetExclusive), addresses that don't match it are rejected with ErrClosed.
+//
+// AccessList is safe for concurrent use.
+type AccessList struct {
+	mu        sync.RWMutex
+	exclusive bool
+	allow     []AccessRule
+	ban       []AccessRule
+}
+
+// SetExclusive controls if only addresses on the allow list can join.
+func (l *AccessList) SetExclusive(v bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.exclusive = v
+}
+
+// Exclusive checks if only addresses on the allow list can join.
+func (l *AccessList) Exclusive() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.exclusive
+}
+
+// Allow adds a network prefix to the allow list.
+func (l *AccessList) Allow(p netip.Prefix, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.allow = setRule(l.allow, AccessRule{Addr: p.Masked(), Reason: reason})
+}
+
+// Disallow removes a network prefix from the allow list.
+func (l *AccessList) Disallow(p netip.Prefix) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ok bool
+	l.allow, ok = delRule(l.allow, AccessRule{Addr: p.Masked()})
+	return ok
+}
+
+// BanAddr bans a network prefix. If dt is not zero, the ban expires after that time.
+func (l *AccessList) BanAddr(p netip.Prefix, dt time.Duration, reason string) {
+	l.Ban(AccessRule{Addr: p.Masked(), Until: banUntil(dt), Reason: reason})
+}
+
+// BanName bans a player name. If dt is not zero, the ban expires after that time.
+func (l *AccessList) BanName(name string, dt time.Duration, reason string) {
+	l.Ban(AccessRule{Name: name, Until: banUntil(dt), Reason: reason})
+}
+
+// Ban adds a rule to the ban list. An existing ban for the same address or name is replaced.
+func (l *AccessList) Ban(r AccessRule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ban = setRule(l.ban, r)
+}
+
+// UnbanAddr removes a ban for a network prefix. The prefix must match the one used for the ban exactly.
+func (l *AccessList) UnbanAddr(p netip.Prefix) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ok bool
+	l.ban, ok = delRule(l.ban, AccessRule{Addr: p.Masked()})
+	return ok
+}
+
+// UnbanName removes a ban for a player name.
+func (l *AccessList) UnbanName(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var ok bool
+	l.ban, ok = delRule(l.ban, AccessRule{Name: name})
+	return ok
+}
+
+// Allowed returns a copy of the allow list.
+func (l *AccessList) Allowed() []AccessRule {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return slices.Clone(l.allow)
+}
+
+// Banned returns a copy of the active bans.
+func (l *AccessList) Banned() []AccessRule {
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]AccessRule, 0, len(l.ban))
+	for _, r := range l.ban {
+		if !r.Expired(now) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Prune removes expired bans.
+func (l *AccessList) Prune() {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ban = slices.DeleteFunc(l.ban, func(r AccessRule) bool {
+		return r.Expired(now)
+	})
+}
+
+// CheckAddr checks if the address is allowed to join. It returns ErrBanned or ErrClosed otherwise.
+func (l *AccessList) CheckAddr(addr netip.Addr) error {
+	if l == nil {
+		return nil
+	}
+	ip := addr.Unmap()
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, r := range l.allow {
+		if r.matchAddr(ip) {
+			return nil
+		}
+	}
+	if l.exclusive {
+		return ErrClosed
+	}
+	for _, r := range l.ban {
+		if r.matchAddr(ip) && !r.Expired(now) {
+			return ErrBanned
+		}
+	}
+	return nil
+}
+
+// CheckName checks if the player name is allowed to join. It returns ErrBanned otherwise.
+func (l *AccessList) CheckName(name string) error {
+	if l == nil || name == "" {
+		return nil
+	}
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, r := range l.ban {
+		if r.matchName(name) && !r.Expired(now) {
+			return ErrBanned
+		}
+	}
+	return nil
+}
+
+func banUntil(dt time.Duration) time.Time {
+	if dt <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(dt)
+}
+
+func sameRule(a, b *AccessRule) bool {
+	if a.Addr.IsValid() || b.Addr.IsValid() {
+		return a.Addr == b.Addr
+	}
+	return strings.EqualFold(a.Name, b.Name)
+}
+
+func setRule(list []AccessRule, r AccessRule) []AccessRule {
+	for i := range list {
+		if sameRule(&list[i], &r) {
+			list[i] = r
+			return list
+		}
+	}
+	return append(list, r)
+}
+
+func delRule(list []AccessRule, r AccessRule) ([]AccessRule, bool) {
+	n := len(list)
+	list = slices.DeleteFunc(list, func(r2 AccessRule) bool {
+		return sameRule(&r2, &r)
+	})
+	return list, len(list) != n
+}
+
+type ymlAccessRule struct {
+	Addr   string    `yaml:"addr,omitempty"`
+	Name   string    `yaml:"name,omitempty"`
+	Until  time.Time `yaml:"until,omitempty"`
+	Reason string    `yaml:"reason,omitempty"`
+}
+
+type ymlAccessList struct {
+	Exclusive bool            `yaml:"exclusive,omitempty"`
+	Allow     []ymlAccessRule `yaml:"allow,omitempty"`
+	Ban       []ymlAccessRule `yaml:"ban,omitempty"`
+}
+
+func (r *AccessRule) toYML() ymlAccessRule {
+	v := ymlAccessRule{Name: r.Name, Until: r.Until, Reason: r.Reason}
+	if r.Addr.IsValid() {
+		if r.Addr.IsSingleIP() {
+			v.Addr = r.Addr.Addr().String()
+		} else {
+			v.Addr = r.Addr.String()
+		}
+	}
+	return v
+}
+
+func (v *ymlAccessRule) toRule() (AccessRule, error) {
+	r := AccessRule{Name: v.Name, Until: v.Until, Reason: v.Reason}
+	if v.Addr != "" {
+		p, err := ParsePrefix(v.Addr)
+		if err != nil {
+			return r, err
+		}
+		r.Addr = p
+	}
+	if r.Addr.IsValid() == (r.Name != "") {
+		return r, errors.New("rule must have either an address or a name")
+	}
+	return r, nil
+}
+
+// DecodeAccessList reads access list in YAML format.
+func DecodeAccessList(r io.Reader) (*AccessList, error) {
+	var v ymlAccessList
+	if err := yaml.NewDecoder(r).Decode(&v); err != nil && err != io.EOF {
+		return nil, err
+	}
+	l := NewAccessList()
+	l.exclusive = v.Exclusive
+	for i, a := range v.Allow {
+		r, err := a.toRule()
+		if err != nil {
+			return nil, fmt.Errorf("allow %d: %w", i, err)
+		} else if !r.Addr.IsValid() {
+			return nil, fmt.Errorf("allow %d: only addresses are supported", i)
+		}
+		l.allow = setRule(l.allow, r)
+	}
+	for i, b := range v.Ban {
+		r, err := b.toRule()
+		if err != nil {
+			return nil, fmt.Errorf("ban %d: %w", i, err)
+		}
+		l.ban = setRule(l.ban, r)
+	}
+	return l, nil
+}
+
+// Encode writes access list in YAML format. Expired bans are omitted.
+func (l *AccessList) Encode(w io.Writer) error {
+	now := time.Now()
+	l.mu.RLock()
+	v := ymlAccessList{Exclusive: l.exclusive}
+	for _, r := range l.allow {
+		v.Allow = append(v.Allow, r.toYML())
+	}
+	for _, r := range l.ban {
+		if !r.Expired(now) {
+			v.Ban = append(v.Ban, r.toYML())
+		}
+	}
+	l.mu.RUnlock()
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ReadAccessList reads access list from a YAML file.
+func ReadAccessList(path string) (*AccessList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeAccessList(f)
+}
+
+// WriteFile saves access list to a YAML file. The file is replaced atomically.
+func (l *AccessList) WriteFile(path string) error {
+	var buf bytes.Buffer
+	if err := l.Encode(&buf); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,129 @@
+package noxnet
+
+import (
+	"bytes"
+	"context"
+	"net/netip"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+func TestAccessList(t *testing.T) {
+	l := NewAccessList()
+	l.BanAddr(netip.MustParsePrefix("10.1.0.0/16"), 0, "subnet")
+	l.BanAddr(netip.MustParsePrefix("10.2.0.1/32"), -1, "")
+	l.Ban(AccessRule{Addr: netip.MustParsePrefix("10.3.0.1/32"), Until: time.Now().Add(-time.Second)})
+	l.BanName("Cheater", time.Hour, "")
+	l.Allow(netip.MustParsePrefix("10.1.2.3/32"), "friend")
+
+	for _, c := range []struct {
+		addr string
+		err  error
+	}{
+		{"10.1.0.1", ErrBanned},
+		{"::ffff:10.1.200.1", ErrBanned},
+		{"10.1.2.3", nil},
+		{"10.2.0.1", ErrBanned},
+		{"10.2.0.2", nil},
+		{"10.3.0.1", nil}, // expired
+		{"fe80::1", nil},
+	} {
+		t.Run(c.addr, func(t *testing.T) {
+			err := l.CheckAddr(netip.MustParseAddr(c.addr))
+			if c.err == nil {
+				must.NoError(t, err)
+			} else {
+				must.ErrorIs(t, err, c.err)
+			}
+		})
+	}
+	must.ErrorIs(t, l.CheckName("cheater"), ErrBanned)
+	must.NoError(t, l.CheckName("Player"))
+
+	l.SetExclusive(true)
+	must.ErrorIs(t, l.CheckAddr(netip.MustParseAddr("10.2.0.2")), ErrClosed)
+	must.NoError(t, l.CheckAddr(netip.MustParseAddr("10.1.2.3")))
+	l.SetExclusive(false)
+
+	must.True(t, l.UnbanAddr(netip.MustParsePrefix("10.1.0.0/16")))
+	must.False(t, l.UnbanAddr(netip.MustParsePrefix("10.1.0.0/16")))
+	must.NoError(t, l.CheckAddr(netip.MustParseAddr("10.1.0.1")))
+	must.True(t, l.UnbanName("CHEATER"))
+	must.NoError(t, l.CheckName("Cheater"))
+
+	var nl *AccessList
+	must.NoError(t, nl.CheckAddr(netip.MustParseAddr("10.1.0.1")))
+}
+
+func TestAccessListFile(t *testing.T) {
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	l := NewAccessList()
+	l.SetExclusive(true)
+	l.Allow(netip.MustParsePrefix("192.168.0.0/24"), "lan")
+	l.BanAddr(netip.MustParsePrefix("192.168.0.66/32"), 0, "griefing")
+	l.Ban(AccessRule{Addr: netip.MustParsePrefix("2001:db8::/32"), Until: until})
+	l.Ban(AccessRule{Addr: netip.MustParsePrefix("1.2.3.4/32"), Until: time.Now().Add(-time.Second)})
+	l.BanName("Cheater", 0, "")
+
+	path := filepath.Join(t.TempDir(), "access.yml")
+	err := l.WriteFile(path)
+	must.NoError(t, err)
+
+	l2, err := ReadAccessList(path)
+	must.NoError(t, err)
+	must.True(t, l2.Exclusive())
+	must.Eq(t, l.Allowed(), l2.Allowed())
+	must.Eq(t, []AccessRule{
+		{Addr: netip.MustParsePrefix("192.168.0.66/32"), Reason: "griefing"},
+		{Addr: netip.MustParsePrefix("2001:db8::/32"), Until: until},
+		{Name: "Cheater"},
+	}, l2.Banned())
+
+	_, err = DecodeAccessList(bytes.NewBufferString("ban:\n  - addr: 1.2.3\n"))
+	must.Error(t, err)
+	_, err = DecodeAccessList(bytes.NewBufferString("ban:\n  - reason: empty\n"))
+	must.Error(t, err)
+	l3, err := DecodeAccessList(bytes.NewBufferString(""))
+	must.NoError(t, err)
+	must.SliceEmpty(t, l3.Banned())
+}
+
+func TestAccessJoin(t *testing.T) {
+	var connects atomic.Int32
+	e := &testEngine{
+		t: t,
+		OnTry: func(req *MsgServerTryJoin) error {
+			return nil
+		},
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			connects.Add(1)
+			return &testPlayer{id: 1}, nil
+		},
+	}
+	access := NewAccessList()
+	srv, cli := newServerAndClientIP(t, e, netip.Addr{}, netip.Addr{}, &ServerOptions{Access: access})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*resendTick)
+	defer cancel()
+	addr := srv.LocalAddr()
+
+	err := cli.TryJoin(ctx, addr, MsgServerTryJoin{PlayerName: "Player"})
+	must.NoError(t, err)
+
+	access.BanName("player", 0, "")
+	err = cli.TryJoin(ctx, addr, MsgServerTryJoin{PlayerName: "Player"})
+	must.ErrorIs(t, err, ErrBanned)
+	must.True(t, access.UnbanName("Player"))
+
+	access.BanAddr(netip.PrefixFrom(cli.LocalAddr().Addr(), 24).Masked(), time.Minute, "")
+	err = cli.TryJoin(ctx, addr, MsgServerTryJoin{PlayerName: "Player"})
+	must.ErrorIs(t, err, ErrBanned)
+
+	srv.handleConnectMsg(srv.Port.Conn(cli.LocalAddr()).Stream(udpconn.ServerStreamID), &MsgConnect{})
+	must.EqOp(t, 0, connects.Load())
+}
@@ -0,0 +1,405 @@
+package noxnet
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+// DefaultBotTick is the default interval between input packets sent by the Bot.
+const DefaultBotTick = time.Second / 30
+
+// BotOptions configures the Bot.
+type BotOptions struct {
+	// Join is a join request sent to the server.
+	Join MsgServerTryJoin
+	// Password is sent if the server requires one.
+	Password string
+	// Accept describes the player for the server.
+	Accept MsgClientAccept
+	// Tick is the interval between input packets. Default is DefaultBotTick.
+	Tick time.Duration
+	// OnMap is called when the server switches the map, before the bot reports that it's ready.
+	// It can be used to download the map with Client.DownloadMap.
+	// It's called in a separate goroutine. If it returns an error, the join fails.
+	OnMap func(ctx context.Context, m *MsgUseMap) error
+	// OnMessage is called for server messages not handled by the bot.
+	// It's called from the network goroutine and must not block.
+	OnMessage func(m netmsg.Message)
+	// World receives all server messages. If not set, a new World with default options is created.
+	// It treats every object type as simple, see WorldOptions.IsComplex.
+	World *World
+}
+
+// BotState is a snapshot of the bot player state, as reported by the server.
+type BotState struct {
+	// PlayerID is the stream ID assigned by the server.
+	PlayerID uint32
+	// NetCode of the player object.
+	NetCode NetCode
+	// Name of the player, as accepted by the server.
+	Name string
+	// Map is the current map name.
+	Map    string
+	MapCRC uint32
+	// Frame is the last known server frame, as tracked by the World.
+	Frame     Timestamp
+	Health    uint16
+	MaxHealth uint16
+	Mana      uint16
+	MaxMana   uint16
+	// Ready is set when the bot completed the join and receives game updates.
+	Ready bool
+}
+
+// NewBot creates a headless client that joins the game as a player.
+func NewBot(log *slog.Logger, conn udpconn.PacketConn, opts *BotOptions) *Bot {
+	return NewBotWithClient(log, NewClient(log, conn), opts)
+}
+
+// NewBotWithClient creates a bot with an existing client. The bot takes the ownership of the client.
+func NewBotWithClient(log *slog.Logger, c *Client, opts *BotOptions) *Bot {
+	if log == nil {
+		log = slog.Default()
+	}
+	if opts == nil {
+		opts = &BotOptions{}
+	}
+	if opts.Tick <= 0 {
+		opts.Tick = DefaultBotTick
+	}
+	w := opts.World
+	if w == nil {
+		w = NewWorld(nil)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bot{
+		log:    log,
+		c:      c,
+		w:      w,
+		opts:   *opts,
+		ctx:    ctx,
+		cancel: cancel,
+		joined: make(chan struct{}),
+	}
+	c.onGameMessage(func(m netmsg.Message) bool {
+		ok, rest := b.w.HandleRest(m)
+		ok = b.handleMsg(m) || ok
+		for _, m := range rest {
+			b.handleMsg(m)
+		}
+		return ok
+	})
+	return b
+}
+
+// Bot is a headless client that joins the game, keeps the connection alive and sends player inputs on a schedule.
+type Bot struct {
+	log    *slog.Logger
+	c      *Client
+	w      *World
+	opts   BotOptions
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	closed    bool
+	st        BotState
+	hasJoin   bool // received join data
+	hasMap    bool // reported that the map is loaded
+	important uint32
+	joined    chan struct{}
+	joinErr   error
+
+	imu     sync.Mutex
+	held    []PlayerInput
+	pressed []PlayerInput
+	mouse   MsgMouse
+	mouseUp bool
+}
+
+// Client returns the underlying client.
+func (b *Bot) Client() *Client {
+	return b.c
+}
+
+// World returns the world state, as seen by the bot.
+func (b *Bot) World() *World {
+	return b.w
+}
+
+// Close disconnects the bot and stops sending inputs.
+func (b *Bot) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.cancel()
+	b.wg.Wait()
+	b.c.Close()
+}
+
+// State returns the current player state.
+func (b *Bot) State() BotState {
+	b.mu.Lock()
+	st := b.st
+	b.mu.Unlock()
+	st.Frame = b.w.Frame()
+	return st
+}
+
+// Join joins the server and waits until the bot is in game. After that, the bot starts sending inputs.
+func (b *Bot) Join(ctx context.Context, addr netip.AddrPort) error {
+	err := b.c.TryJoin(ctx, addr, b.opts.Join)
+	if errors.Is(err, ErrPasswordRequired) && b.opts.Password != "" {
+		err = b.c.TryPassword(ctx, b.opts.Password)
+	}
+	if err != nil {
+		return err
+	}
+	if err = b.c.Connect(ctx, addr, &b.opts.Accept); err != nil {
+		return err
+	}
+	b.c.smu.RLock()
+	pid := b.c.pid
+	b.c.smu.RUnlock()
+	b.mu.Lock()
+	b.st.PlayerID = pid
+	b.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-b.ctx.Done():
+		return b.ctx.Err()
+	case <-b.joined:
+	}
+	b.mu.Lock()
+	err = b.joinErr
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return context.Canceled
+	}
+	b.wg.Add(1)
+	go b.inputLoop()
+	return nil
+}
+
+func (b *Bot) stream() udpconn.Stream {
+	b.c.smu.RLock()
+	defer b.c.smu.RUnlock()
+	return b.c.own
+}
+
+// Send sends messages to the server unreliably.
+func (b *Bot) Send(msgs ...netmsg.Message) error {
+	own := b.stream()
+	if !own.Valid() {
+		return errors.New("not connected")
+	}
+	return own.SendUnreliable(msgs...)
+}
+
+// SendReliable sends messages to the server and waits for the acknowledgement.
+func (b *Bot) SendReliable(ctx context.Context, msgs ...netmsg.Message) error {
+	own := b.stream()
+	if !own.Valid() {
+		return errors.New("not connected")
+	}
+	return own.SendReliable(ctx, msgs...)
+}
+
+// SetMouse sets the cursor position in world coordinates. It's sent with the next input packet.
+func (b *Bot) SetMouse(x, y uint16) {
+	b.imu.Lock()
+	defer b.imu.Unlock()
+	b.mouse = MsgMouse{X: x, Y: y}
+	b.mouseUp = true
+}
+
+// Hold adds inputs which are sent with every input packet until released.
+// Inputs with the same control code replace previously held ones.
+func (b *Bot) Hold(inputs ...PlayerInput) {
+	b.imu.Lock()
+	defer b.imu.Unlock()
+	for _, in := range inputs {
+		b.held = slices.DeleteFunc(b.held, func(v PlayerInput) bool {
+			return v.CtrlCode() == in.CtrlCode()
+		})
+		b.held = append(b.held, in)
+	}
+}
+
+// Release removes held inputs with given control codes. If no codes are given, all inputs are released.
+func (b *Bot) Release(codes ...CtrlCode) {
+	b.imu.Lock()
+	defer b.imu.Unlock()
+	if len(codes) == 0 {
+		b.held = nil
+		return
+	}
+	b.held = slices.DeleteFunc(b.held, func(v PlayerInput) bool {
+		return slices.Contains(codes, v.CtrlCode())
+	})
+}
+
+// Press sends inputs once, with the next input packet.
+func (b *Bot) Press(inputs ...PlayerInput) {
+	b.imu.Lock()
+	defer b.imu.Unlock()
+	b.pressed = append(b.pressed, inputs...)
+}
+
+// nextInputs returns messages for the next input packet.
+func (b *Bot) nextInputs() []netmsg.Message {
+	b.imu.Lock()
+	defer b.imu.Unlock()
+	var out []netmsg.Message
+	if len(b.held) != 0 || len(b.pressed) != 0 {
+		inp := &MsgPlayerInput{Inputs: slices.Concat(b.held, b.pressed)}
+		b.pressed = nil
+		out = append(out, inp)
+	}
+	if b.mouseUp {
+		m := b.mouse
+		b.mouseUp = false
+		out = append(out, &m)
+	}
+	return out
+}
+
+func (b *Bot) inputLoop() {
+	defer b.wg.Done()
+	t := time.NewTicker(b.opts.Tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-t.C:
+		}
+		msgs := b.nextInputs()
+		if len(msgs) == 0 {
+			continue
+		}
+		if err := b.Send(msgs...); err != nil {
+			b.log.Warn("cannot send inputs", "err", err)
+		}
+	}
+}
+
+// finishJoin completes the join with an error, or successfully if all join messages are handled.
+func (b *Bot) finishJoin(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.st.Ready || b.joinErr != nil {
+		return
+	}
+	if err != nil {
+		b.joinErr = err
+	} else if b.hasJoin && b.hasMap {
+		b.st.Ready = true
+	} else {
+		return
+	}
+	close(b.joined)
+}
+
+// useMap switches the map and reports to the server that the client is ready.
+func (b *Bot) useMap(m *MsgUseMap) {
+	defer b.wg.Done()
+	if b.opts.OnMap != nil {
+		if err := b.opts.OnMap(b.ctx, m); err != nil {
+			b.finishJoin(err)
+			return
+		}
+	}
+	own := b.stream()
+	if !own.Valid() {
+		return
+	}
+	b.mu.Lock()
+	b.important++
+	id := b.important
+	b.mu.Unlock()
+	own.QueueReliable(udpconn.Options{Context: b.ctx}, &MsgImportantSrv{ID: id})
+	_ = own.SendQueue()
+	b.mu.Lock()
+	b.hasMap = true
+	b.mu.Unlock()
+	b.finishJoin(nil)
+}
+
+func (b *Bot) handleMsg(m netmsg.Message) bool {
+	switch m := m.(type) {
+	case *MsgSeqImportant:
+		if m.Msg != nil {
+			return b.handleMsg(m.Msg)
+		}
+		return true
+	case *MsgUseMap:
+		b.mu.Lock()
+		b.st.Map = m.MapName.Value
+		b.st.MapCRC = m.CRC
+		closed := b.closed
+		if !closed {
+			b.wg.Add(1)
+		}
+		b.mu.Unlock()
+		if !closed {
+			go b.useMap(m)
+		}
+		return true
+	case *MsgJoinData:
+		b.mu.Lock()
+		b.st.NetCode = m.NetCode
+		b.hasJoin = true
+		b.mu.Unlock()
+		b.finishJoin(nil)
+		return true
+	case *MsgNewPlayer:
+		b.mu.Lock()
+		if m.NetCode == b.st.NetCode {
+			b.st.Name = m.PlayerName
+		}
+		b.mu.Unlock()
+	case *MsgFullTimestamp, *MsgTimestamp:
+		return true // tracked by the World
+	case *MsgImportantCli:
+		ts := b.w.Frame()
+		if own := b.stream(); own.Valid() {
+			_ = own.SendUnreliable(&MsgImportantAckSrv{TS: ts})
+		}
+		return true
+	case *MsgImportantAckCli:
+		return true
+	case *MsgReportHealth:
+		b.mu.Lock()
+		b.st.Health, b.st.MaxHealth = m.Health, m.Max
+		b.mu.Unlock()
+	case *MsgReportMana:
+		b.mu.Lock()
+		b.st.Mana, b.st.MaxMana = m.Mana, m.Max
+		b.mu.Unlock()
+	}
+	if b.opts.OnMessage != nil {
+		b.opts.OnMessage(m)
+		return true
+	}
+	switch m.(type) {
+	case *MsgNewPlayer, *MsgReportHealth, *MsgReportMana:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package noxnet
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/binenc"
+	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+func TestBotJoin(t *testing.T) {
+	pl := &testPlayer{id: 1, name: "Bot"}
+	e := &testEngine{
+		t: t,
+		OnTry: func(req *MsgServerTryJoin) error {
+			return nil
+		},
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			return pl, nil
+		},
+	}
+	srv, cli := newServerAndClient(t, e)
+
+	ready := make(chan uint32, 1)
+	inputs := make(chan netmsg.Message, 10)
+	var stream udpconn.Stream
+	srv.Port.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		switch m := m.(type) {
+		case *MsgClientAccept:
+			stream = s
+			s.QueueReliable(udpconn.Options{},
+				&MsgUseMap{MapName: binenc.String{Value: "estate"}, CRC: 0x1234, T: 100},
+				&MsgJoinData{NetCode: 42},
+				&MsgFullTimestamp{T: 105},
+			)
+			_ = s.SendQueue()
+			return true
+		case *MsgImportantSrv:
+			_ = s.SendUnreliable(&MsgImportantAckCli{ID: m.ID})
+			select {
+			case ready <- m.ID:
+			default:
+			}
+			return true
+		case *MsgPlayerInput, *MsgMouse:
+			select {
+			case inputs <- m:
+			default:
+			}
+			return true
+		}
+		return false
+	})
+
+	var gotMap *MsgUseMap
+	other := make(chan netmsg.Message, 10)
+	b := NewBotWithClient(slog.Default(), cli, &BotOptions{
+		Join:   MsgServerTryJoin{PlayerName: "Bot"},
+		Accept: MsgClientAccept{PlayerInfo: PlayerInfo{PlayerName: "Bot"}},
+		Tick:   resendTick,
+		OnMap: func(ctx context.Context, m *MsgUseMap) error {
+			gotMap = m
+			return nil
+		},
+		OnMessage: func(m netmsg.Message) {
+			select {
+			case other <- m:
+			default:
+			}
+		},
+	})
+	t.Cleanup(b.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*resendTick)
+	defer cancel()
+	err := b.Join(ctx, srv.LocalAddr())
+	must.NoError(t, err)
+	must.NotNil(t, gotMap)
+	must.EqOp(t, "estate", gotMap.MapName.Value)
+
+	select {
+	case id := <-ready:
+		must.EqOp(t, 1, id)
+	case <-time.After(5 * resendTick):
+		t.Fatal("expected ready message")
+	}
+
+	st := b.State()
+	must.True(t, st.Ready)
+	must.EqOp(t, 1, st.PlayerID)
+	must.EqOp(t, NetCode(42), st.NetCode)
+	must.EqOp(t, "estate", st.Map)
+	must.EqOp(t, 0x1234, st.MapCRC)
+
+	b.Hold(&PlayerInput1{Code: CCMoveForward, Val: 0x10})
+	b.SetMouse(100, 200)
+	var (
+		gotInput *MsgPlayerInput
+		gotMouse *MsgMouse
+	)
+	for gotInput == nil || gotMouse == nil {
+		select {
+		case m := <-inputs:
+			switch m := m.(type) {
+			case *MsgPlayerInput:
+				gotInput = m
+			case *MsgMouse:
+				gotMouse = m
+			}
+		case <-time.After(10 * resendTick):
+			t.Fatal("expected inputs")
+		}
+	}
+	must.Eq(t, &MsgPlayerInput{Inputs: []PlayerInput{&PlayerInput1{Code: CCMoveForward, Val: 0x10}}}, gotInput)
+	must.Eq(t, &MsgMouse{X: 100, Y: 200}, gotMouse)
+	must.EqOp(t, Timestamp(105), b.State().Frame)
+
+	// messages after an update stream are decoded by the World, but still reach the bot
+	data := []byte{
+		0xff, 0x10, 0x00, 0x01, 0x00, // observer ID, type
+		100, 0, 200, 0, 0x0, 0, // pos, flags, unk5
+		0, 0, 0,
+	}
+	data, err = netmsg.Append(data, &MsgReportHealth{Health: 50, Max: 100})
+	must.NoError(t, err)
+	data, err = netmsg.Append(data, &MsgObjectFriendAdd{ObjectCode{NetCode: 0x10}})
+	must.NoError(t, err)
+	err = stream.SendUnreliable(&netmsg.Unknown{Op: netmsg.MSG_UPDATE_STREAM, Data: data})
+	must.NoError(t, err)
+	for _, exp := range []netmsg.Message{
+		&netmsg.Unknown{Op: netmsg.MSG_UPDATE_STREAM, Data: data},
+		&MsgReportHealth{Health: 50, Max: 100},
+		&MsgObjectFriendAdd{ObjectCode{NetCode: 0x10}},
+	} {
+		select {
+		case m := <-other:
+			must.Eq(t, exp, m)
+		case <-time.After(10 * resendTick):
+			t.Fatalf("expected %T", exp)
+		}
+	}
+	st = b.State()
+	must.EqOp(t, 50, st.Health)
+	must.EqOp(t, 100, st.MaxHealth)
+	o, ok := b.World().Object(0x10)
+	must.True(t, ok)
+	must.True(t, o.Friend)
+}
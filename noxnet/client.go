@@ -9,8 +9,10 @@ import (
 	"net/netip"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/opennox/libs/noxnet/discover"
+	"github.com/opennox/libs/noxnet/mapsend"
 	"github.com/opennox/libs/noxnet/netmsg"
 	"github.com/opennox/libs/noxnet/udpconn"
 )
@@ -20,6 +22,10 @@ var (
 	ErrJoinFailed       = errors.New("join failed")
 )
 
+// acceptWait is the max time Connect waits for a rejection after the server acknowledges MsgClientAccept.
+// The server doesn't confirm the join explicitly, so the wait ends early on the first game message.
+const acceptWait = time.Second
+
 func NewClient(log *slog.Logger, conn udpconn.PacketConn) *Client {
 	p := udpconn.NewPort(log, conn, netmsg.Options{IsClient: true})
 	return NewClientWithPort(log, p)
@@ -49,6 +55,16 @@ type Client struct {
 		res chan<- netmsg.Message
 	}
 
+	maps struct {
+		sync.RWMutex
+		recv *mapsend.Receiver
+	}
+
+	game struct {
+		sync.RWMutex
+		fnc func(m netmsg.Message) bool
+	}
+
 	smu  sync.RWMutex
 	port *udpconn.Conn
 	srv  udpconn.Stream
@@ -76,6 +92,7 @@ func (c *Client) Reset() {
 }
 
 func (c *Client) SetServerAddr(addr netip.AddrPort) {
+	addr = udpconn.NormalizeAddr(addr)
 	var cur netip.AddrPort
 	if c.port != nil {
 		cur = c.port.RemoteAddr()
@@ -95,10 +112,16 @@ func (c *Client) SetServerAddr(addr netip.AddrPort) {
 func (c *Client) handleMsg(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
 	c.smu.RLock()
 	port := c.port
+	own := c.own
 	c.smu.RUnlock()
 	if port != nil && port == s.Conn() {
-		switch s.SID() {
-		case 0: // from server
+		c.maps.RLock()
+		recv := c.maps.recv
+		c.maps.RUnlock()
+		if recv != nil && recv.Handle(s, m, flags) {
+			return true
+		}
+		if s.SID() == udpconn.ServerStreamID || (own.Valid() && s.SID() == own.SID()) {
 			return c.handleServerMsg(m)
 		}
 		return false
@@ -131,24 +154,53 @@ func (c *Client) handleMsg(s udpconn.Stream, m netmsg.Message, flags udpconn.Pac
 
 func (c *Client) handleServerMsg(m netmsg.Message) bool {
 	switch m := m.(type) {
-	case *MsgJoinOK, ErrorMsg:
-		c.join.RLock()
-		res := c.join.res
-		c.join.RUnlock()
-		if res != nil {
-			select {
-			case res <- m:
-			default:
-			}
+	case *MsgAccept:
+		return true // always precedes MsgServerAccept
+	case *MsgJoinOK, *MsgServerAccept, ErrorMsg:
+		return c.joinResult(m)
+	default:
+		switch m.(type) {
+		case *MsgUseMap, *MsgJoinData:
+			// confirms the join, but must reach the game handler as well
+			c.joinResult(m)
+		}
+		if c.handleGameMsg(m) {
 			return true
 		}
-		return false
-	default:
 		c.log.Warn("unhandled server message", "type", reflect.TypeOf(m).String(), "msg", m)
 		return false
 	}
 }
 
+// joinResult passes the message to the pending join request. It returns false if there's no request.
+func (c *Client) joinResult(m netmsg.Message) bool {
+	c.join.RLock()
+	res := c.join.res
+	c.join.RUnlock()
+	if res == nil {
+		return false
+	}
+	select {
+	case res <- m:
+	default:
+	}
+	return true
+}
+
+// onGameMessage sets a handler for server messages that are not part of the join process.
+func (c *Client) onGameMessage(fnc func(m netmsg.Message) bool) {
+	c.game.Lock()
+	defer c.game.Unlock()
+	c.game.fnc = fnc
+}
+
+func (c *Client) handleGameMsg(m netmsg.Message) bool {
+	c.game.RLock()
+	fnc := c.game.fnc
+	c.game.RUnlock()
+	return fnc != nil && fnc(m)
+}
+
 type ServerInfoResp struct {
 	Addr netip.AddrPort
 	Info discover.MsgServerInfo
@@ -188,29 +240,7 @@ func (c *Client) joinSrv(ctx context.Context, req netmsg.Message, out chan<- net
 	if !srv.Valid() {
 		return nil, errors.New("server address must be set")
 	}
-	c.join.Lock()
-	if c.join.res != nil {
-		c.join.Unlock()
-		return nil, errors.New("already joining")
-	}
-	c.join.res = out
-	c.join.Unlock()
-	cancel := func() {
-		c.join.Lock()
-		c.join.res = nil
-		c.join.Unlock()
-	}
-	var err error
-	if reliable {
-		err = srv.SendReliable(ctx, req)
-	} else {
-		err = srv.SendUnreliable(req)
-	}
-	if err != nil {
-		cancel()
-		return nil, err
-	}
-	return cancel, nil
+	return c.joinVia(ctx, srv, req, out, reliable)
 }
 
 func (c *Client) joinOwn(ctx context.Context, req netmsg.Message, out chan<- netmsg.Message, reliable bool) (func(), error) {
@@ -220,23 +250,33 @@ func (c *Client) joinOwn(ctx context.Context, req netmsg.Message, out chan<- net
 	if !own.Valid() {
 		return nil, errors.New("not connected")
 	}
+	return c.joinVia(ctx, own, req, out, reliable)
+}
+
+// joinWait registers a channel for join responses.
+func (c *Client) joinWait(out chan<- netmsg.Message) (func(), error) {
 	c.join.Lock()
+	defer c.join.Unlock()
 	if c.join.res != nil {
-		c.join.Unlock()
 		return nil, errors.New("already joining")
 	}
 	c.join.res = out
-	c.join.Unlock()
-	cancel := func() {
+	return func() {
 		c.join.Lock()
 		c.join.res = nil
 		c.join.Unlock()
+	}, nil
+}
+
+func (c *Client) joinVia(ctx context.Context, s udpconn.Stream, req netmsg.Message, out chan<- netmsg.Message, reliable bool) (func(), error) {
+	cancel, err := c.joinWait(out)
+	if err != nil {
+		return nil, err
 	}
-	var err error
 	if reliable {
-		err = own.SendReliable(ctx, req)
+		err = s.SendReliable(ctx, req)
 	} else {
-		err = own.SendUnreliable(req)
+		err = s.SendUnreliable(req)
 	}
 	if err != nil {
 		cancel()
@@ -292,12 +332,25 @@ func (c *Client) TryPassword(ctx context.Context, pass string) error {
 
 func (c *Client) connect(ctx context.Context, addr netip.AddrPort) error {
 	c.SetServerAddr(addr)
+	c.smu.RLock()
+	port := c.port
+	c.smu.RUnlock()
+	if port == nil {
+		return errors.New("server address must be set")
+	}
 	out := make(chan netmsg.Message, 1)
-	cancel, err := c.joinSrv(ctx, &netmsg.Unknown{Op: netmsg.MSG_SERVER_CONNECT}, out, true)
+	cancel, err := c.joinWait(out)
 	if err != nil {
 		return err
 	}
 	defer cancel()
+	// The server expects connection requests on a dedicated stream, but responds on the server stream.
+	// Don't wait for the ACK: the server may send it already encrypted, while the response confirms the delivery anyway.
+	s := port.Stream(udpconn.MaxStreamID)
+	s.QueueReliable(udpconn.Options{Context: ctx}, &MsgConnect{})
+	if err = s.SendQueue(); err != nil {
+		return err
+	}
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -321,19 +374,26 @@ func (c *Client) connect(ctx context.Context, addr netip.AddrPort) error {
 
 func (c *Client) clientAccept(ctx context.Context, req *MsgClientAccept) error {
 	out := make(chan netmsg.Message, 1)
+	// returns when the server acknowledges the request
 	cancel, err := c.joinOwn(ctx, req, out, true)
 	if err != nil {
 		return err
 	}
 	defer cancel()
+	// the server doesn't respond on success, but it may reject the player
+	t := time.NewTimer(acceptWait)
+	defer t.Stop()
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
+	case <-t.C:
+		return nil
 	case resp := <-out:
-		if err := c.port.Ack(); err != nil {
-			return err
-		}
 		switch resp := resp.(type) {
+		case ErrorMsg:
+			return resp.Error()
+		case *MsgUseMap, *MsgJoinData:
+			return nil
 		default:
 			return fmt.Errorf("unexpected response: %v", resp.NetOp())
 		}
@@ -349,3 +409,28 @@ func (c *Client) Connect(ctx context.Context, addr netip.AddrPort, req *MsgClien
 	}
 	return nil
 }
+
+// DownloadMap requests the current map from the server and downloads it using native map transfer protocol.
+// The map is saved to dir, or to the default map directory if dir is empty. It returns the path of the map file.
+func (c *Client) DownloadMap(ctx context.Context, dir string, opts *mapsend.ClientOptions) (string, error) {
+	c.smu.RLock()
+	own := c.own
+	c.smu.RUnlock()
+	if !own.Valid() {
+		return "", errors.New("not connected")
+	}
+	r := mapsend.NewReceiver(c.log, dir, opts)
+	c.maps.Lock()
+	if c.maps.recv != nil {
+		c.maps.Unlock()
+		return "", mapsend.ErrBusy
+	}
+	c.maps.recv = r
+	c.maps.Unlock()
+	defer func() {
+		c.maps.Lock()
+		c.maps.recv = nil
+		c.maps.Unlock()
+	}()
+	return r.Download(ctx, own)
+}
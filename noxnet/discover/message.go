@@ -100,7 +100,7 @@ func (p *MsgServerInfo) Decode(data []byte) (int, error) {
 	p.PlayersCur = data[0]
 	p.PlayersMax = data[1]
 	copy(p.Unk2[:], data[2:7])
-	p.MapName = binenc.CString(data[7:16])
+	p.MapName = binenc.CString(data[7:15])
 	p.Status1 = data[16]
 	p.Status2 = data[17]
 	copy(p.Unk19[:], data[18:25])
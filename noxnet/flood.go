@@ -0,0 +1,355 @@
+package noxnet
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+const (
+	defaultHalfOpenTimeout = 10 * time.Second
+	defaultBlockFor        = time.Minute
+	// maxFloodAddrs is the max number of per-address buckets. Least recently used ones are evicted first.
+	maxFloodAddrs = 4096
+	// floodPruneInterval is the interval for removing expired blocks.
+	floodPruneInterval = 10 * time.Second
+)
+
+// RateLimit describes a token bucket: Rate tokens are added per second, up to Burst.
+// Zero value means no limit.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// IsZero checks if the limit is disabled.
+func (l RateLimit) IsZero() bool {
+	return l.Rate <= 0
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// addrBucket is a token bucket for a single IP, stored in the LRU list.
+type addrBucket struct {
+	ip netip.Addr
+	tokenBucket
+}
+
+// allow takes a token from the bucket, if available.
+func (b *tokenBucket) allow(l RateLimit, now time.Time) bool {
+	burst := float64(max(l.Burst, 1))
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// FloodReason describes why a packet or a join from a remote address was rejected.
+type FloodReason int
+
+const (
+	// FloodAddrRate is reported when a single address exceeds FloodOptions.PerAddr.
+	FloodAddrRate = FloodReason(iota + 1)
+	// FloodGlobalRate is reported when all addresses together exceed FloodOptions.Global.
+	FloodGlobalRate
+	// FloodPending is reported when a join is rejected because of FloodOptions.MaxPending.
+	FloodPending
+	// FloodHalfOpen is reported when a pending join is dropped after FloodOptions.HalfOpenTimeout.
+	FloodHalfOpen
+	// FloodBlocked is reported when a packet is dropped because the address is blocked.
+	FloodBlocked
+	// FloodSysop is reported when a player reaches SysopOptions.MaxAttempts failed sysop logins.
+	FloodSysop
+)
+
+func (r FloodReason) String() string {
+	switch r {
+	case FloodAddrRate:
+		return "address rate"
+	case FloodGlobalRate:
+		return "global rate"
+	case FloodPending:
+		return "too many pending"
+	case FloodHalfOpen:
+		return "half-open timeout"
+	case FloodBlocked:
+		return "blocked"
+	case FloodSysop:
+		return "sysop login"
+	default:
+		return "unknown"
+	}
+}
+
+// FloodOptions configures protection against discovery and connection floods.
+//
+// Limits only apply to packets on the global and connection streams, traffic of connected players is not affected.
+// Zero value disables all limits.
+type FloodOptions struct {
+	// PerAddr limits the rate of packets from a single IP.
+	PerAddr RateLimit
+	// Global limits the rate of packets from all IPs together.
+	Global RateLimit
+	// MaxPending limits the number of players that connected, but haven't sent anything on their own stream yet.
+	MaxPending int
+	// HalfOpenTimeout is the time after which a pending player is disconnected.
+	// Default is 10s, if MaxPending is set. Otherwise, pending players are not tracked.
+	HalfOpenTimeout time.Duration
+	// BlockFor is the time for which an IP is blocked, if FloodEngine asked to block it. Default is 1 minute.
+	BlockFor time.Duration
+}
+
+func (o FloodOptions) withDefaults() FloodOptions {
+	if o.MaxPending > 0 && o.HalfOpenTimeout <= 0 {
+		o.HalfOpenTimeout = defaultHalfOpenTimeout
+	}
+	if o.BlockFor <= 0 {
+		o.BlockFor = defaultBlockFor
+	}
+	return o
+}
+
+// FloodEngine is an optional interface for Engine that is notified when flood protection rejects a packet or a join.
+//
+// It can be called for each dropped packet, so implementations should rate-limit their logging.
+type FloodEngine interface {
+	// OnFlood is called without any locks held. Returning true blocks the IP for FloodOptions.BlockFor.
+	OnFlood(addr netip.AddrPort, reason FloodReason) bool
+}
+
+type pendingJoin struct {
+	p     Player
+	sid   udpconn.SID
+	timer *time.Timer
+}
+
+type floodState struct {
+	sync.Mutex
+	opts      FloodOptions
+	global    tokenBucket
+	byAddr    map[netip.Addr]*list.Element // of *addrBucket
+	addrs     list.List                    // most recently used first
+	blocked   map[netip.Addr]time.Time
+	nextPrune time.Time
+	pending   map[netip.AddrPort]*pendingJoin
+}
+
+// addrBucket returns the bucket for the IP and marks it as recently used.
+func (f *floodState) addrBucket(ip netip.Addr) *tokenBucket {
+	e := f.byAddr[ip]
+	if e == nil {
+		return nil
+	}
+	f.addrs.MoveToFront(e)
+	return &e.Value.(*addrBucket).tokenBucket
+}
+
+// newAddrBucket adds a bucket for the IP, evicting the least recently used one if the limit is reached.
+func (f *floodState) newAddrBucket(ip netip.Addr) *tokenBucket {
+	if f.byAddr == nil {
+		f.byAddr = make(map[netip.Addr]*list.Element)
+	}
+	for len(f.byAddr) >= maxFloodAddrs {
+		e := f.addrs.Back()
+		delete(f.byAddr, e.Value.(*addrBucket).ip)
+		f.addrs.Remove(e)
+	}
+	b := &addrBucket{ip: ip}
+	f.byAddr[ip] = f.addrs.PushFront(b)
+	return &b.tokenBucket
+}
+
+// pruneBlocked removes expired blocks. It only scans the list once per floodPruneInterval.
+func (f *floodState) pruneBlocked(now time.Time) {
+	if len(f.blocked) == 0 || now.Before(f.nextPrune) {
+		return
+	}
+	f.nextPrune = now.Add(floodPruneInterval)
+	for ip, until := range f.blocked {
+		if !now.Before(until) {
+			delete(f.blocked, ip)
+		}
+	}
+}
+
+// Block drops all packets from the IP on the global and connection streams for a given duration.
+func (s *Server) Block(ip netip.Addr, dt time.Duration) {
+	ip = ip.Unmap()
+	s.flood.Lock()
+	defer s.flood.Unlock()
+	now := time.Now()
+	if s.flood.blocked == nil {
+		s.flood.blocked = make(map[netip.Addr]time.Time)
+	}
+	s.flood.pruneBlocked(now)
+	s.flood.blocked[ip] = now.Add(dt)
+}
+
+// isBlocked checks if the IP is currently blocked.
+func (s *Server) isBlocked(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	s.flood.Lock()
+	defer s.flood.Unlock()
+	until, ok := s.flood.blocked[ip]
+	return ok && time.Now().Before(until)
+}
+
+// Unblock removes the IP from the block list.
+func (s *Server) Unblock(ip netip.Addr) {
+	ip = ip.Unmap()
+	s.flood.Lock()
+	defer s.flood.Unlock()
+	delete(s.flood.blocked, ip)
+}
+
+// Pending returns the number of players that connected, but haven't sent anything on their own stream yet.
+func (s *Server) Pending() int {
+	s.flood.Lock()
+	defer s.flood.Unlock()
+	return len(s.flood.pending)
+}
+
+// reportFlood notifies the engine about the rejected packet and blocks the address, if requested.
+func (s *Server) reportFlood(addr netip.AddrPort, reason FloodReason) {
+	e, ok := s.e.(FloodEngine)
+	if !ok || !e.OnFlood(addr, reason) {
+		return
+	}
+	s.Block(addr.Addr(), s.flood.opts.BlockFor)
+}
+
+// allowPacket checks rate limits for a packet on the global or connection stream.
+func (s *Server) allowPacket(addr netip.AddrPort) bool {
+	reason := s.checkPacket(addr, time.Now())
+	if reason == 0 {
+		return true
+	}
+	if reason != FloodBlocked {
+		s.log.Debug("packet dropped", "addr", addr, "reason", reason)
+	}
+	s.reportFlood(addr, reason)
+	return false
+}
+
+func (s *Server) checkPacket(addr netip.AddrPort, now time.Time) FloodReason {
+	ip := addr.Addr()
+	f := &s.flood
+	f.Lock()
+	defer f.Unlock()
+	f.pruneBlocked(now)
+	if until, ok := f.blocked[ip]; ok {
+		if now.Before(until) {
+			return FloodBlocked
+		}
+		delete(f.blocked, ip)
+	}
+	perAddr := f.opts.PerAddr
+	var b *tokenBucket
+	if !perAddr.IsZero() {
+		b = f.addrBucket(ip)
+		if b != nil && !b.allow(perAddr, now) {
+			return FloodAddrRate
+		}
+	}
+	// check the global limit before tracking a new address, so that a flood from spoofed addresses
+	// is rejected without evicting the buckets of real clients
+	if l := f.opts.Global; !l.IsZero() && !f.global.allow(l, now) {
+		return FloodGlobalRate
+	}
+	if !perAddr.IsZero() && b == nil {
+		// a new bucket is always full
+		f.newAddrBucket(ip).allow(perAddr, now)
+	}
+	return 0
+}
+
+// addPending registers a new pending join. It returns false if the join should be rejected.
+// If dup is set, the address already has a pending join.
+func (s *Server) addPending(addr netip.AddrPort) (dup, ok bool) {
+	f := &s.flood
+	f.Lock()
+	defer f.Unlock()
+	if f.opts.HalfOpenTimeout <= 0 {
+		return false, true
+	}
+	if _, ok := f.pending[addr]; ok {
+		return true, false
+	}
+	if f.opts.MaxPending > 0 && len(f.pending) >= f.opts.MaxPending {
+		return false, false
+	}
+	if f.pending == nil {
+		f.pending = make(map[netip.AddrPort]*pendingJoin)
+	}
+	// reserve the slot, player is set by startPending
+	f.pending[addr] = &pendingJoin{}
+	return false, true
+}
+
+// startPending starts half-open timer for a join registered with addPending.
+func (s *Server) startPending(addr netip.AddrPort, p Player, sid udpconn.SID) {
+	f := &s.flood
+	f.Lock()
+	defer f.Unlock()
+	pj := f.pending[addr]
+	if pj == nil {
+		return
+	}
+	pj.p, pj.sid = p, sid
+	pj.timer = time.AfterFunc(f.opts.HalfOpenTimeout, func() {
+		if !s.removePending(addr, pj) {
+			return
+		}
+		s.log.Warn("half-open connection timeout", "addr", addr, "sid", sid)
+		s.takeActive(p)
+		s.players.mapper.DelPlayer(addr, p, sid)
+		s.RevokeSysop(p)
+		p.Disconnect()
+		s.reportFlood(addr, FloodHalfOpen)
+	})
+}
+
+// removePending removes a pending join for the address. If pj is set, it is only removed if it matches.
+func (s *Server) removePending(addr netip.AddrPort, pj *pendingJoin) bool {
+	f := &s.flood
+	f.Lock()
+	defer f.Unlock()
+	cur := f.pending[addr]
+	if cur == nil || (pj != nil && cur != pj) {
+		return false
+	}
+	delete(f.pending, addr)
+	if cur.timer != nil {
+		cur.timer.Stop()
+	}
+	return true
+}
+
+// resetFlood stops all half-open timers and forgets rate limiting state.
+func (s *Server) resetFlood() {
+	f := &s.flood
+	f.Lock()
+	defer f.Unlock()
+	for _, pj := range f.pending {
+		if pj.timer != nil {
+			pj.timer.Stop()
+		}
+	}
+	f.pending = nil
+	f.byAddr = nil
+	f.addrs.Init()
+	f.global = tokenBucket{}
+}
@@ -0,0 +1,183 @@
+package lobby
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opennox/libs/noxnet"
+	"github.com/opennox/libs/noxnet/discover"
+)
+
+// NewClient creates a client for the lobby with a given address (host:port or URL).
+func NewClient(log *slog.Logger, addr string) (*Client, error) {
+	if addr == "" {
+		return nil, errors.New("no address")
+	}
+	if log == nil {
+		log = slog.Default()
+	}
+	if !strings.Contains(addr, "://") {
+		if !strings.Contains(addr, ":") {
+			addr = fmt.Sprintf("%s:%d", addr, DefaultPort)
+		}
+		addr = "http://" + addr
+	}
+	return &Client{
+		log:  log,
+		cli:  http.DefaultClient,
+		base: strings.TrimSuffix(addr, "/"),
+	}, nil
+}
+
+// Client for the lobby API.
+type Client struct {
+	log  *slog.Logger
+	cli  *http.Client
+	base string
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return fmt.Errorf("cannot create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+	default:
+		return fmt.Errorf("status: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// List returns servers matching the filter. Nil filter selects all servers.
+func (c *Client) List(ctx context.Context, f *Filter) ([]Entry, error) {
+	q := make(url.Values)
+	if f != nil {
+		if f.Map != "" {
+			q.Set("map", f.Map)
+		}
+		if f.Mode != 0 {
+			q.Set("mode", strconv.Itoa(int(f.Mode)))
+		}
+		if f.Free > 0 {
+			q.Set("free", strconv.Itoa(f.Free))
+		}
+	}
+	path := apiServers
+	if len(q) != 0 {
+		path += "?" + q.Encode()
+	}
+	var out []Entry
+	if err := c.do(ctx, "GET", path, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Register the server in the lobby, or refresh the registration.
+func (c *Client) Register(ctx context.Context, req *RegisterRequest) (*Entry, error) {
+	var e Entry
+	if err := c.do(ctx, "POST", apiServers, req, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Unregister the server with a given address from the lobby.
+func (c *Client) Unregister(ctx context.Context, addr netip.AddrPort) error {
+	return c.do(ctx, "DELETE", apiServers+"/"+url.PathEscape(addr.String()), nil, nil)
+}
+
+// Announce registers the server in the lobby and sends heartbeats until the context is canceled.
+// The server is removed from the lobby when Announce returns.
+//
+// The info function is called before each heartbeat. If it returns nil, the server is hidden:
+// it is removed from the lobby and the heartbeat is skipped, until info returns a value again.
+// If interval is zero, DefaultHeartbeat is used. See also AnnounceServer.
+func (c *Client) Announce(ctx context.Context, port uint16, interval time.Duration, info func() *discover.MsgServerInfo) error {
+	if interval <= 0 {
+		interval = DefaultHeartbeat
+	}
+	var addr netip.AddrPort
+	heartbeat := func() error {
+		m := info()
+		if m == nil {
+			// server is hidden
+			if !addr.IsValid() {
+				return nil
+			}
+			if err := c.Unregister(ctx, addr); err != nil {
+				return err
+			}
+			addr = netip.AddrPort{}
+			return nil
+		}
+		e, err := c.Register(ctx, &RegisterRequest{Port: port, Info: InfoFromMsg(m)})
+		if err != nil {
+			return err
+		}
+		addr = e.Addr
+		return nil
+	}
+	if err := heartbeat(); err != nil {
+		return err
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if addr.IsValid() {
+				// parent context is already canceled
+				uctx, cancel := context.WithTimeout(context.Background(), interval)
+				err := c.Unregister(uctx, addr)
+				cancel()
+				if err != nil {
+					c.log.Warn("cannot unregister from the lobby", "err", err)
+				}
+			}
+			return ctx.Err()
+		case <-t.C:
+		}
+		if err := heartbeat(); err != nil {
+			// lobby may be restarting, keep trying
+			c.log.Warn("lobby heartbeat failed", "err", err)
+		}
+	}
+}
+
+// AnnounceServer registers the game server in the lobby and keeps the registration updated
+// with noxnet.Server.ServerInfo, until the context is canceled. See Announce.
+func (c *Client) AnnounceServer(ctx context.Context, srv *noxnet.Server, interval time.Duration) error {
+	return c.Announce(ctx, srv.LocalAddr().Port(), interval, srv.ServerInfo)
+}
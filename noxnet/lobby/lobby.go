@@ -0,0 +1,156 @@
+// Package lobby implements a registry of game servers, which allows playing over the Internet without LAN discovery.
+//
+// Game servers register in the lobby and send heartbeats with the info returned by the engine,
+// while clients query the list of servers with optional filters.
+package lobby
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opennox/libs/noxnet/discover"
+)
+
+const (
+	// DefaultPort is the default HTTP port of the lobby.
+	DefaultPort = 18580
+	// DefaultTTL is the default time after which a server is removed from the lobby, if it stops sending heartbeats.
+	DefaultTTL = time.Minute
+	// DefaultHeartbeat is the default interval between heartbeats sent by Announce.
+	DefaultHeartbeat = DefaultTTL / 3
+
+	apiServers = "/api/v0/servers"
+)
+
+// Mode is a game mode, as set in discover.MsgServerInfo.Flags.
+type Mode uint16
+
+const (
+	ModeKOTR        = Mode(0x0010)
+	ModeCTF         = Mode(0x0020)
+	ModeFlagBall    = Mode(0x0040)
+	ModeChat        = Mode(0x0080)
+	ModeArena       = Mode(0x0100)
+	ModeElimination = Mode(0x0400)
+	ModeQuest       = Mode(0x1000)
+	modeMask        = ModeKOTR | ModeCTF | ModeFlagBall | ModeChat | ModeArena | ModeElimination | ModeQuest
+)
+
+var modeNames = []struct {
+	Mode Mode
+	Name string
+}{
+	{ModeKOTR, "kotr"},
+	{ModeCTF, "ctf"},
+	{ModeFlagBall, "flagball"},
+	{ModeChat, "chat"},
+	{ModeArena, "arena"},
+	{ModeElimination, "elimination"},
+	{ModeQuest, "quest"},
+}
+
+// ModeFromFlags returns the game mode from server flags.
+func ModeFromFlags(flags uint16) Mode {
+	return Mode(flags) & modeMask
+}
+
+func (m Mode) String() string {
+	for _, v := range modeNames {
+		if m == v.Mode {
+			return v.Name
+		}
+	}
+	return fmt.Sprintf("Mode(0x%x)", uint16(m))
+}
+
+// ParseMode parses game mode name, or a numeric mode value.
+func ParseMode(s string) (Mode, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, v := range modeNames {
+		if s == v.Name {
+			return v.Mode, nil
+		}
+	}
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown game mode: %q", s)
+	}
+	return Mode(v), nil
+}
+
+// Info is a server information reported to the lobby.
+type Info struct {
+	Name       string `json:"name"`
+	Map        string `json:"map"`
+	Flags      uint16 `json:"flags"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"max_players"`
+}
+
+// InfoFromMsg converts server info returned by noxnet.Engine to lobby Info.
+func InfoFromMsg(m *discover.MsgServerInfo) Info {
+	return Info{
+		Name:       m.ServerName,
+		Map:        m.MapName,
+		Flags:      m.Flags,
+		Players:    int(m.PlayersCur),
+		MaxPlayers: int(m.PlayersMax),
+	}
+}
+
+// Mode returns the game mode.
+func (info *Info) Mode() Mode {
+	return ModeFromFlags(info.Flags)
+}
+
+// Free returns the number of free player slots.
+func (info *Info) Free() int {
+	return max(0, info.MaxPlayers-info.Players)
+}
+
+// Entry is a server registered in the lobby.
+type Entry struct {
+	// Addr is the game address of the server.
+	Addr netip.AddrPort `json:"addr"`
+	Info
+	// ModeName is the name of the game mode, for convenience.
+	ModeName string `json:"mode,omitempty"`
+	// Updated is the time of the last heartbeat.
+	Updated time.Time `json:"updated"`
+}
+
+// RegisterRequest registers a server in the lobby, or refreshes the registration.
+//
+// The lobby uses the IP address of the request, thus servers can only register themselves.
+type RegisterRequest struct {
+	// Port is the game port of the server. Default is common.GamePort.
+	Port uint16 `json:"port,omitempty"`
+	Info
+}
+
+// Filter selects servers from the lobby.
+type Filter struct {
+	// Map selects servers with a given map name (case-insensitive).
+	Map string
+	// Mode selects servers with a given game mode.
+	Mode Mode
+	// Free selects servers with at least that many free slots.
+	Free int
+}
+
+// Match checks if the server matches the filter.
+func (f *Filter) Match(e *Entry) bool {
+	if f.Map != "" && !strings.EqualFold(f.Map, e.Map) {
+		return false
+	}
+	if f.Mode != 0 && e.Info.Mode()&f.Mode == 0 {
+		return false
+	}
+	if f.Free > 0 && e.Free() < f.Free {
+		return false
+	}
+	return true
+}
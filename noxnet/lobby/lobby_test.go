@@ -0,0 +1,227 @@
+package lobby
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+	"github.com/shoenig/test/wait"
+
+	"github.com/opennox/libs/noxnet"
+	"github.com/opennox/libs/noxnet/discover"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+func newTestLobby(t testing.TB, opts *ServerOptions) (*Server, *Client) {
+	srv := NewServer(slog.Default(), opts)
+	hs := httptest.NewServer(srv)
+	t.Cleanup(hs.Close)
+	cli, err := NewClient(slog.Default(), hs.URL)
+	must.NoError(t, err)
+	return srv, cli
+}
+
+func names(list []Entry) []string {
+	var out []string
+	for _, e := range list {
+		out = append(out, e.Name)
+	}
+	return out
+}
+
+func TestLobby(t *testing.T) {
+	srv, cli := newTestLobby(t, nil)
+	ctx := context.Background()
+
+	e, err := cli.Register(ctx, &RegisterRequest{Port: 18590, Info: Info{
+		Name: "Arena", Map: "Estate", Flags: uint16(ModeArena), Players: 2, MaxPlayers: 32,
+	}})
+	must.NoError(t, err)
+	must.EqOp(t, netip.MustParseAddrPort("127.0.0.1:18590"), e.Addr)
+	must.EqOp(t, "arena", e.ModeName)
+
+	_, err = cli.Register(ctx, &RegisterRequest{Port: 18591, Info: Info{
+		Name: "CTF", Map: "Bunker", Flags: uint16(ModeCTF), Players: 7, MaxPlayers: 8,
+	}})
+	must.NoError(t, err)
+	// direct registration, like from another host
+	srv.Register(netip.MustParseAddrPort("10.0.0.1:18590"), Info{
+		Name: "Quest", Map: "g_castle", Flags: uint16(ModeQuest), Players: 0, MaxPlayers: 6,
+	})
+
+	list, err := cli.List(ctx, nil)
+	must.NoError(t, err)
+	must.Eq(t, []string{"Quest", "Arena", "CTF"}, names(list))
+
+	list, err = cli.List(ctx, &Filter{Map: "estate"})
+	must.NoError(t, err)
+	must.Eq(t, []string{"Arena"}, names(list))
+
+	list, err = cli.List(ctx, &Filter{Mode: ModeCTF | ModeQuest})
+	must.NoError(t, err)
+	must.Eq(t, []string{"Quest", "CTF"}, names(list))
+
+	list, err = cli.List(ctx, &Filter{Free: 2})
+	must.NoError(t, err)
+	must.Eq(t, []string{"Quest", "Arena"}, names(list))
+
+	// heartbeat updates the info
+	_, err = cli.Register(ctx, &RegisterRequest{Port: 18591, Info: Info{
+		Name: "CTF", Map: "Bunker", Flags: uint16(ModeCTF), Players: 2, MaxPlayers: 8,
+	}})
+	must.NoError(t, err)
+	list, err = cli.List(ctx, &Filter{Free: 2})
+	must.NoError(t, err)
+	must.Eq(t, []string{"Quest", "Arena", "CTF"}, names(list))
+
+	// cannot remove other servers
+	err = cli.Unregister(ctx, netip.MustParseAddrPort("10.0.0.1:18590"))
+	must.Error(t, err)
+	err = cli.Unregister(ctx, netip.MustParseAddrPort("127.0.0.1:18591"))
+	must.NoError(t, err)
+	list, err = cli.List(ctx, nil)
+	must.NoError(t, err)
+	must.Eq(t, []string{"Quest", "Arena"}, names(list))
+}
+
+func TestLobbyExpire(t *testing.T) {
+	srv, cli := newTestLobby(t, &ServerOptions{TTL: time.Minute, MaxPerIP: 1})
+	now := time.Now()
+	srv.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := cli.Register(ctx, &RegisterRequest{Port: 18590, Info: Info{Name: "A"}})
+	must.NoError(t, err)
+	_, err = cli.Register(ctx, &RegisterRequest{Port: 18591, Info: Info{Name: "B"}})
+	must.Error(t, err)
+
+	now = now.Add(2 * time.Minute)
+	list, err := cli.List(ctx, nil)
+	must.NoError(t, err)
+	must.SliceEmpty(t, list)
+
+	_, err = cli.Register(ctx, &RegisterRequest{Port: 18591, Info: Info{Name: "B"}})
+	must.NoError(t, err)
+}
+
+type infoEngine struct {
+	info discover.MsgServerInfo
+}
+
+func (e *infoEngine) ServerInfo(addr netip.AddrPort) *discover.MsgServerInfo {
+	info := e.info
+	return &info
+}
+
+func (e *infoEngine) PreJoin(addr netip.AddrPort, req *noxnet.MsgServerTryJoin) error {
+	return errors.New("not supported")
+}
+
+func (e *infoEngine) CheckPass(addr netip.AddrPort, pass string) error {
+	return errors.New("not supported")
+}
+
+func (e *infoEngine) Connect(addr netip.AddrPort) (noxnet.Player, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestAnnounceServer(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	conn, _ := udpconn.NewPipe(slog.Default(), 10)
+	conn.Addr = netip.AddrPortFrom(conn.Addr.Addr(), udpconn.DefaultPort)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	gs := noxnet.NewServer(slog.Default(), conn, &infoEngine{info: discover.MsgServerInfo{
+		PlayersCur: 3,
+		PlayersMax: 16,
+		MapName:    "estate",
+		Flags:      uint16(ModeArena),
+		ServerName: "Test",
+	}}, nil)
+	t.Cleanup(gs.Close)
+	_, cli := newTestLobby(t, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- cli.AnnounceServer(ctx, gs, interval)
+	}()
+
+	var (
+		list []Entry
+		err  error
+	)
+	must.Wait(t, wait.InitialSuccess(
+		wait.BoolFunc(func() bool {
+			list, err = cli.List(context.Background(), &Filter{Map: "Estate", Mode: ModeArena, Free: 10})
+			return err == nil && len(list) == 1
+		}),
+		wait.Timeout(10*interval),
+		wait.Gap(interval/4),
+	))
+	must.EqOp(t, udpconn.DefaultPort, list[0].Addr.Port())
+	must.EqOp(t, "Test", list[0].Name)
+	must.EqOp(t, "arena", list[0].ModeName)
+
+	cancel()
+	must.ErrorIs(t, <-done, context.Canceled)
+	list, err = cli.List(context.Background(), nil)
+	must.NoError(t, err)
+	must.SliceEmpty(t, list)
+}
+
+func TestAnnounceHidden(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	_, cli := newTestLobby(t, nil)
+	var hidden atomic.Bool
+	info := func() *discover.MsgServerInfo {
+		if hidden.Load() {
+			return nil
+		}
+		return &discover.MsgServerInfo{MapName: "estate", ServerName: "Test"}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- cli.Announce(ctx, udpconn.DefaultPort, interval, info)
+	}()
+	waitCount := func(n int) {
+		t.Helper()
+		must.Wait(t, wait.InitialSuccess(
+			wait.BoolFunc(func() bool {
+				list, err := cli.List(context.Background(), nil)
+				return err == nil && len(list) == n
+			}),
+			wait.Timeout(10*interval),
+			wait.Gap(interval/4),
+		))
+	}
+	waitCount(1)
+	hidden.Store(true)
+	waitCount(0)
+	hidden.Store(false)
+	waitCount(1)
+
+	cancel()
+	must.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestParseMode(t *testing.T) {
+	m, err := ParseMode("CTF")
+	must.NoError(t, err)
+	must.EqOp(t, ModeCTF, m)
+	m, err = ParseMode("0x100")
+	must.NoError(t, err)
+	must.EqOp(t, ModeArena, m)
+	_, err = ParseMode("unknown")
+	must.Error(t, err)
+	must.EqOp(t, ModeFlagBall, ModeFromFlags(0x2041))
+}
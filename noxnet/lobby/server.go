@@ -0,0 +1,260 @@
+package lobby
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/opennox/libs/common"
+)
+
+var _ http.Handler = (*Server)(nil)
+
+// maxRequestSize limits the size of registration requests.
+const maxRequestSize = 4096
+
+// ServerOptions configures the lobby Server.
+type ServerOptions struct {
+	// TTL is the time after which a server is removed, if it stops sending heartbeats. Default is DefaultTTL.
+	TTL time.Duration
+	// MaxPerIP limits the number of servers registered from a single IP. Zero means no limit.
+	MaxPerIP int
+}
+
+// NewServer creates a lobby HTTP server.
+func NewServer(log *slog.Logger, opts *ServerOptions) *Server {
+	if log == nil {
+		log = slog.Default()
+	}
+	if opts == nil {
+		opts = &ServerOptions{}
+	}
+	s := &Server{
+		log:    log,
+		opts:   *opts,
+		mux:    httprouter.New(),
+		byAddr: make(map[netip.AddrPort]*Entry),
+		now:    time.Now,
+	}
+	if s.opts.TTL <= 0 {
+		s.opts.TTL = DefaultTTL
+	}
+	s.mux.Handle("HEAD", apiServers, s.handleList)
+	s.mux.Handle("GET", apiServers, s.handleList)
+	s.mux.Handle("POST", apiServers, s.handleRegister)
+	s.mux.Handle("DELETE", apiServers+"/:addr", s.handleUnregister)
+	return s
+}
+
+// Server is a lobby HTTP server that keeps a list of registered game servers.
+type Server struct {
+	log  *slog.Logger
+	opts ServerOptions
+	mux  *httprouter.Router
+	now  func() time.Time
+
+	mu     sync.RWMutex
+	byAddr map[netip.AddrPort]*Entry
+}
+
+func (s *Server) RegisterOnMux(mux *http.ServeMux) {
+	mux.Handle(apiServers, s)
+	mux.Handle(apiServers+"/", s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.log.Debug("http request", "method", r.Method, "url", r.URL)
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) serveJSON(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(obj)
+}
+
+// List returns servers matching the filter, sorted by the address. Nil filter selects all servers.
+func (s *Server) List(f *Filter) []Entry {
+	s.expire()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Entry, 0, len(s.byAddr))
+	for _, e := range s.byAddr {
+		if f == nil || f.Match(e) {
+			out = append(out, *e)
+		}
+	}
+	slices.SortFunc(out, func(a, b Entry) int {
+		return a.Addr.Compare(b.Addr)
+	})
+	return out
+}
+
+// Register adds or refreshes a server with a given address.
+func (s *Server) Register(addr netip.AddrPort, info Info) (Entry, bool) {
+	addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	s.expire()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.byAddr[addr]
+	if e == nil {
+		if s.opts.MaxPerIP > 0 && s.countIP(addr.Addr()) >= s.opts.MaxPerIP {
+			return Entry{}, false
+		}
+		e = &Entry{Addr: addr}
+		s.byAddr[addr] = e
+		s.log.Info("server registered", "addr", addr, "name", info.Name)
+	}
+	e.Info = info
+	e.ModeName = ""
+	if m := info.Mode(); m != 0 {
+		e.ModeName = m.String()
+	}
+	e.Updated = s.now()
+	return *e, true
+}
+
+// Unregister removes a server with a given address.
+func (s *Server) Unregister(addr netip.AddrPort) bool {
+	addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byAddr[addr]; !ok {
+		return false
+	}
+	delete(s.byAddr, addr)
+	s.log.Info("server unregistered", "addr", addr)
+	return true
+}
+
+func (s *Server) countIP(ip netip.Addr) int {
+	n := 0
+	for addr := range s.byAddr {
+		if addr.Addr() == ip {
+			n++
+		}
+	}
+	return n
+}
+
+// expire removes servers that stopped sending heartbeats.
+func (s *Server) expire() {
+	deadline := s.now().Add(-s.opts.TTL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for addr, e := range s.byAddr {
+		if e.Updated.Before(deadline) {
+			delete(s.byAddr, addr)
+			s.log.Info("server expired", "addr", addr)
+		}
+	}
+}
+
+func remoteIP(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
+
+func parseFilter(r *http.Request) (*Filter, error) {
+	q := r.URL.Query()
+	f := &Filter{Map: q.Get("map")}
+	if v := q.Get("mode"); v != "" {
+		m, err := ParseMode(v)
+		if err != nil {
+			return nil, err
+		}
+		f.Mode = m
+	}
+	if v := q.Get("free"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		f.Free = n
+	}
+	return f, nil
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	switch r.Method {
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	case "HEAD":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		f, err := parseFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.serveJSON(w, s.List(f))
+	}
+}
+
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ip, ok := remoteIP(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req RegisterRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Port == 0 {
+		req.Port = common.GamePort
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Players < 0 || req.MaxPlayers < 0 {
+		http.Error(w, "invalid player count", http.StatusBadRequest)
+		return
+	}
+	e, ok := s.Register(netip.AddrPortFrom(ip, req.Port), req.Info)
+	if !ok {
+		http.Error(w, "too many servers", http.StatusTooManyRequests)
+		return
+	}
+	s.serveJSON(w, e)
+}
+
+func (s *Server) handleUnregister(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ip, ok := remoteIP(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	addr, err := netip.ParseAddrPort(p.ByName("addr"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if addr.Addr().Unmap() != ip {
+		// servers can only remove themselves
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if !s.Unregister(addr) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
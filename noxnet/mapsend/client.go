@@ -0,0 +1,249 @@
+package mapsend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/opennox/libs/maps"
+	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+var ErrNotActive = errors.New("mapsend: no active transfer")
+
+// AbortError is returned when the server aborts the transfer with MSG_MAP_SEND_ABORT.
+type AbortError struct {
+	Code byte
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("mapsend: aborted by server (code %d)", e.Code)
+}
+
+func (e *AbortError) Is(err error) bool {
+	return err == ErrAborted
+}
+
+// ClientOptions configure map receiver.
+type ClientOptions struct {
+	// OnStart is called when the server starts sending the map.
+	OnStart func(name string, size int)
+	// OnProgress is called when the next part of the map is received.
+	OnProgress func(recv, total int)
+}
+
+// NewReceiver creates a map receiver which stores map files into a given directory.
+// If dir is empty, default map directory is used.
+func NewReceiver(log *slog.Logger, dir string, opts *ClientOptions) *Receiver {
+	if log == nil {
+		log = slog.Default()
+	}
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	return &Receiver{
+		log:  log,
+		opts: *opts,
+		d:    maps.NewNativeDownloader(dir),
+	}
+}
+
+// Receiver downloads map files from the server using MSG_MAP_SEND_* messages
+// and writes them with maps.NativeDownloader.
+type Receiver struct {
+	log  *slog.Logger
+	opts ClientOptions
+
+	mu    sync.Mutex
+	d     *maps.NativeDownloader
+	st    udpconn.Stream
+	name  string
+	path  string
+	size  int
+	recv  int
+	done  chan error
+	ended bool
+}
+
+// Download requests the map from the server and waits for it to be received.
+// It returns the path of the downloaded map file.
+//
+// If the context is cancelled, MSG_CANCEL_MAP is sent to the server and partial file is removed.
+func (r *Receiver) Download(ctx context.Context, st udpconn.Stream) (string, error) {
+	if err := r.Expect(st); err != nil {
+		return "", err
+	}
+	if err := st.SendReliable(ctx, &MsgMapRequest{}); err != nil {
+		r.stop(err)
+		return "", err
+	}
+	return r.Wait(ctx)
+}
+
+// Expect prepares the receiver for the map transfer initiated by the server.
+// Replies are sent to a given stream. Use Wait to get the result.
+func (r *Receiver) Expect(st udpconn.Stream) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done != nil && !r.ended {
+		return ErrBusy
+	}
+	r.st = st
+	r.name, r.path = "", ""
+	r.size, r.recv = 0, 0
+	r.done = make(chan error, 1)
+	r.ended = false
+	return nil
+}
+
+// Wait for the current transfer to complete. It returns the path of the downloaded map file.
+func (r *Receiver) Wait(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	done := r.done
+	r.mu.Unlock()
+	if done == nil {
+		return "", ErrNotActive
+	}
+	select {
+	case <-ctx.Done():
+		r.Cancel()
+		return "", ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return "", err
+		}
+		r.mu.Lock()
+		path := r.path
+		r.mu.Unlock()
+		return path, nil
+	}
+}
+
+// Progress returns the number of bytes received and the total map size.
+func (r *Receiver) Progress() (recv, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recv, r.size
+}
+
+// Cancel the current download and send MSG_CANCEL_MAP to the server.
+func (r *Receiver) Cancel() {
+	r.mu.Lock()
+	st := r.st
+	active := r.done != nil && !r.ended
+	r.mu.Unlock()
+	if !active {
+		return
+	}
+	if st.Valid() {
+		_ = st.SendUnreliable(&MsgMapCancel{})
+	}
+	r.stop(ErrCancelled)
+}
+
+// stop the transfer, delete partial file and report an error.
+func (r *Receiver) stop(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done == nil || r.ended {
+		return
+	}
+	if r.d.DownloadOK() {
+		r.d.CancelAndDelete()
+	}
+	r.ended = true
+	r.done <- err
+}
+
+// Handle map transfer messages from the server. It can be registered with udpconn.Port.OnMessage.
+func (r *Receiver) Handle(s udpconn.Stream, m netmsg.Message, _ udpconn.PacketFlags) bool {
+	switch m.(type) {
+	case *MsgMapSendStart, *MsgMapSendPacket, *MsgMapSendAbort:
+	default:
+		return false
+	}
+	r.mu.Lock()
+	if r.done == nil || r.ended || r.st.Conn() != s.Conn() {
+		r.mu.Unlock()
+		return false
+	}
+	switch m := m.(type) {
+	case *MsgMapSendStart:
+		name, err := mapFileName(m.MapName.Value)
+		if err == nil {
+			ext := filepath.Ext(name)
+			path := filepath.Join(r.d.Dir(), strings.TrimSuffix(name, ext), name)
+			err = r.d.Start(path, uint(m.MapSize))
+		}
+		if err != nil {
+			r.mu.Unlock()
+			r.log.Warn("cannot start map download", "name", m.MapName.Value, "err", err)
+			_ = r.st.SendUnreliable(&MsgMapCancel{})
+			r.stop(err)
+			return true
+		}
+		r.name, r.path = name, r.d.Path()
+		r.size, r.recv = int(m.MapSize), 0
+		r.mu.Unlock()
+		if fnc := r.opts.OnStart; fnc != nil {
+			fnc(name, int(m.MapSize))
+		}
+		r.checkDone()
+	case *MsgMapSendPacket:
+		if !r.d.DownloadOK() {
+			r.mu.Unlock()
+			return true
+		}
+		r.d.WritePart(uint(m.Block), m.Data)
+		if r.size > 0 {
+			r.recv = int(math.Round(r.d.Progress() * float64(r.size)))
+		}
+		recv, total := r.recv, r.size
+		r.mu.Unlock()
+		if fnc := r.opts.OnProgress; fnc != nil {
+			fnc(recv, total)
+		}
+		r.checkDone()
+	case *MsgMapSendAbort:
+		r.mu.Unlock()
+		r.stop(&AbortError{Code: m.Code})
+	}
+	return true
+}
+
+// checkDone completes the download if all the map data was received.
+func (r *Receiver) checkDone() {
+	r.mu.Lock()
+	if r.ended || !r.d.Complete() {
+		r.mu.Unlock()
+		return
+	}
+	r.d.Reset() // closes the file
+	r.ended = true
+	st := r.st
+	r.mu.Unlock()
+	st.QueueReliable(udpconn.Options{}, &MsgMapReceived{})
+	_ = st.SendQueue()
+	r.done <- nil
+}
+
+// mapFileName validates map file name sent by the server.
+func mapFileName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	base := filepath.Base(name)
+	if base != name || base == "." || base == ".." || base == "/" {
+		return "", fmt.Errorf("mapsend: invalid map name: %q", name)
+	}
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".map", ".nxz":
+	default:
+		return "", fmt.Errorf("mapsend: unsupported map file: %q", name)
+	}
+	return base, nil
+}
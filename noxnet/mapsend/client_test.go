@@ -0,0 +1,125 @@
+package mapsend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+func newTestReceiver(t testing.TB, sopts *Options, copts *ClientOptions) (*Server, *Receiver, udpconn.Stream, string) {
+	srvC, cliC := udpconn.NewPipe(slog.Default(), 64)
+	t.Cleanup(func() {
+		_ = cliC.Close()
+		_ = srvC.Close()
+	})
+	srv := NewServer(slog.Default(), sopts)
+	t.Cleanup(srv.Close)
+	srvC.Port.OnMessage(srv.Handle)
+	t.Cleanup(srvC.Port.Close)
+
+	dir := t.TempDir()
+	cli := NewReceiver(slog.Default(), dir, copts)
+	cliC.Port.OnMessage(cli.Handle)
+	t.Cleanup(cliC.Port.Close)
+
+	srvC.Port.Start()
+	cliC.Port.Start()
+	st := cliC.Port.Conn(srvC.Addr).Stream(1)
+	return srv, cli, st, dir
+}
+
+func TestReceiverDownload(t *testing.T) {
+	f := &File{Name: "test.nxz", Data: testMapData(20*DefaultBlockSize + 17)}
+	var (
+		started  string
+		progress int
+	)
+	_, cli, st, dir := newTestReceiver(t, &Options{
+		OnRequest: func(s udpconn.Stream) (*File, error) {
+			return f, nil
+		},
+	}, &ClientOptions{
+		OnStart: func(name string, size int) {
+			started = name
+		},
+		OnProgress: func(recv, total int) {
+			progress = recv
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	path, err := cli.Download(ctx, st)
+	must.NoError(t, err)
+	must.EqOp(t, filepath.Join(dir, "test", "test.nxz"), path)
+	must.EqOp(t, "test.nxz", started)
+	must.EqOp(t, len(f.Data), progress)
+
+	data, err := os.ReadFile(path)
+	must.NoError(t, err)
+	must.True(t, bytes.Equal(f.Data, data))
+}
+
+func TestReceiverAbort(t *testing.T) {
+	_, cli, st, _ := newTestReceiver(t, &Options{
+		OnRequest: func(s udpconn.Stream) (*File, error) {
+			return nil, errors.New("no map")
+		},
+	}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := cli.Download(ctx, st)
+	must.ErrorIs(t, err, ErrAborted)
+}
+
+func TestReceiverCancel(t *testing.T) {
+	f := &File{Name: "test.map", Data: testMapData(100 * DefaultBlockSize)}
+	done := make(chan error, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, cli, st, dir := newTestReceiver(t, &Options{
+		Window: 1,
+		OnRequest: func(s udpconn.Stream) (*File, error) {
+			return f, nil
+		},
+		OnDone: func(_ udpconn.Stream, err error) {
+			done <- err
+		},
+	}, &ClientOptions{
+		OnProgress: func(recv, _ int) {
+			if recv >= 5*DefaultBlockSize {
+				cancel()
+			}
+		},
+	})
+	_, err := cli.Download(ctx, st)
+	must.ErrorIs(t, err, context.Canceled)
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	must.ErrorIs(t, err, ErrCancelled)
+	_, err = os.Stat(filepath.Join(dir, "test", "test.map"))
+	must.True(t, os.IsNotExist(err))
+}
+
+func TestMapFileName(t *testing.T) {
+	for _, name := range []string{"estate.map", "Estate.NXZ"} {
+		got, err := mapFileName(name)
+		must.NoError(t, err)
+		must.EqOp(t, name, got)
+	}
+	for _, name := range []string{"", "..", "../estate.map", "maps\\estate.map", "/etc/passwd", "estate.txt"} {
+		_, err := mapFileName(name)
+		must.Error(t, err)
+	}
+}
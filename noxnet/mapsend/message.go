@@ -15,6 +15,8 @@ func init() {
 	netmsg.Register(&MsgMapSendAbort{}, false)
 	netmsg.Register(&MsgMapSendPacket{}, true)
 	netmsg.Register(&MsgMapReceived{}, false)
+	netmsg.Register(&MsgMapRequest{}, false)
+	netmsg.Register(&MsgMapCancel{}, false)
 }
 
 type MsgMapSendStart struct {
@@ -131,7 +133,7 @@ func (*MsgMapReceived) NetOp() netmsg.Op {
 }
 
 func (*MsgMapReceived) EncodeSize() int {
-	return 1
+	return 0
 }
 
 func (*MsgMapReceived) Encode(data []byte) (int, error) {
@@ -141,3 +143,41 @@ func (*MsgMapReceived) Encode(data []byte) (int, error) {
 func (*MsgMapReceived) Decode(data []byte) (int, error) {
 	return 0, nil
 }
+
+type MsgMapRequest struct {
+}
+
+func (*MsgMapRequest) NetOp() netmsg.Op {
+	return netmsg.MSG_REQUEST_MAP
+}
+
+func (*MsgMapRequest) EncodeSize() int {
+	return 0
+}
+
+func (*MsgMapRequest) Encode(data []byte) (int, error) {
+	return 0, nil
+}
+
+func (*MsgMapRequest) Decode(data []byte) (int, error) {
+	return 0, nil
+}
+
+type MsgMapCancel struct {
+}
+
+func (*MsgMapCancel) NetOp() netmsg.Op {
+	return netmsg.MSG_CANCEL_MAP
+}
+
+func (*MsgMapCancel) EncodeSize() int {
+	return 0
+}
+
+func (*MsgMapCancel) Encode(data []byte) (int, error) {
+	return 0, nil
+}
+
+func (*MsgMapCancel) Decode(data []byte) (int, error) {
+	return 0, nil
+}
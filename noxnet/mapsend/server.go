@@ -1,12 +1,359 @@
 package mapsend
 
-/*
-type Conn interface {
-	comparable
-	SendReliableMsg(m Msg) error
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/opennox/libs/binenc"
+	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+const (
+	DefaultBlockSize = 512
+	DefaultWindow    = 16
+	maxBlocks        = 0xffff
+)
+
+var (
+	ErrBusy      = errors.New("mapsend: transfer already in progress")
+	ErrCancelled = errors.New("mapsend: cancelled by client")
+	ErrAborted   = errors.New("mapsend: aborted")
+	ErrTimeout   = errors.New("mapsend: timeout")
+	ErrNoMap     = errors.New("mapsend: no map to send")
+)
+
+// File is a map file sent to the client.
+type File struct {
+	// Name is a file name reported to the client, for example "estate.map" or "estate.nxz".
+	Name string
+	Data []byte
+}
+
+// ReadFile reads a map file (either .map or .nxz) for sending.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Name: filepath.Base(path), Data: data}, nil
+}
+
+// ReadMap reads a map with a given name from a directory, preferring compressed .nxz version if it exists.
+func ReadMap(dir, name string) (*File, error) {
+	ext := filepath.Ext(name)
+	base := name[:len(name)-len(ext)]
+	mdir := filepath.Join(dir, base)
+	if f, err := ReadFile(filepath.Join(mdir, base+".nxz")); err == nil {
+		return f, nil
+	}
+	return ReadFile(filepath.Join(mdir, base+".map"))
+}
+
+// ProgressFunc is called when the client acknowledges a part of the map.
+type ProgressFunc func(s udpconn.Stream, sent, total int)
+
+// DoneFunc is called when the transfer completes. Error is nil if the client received the map.
+type DoneFunc func(s udpconn.Stream, err error)
+
+// RequestFunc is called when the client requests a map with MSG_REQUEST_MAP.
+type RequestFunc func(s udpconn.Stream) (*File, error)
+
+type Options struct {
+	// BlockSize is a maximal size of a single MSG_MAP_SEND_PACKET payload.
+	BlockSize int
+	// Window is a maximal number of unacknowledged blocks sent to a single client.
+	Window int
+
+	OnRequest  RequestFunc
+	OnProgress ProgressFunc
+	OnDone     DoneFunc
+}
+
+func NewServer(log *slog.Logger, opts *Options) *Server {
+	if log == nil {
+		log = slog.Default()
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	s := &Server{
+		log:    log,
+		opts:   *opts,
+		byConn: make(map[udpconn.Stream]*transfer),
+	}
+	if s.opts.BlockSize <= 0 {
+		s.opts.BlockSize = DefaultBlockSize
+	}
+	if s.opts.Window <= 0 {
+		s.opts.Window = DefaultWindow
+	}
+	return s
+}
+
+// Server streams map files to clients using MSG_MAP_SEND_* messages.
+type Server struct {
+	log  *slog.Logger
+	opts Options
+
+	mu     sync.Mutex
+	byConn map[udpconn.Stream]*transfer
+}
+
+type transfer struct {
+	s       *Server
+	st      udpconn.Stream
+	f       *File
+	blocks  int
+	next    int // next block to send, 1-based
+	pending map[udpconn.PID]int
+	acked   int // bytes
+	done    chan struct{}
+	err     error // set before done is closed
+	closed  bool
+}
+
+// Start starts sending a map file to the client. It returns immediately.
+//
+// Use Wait or Options.OnDone to get the transfer result.
+func (s *Server) Start(st udpconn.Stream, f *File) error {
+	_, err := s.start(st, f)
+	return err
+}
+
+func (s *Server) start(st udpconn.Stream, f *File) (*transfer, error) {
+	if f == nil {
+		return nil, ErrNoMap
+	}
+	blocks := (len(f.Data) + s.opts.BlockSize - 1) / s.opts.BlockSize
+	if blocks > maxBlocks {
+		return nil, fmt.Errorf("mapsend: map is too large: %d bytes", len(f.Data))
+	}
+	s.mu.Lock()
+	if _, ok := s.byConn[st]; ok {
+		s.mu.Unlock()
+		return nil, ErrBusy
+	}
+	t := &transfer{
+		s:       s,
+		st:      st,
+		f:       f,
+		blocks:  blocks,
+		next:    1,
+		pending: make(map[udpconn.PID]int),
+		done:    make(chan struct{}),
+	}
+	s.byConn[st] = t
+	t.queue(&MsgMapSendStart{
+		MapSize: uint32(len(f.Data)),
+		MapName: binenc.String{Value: f.Name},
+	}, 0)
+	t.fill()
+	s.mu.Unlock()
+	_ = st.SendQueue()
+	return t, nil
+}
+
+// Send a map file to the client and wait for it to be received.
+func (s *Server) Send(ctx context.Context, st udpconn.Stream, f *File) error {
+	t, err := s.start(st, f)
+	if err != nil {
+		return err
+	}
+	return s.wait(ctx, t)
+}
+
+// Wait for the current transfer to the client to complete.
+// If the context is cancelled, the transfer is aborted.
+//
+// It returns ErrNotActive if there's no transfer in progress, including when it already finished.
+// Use Send or Options.OnDone to reliably get the result.
+func (s *Server) Wait(ctx context.Context, st udpconn.Stream) error {
+	s.mu.Lock()
+	t := s.byConn[st]
+	s.mu.Unlock()
+	if t == nil {
+		return ErrNotActive
+	}
+	return s.wait(ctx, t)
+}
+
+func (s *Server) wait(ctx context.Context, t *transfer) error {
+	select {
+	case <-ctx.Done():
+		s.abort(t, 0)
+		return ctx.Err()
+	case <-t.done:
+		return t.err
+	}
+}
+
+// Progress returns the number of bytes acknowledged by the client and the total map size.
+func (s *Server) Progress(st udpconn.Stream) (sent, total int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.byConn[st]
+	if t == nil {
+		return 0, 0, false
+	}
+	return t.acked, len(t.f.Data), true
+}
+
+// Abort the transfer to the client and send MSG_MAP_SEND_ABORT with a given code.
+func (s *Server) Abort(st udpconn.Stream, code byte) {
+	s.mu.Lock()
+	t := s.byConn[st]
+	s.mu.Unlock()
+	if t != nil {
+		s.abort(t, code)
+	}
+}
+
+func (s *Server) abort(t *transfer, code byte) {
+	s.mu.Lock()
+	if t.closed {
+		s.mu.Unlock()
+		return
+	}
+	t.cancel()
+	t.queue(&MsgMapSendAbort{Code: code}, 0)
+	s.mu.Unlock()
+	_ = t.st.SendQueue()
+	s.finish(t, ErrAborted)
+}
+
+// Close aborts all active transfers.
+func (s *Server) Close() {
+	s.mu.Lock()
+	list := make([]udpconn.Stream, 0, len(s.byConn))
+	for st := range s.byConn {
+		list = append(list, st)
+	}
+	s.mu.Unlock()
+	for _, st := range list {
+		s.Abort(st, 0)
+	}
+}
+
+// Handle map transfer messages from the client. It can be registered with udpconn.Port.OnMessage.
+func (s *Server) Handle(st udpconn.Stream, m netmsg.Message, _ udpconn.PacketFlags) bool {
+	switch m.(type) {
+	default:
+		return false
+	case *MsgMapRequest:
+		if s.opts.OnRequest == nil {
+			return false
+		}
+		f, err := s.opts.OnRequest(st)
+		if err == nil {
+			err = s.Start(st, f)
+		}
+		if errors.Is(err, ErrBusy) {
+			return true // already sending
+		} else if err != nil {
+			s.log.Warn("cannot send map", "addr", st.Addr(), "err", err)
+			_ = st.SendUnreliable(&MsgMapSendAbort{})
+		}
+		return true
+	case *MsgMapCancel:
+		s.mu.Lock()
+		t := s.byConn[st]
+		if t != nil {
+			t.cancel()
+		}
+		s.mu.Unlock()
+		if t != nil {
+			s.finish(t, ErrCancelled)
+		}
+		return true
+	case *MsgMapReceived:
+		s.mu.Lock()
+		t := s.byConn[st]
+		s.mu.Unlock()
+		if t != nil {
+			s.finish(t, nil)
+		}
+		return true
+	}
+}
+
+func (s *Server) finish(t *transfer, err error) {
+	s.mu.Lock()
+	if t.closed {
+		s.mu.Unlock()
+		return
+	}
+	t.closed = true
+	if s.byConn[t.st] == t {
+		delete(s.byConn, t.st)
+	}
+	t.err = err
+	s.mu.Unlock()
+	close(t.done)
+	if s.opts.OnDone != nil {
+		s.opts.OnDone(t.st, err)
+	}
+}
+
+// queue a reliable message. Must be called with the server lock held.
+func (t *transfer) queue(m netmsg.Message, size int) {
+	var pid udpconn.PID
+	pid = t.st.QueueReliable(udpconn.Options{
+		OnDone: func() {
+			t.onAck(pid)
+		},
+		OnTimeout: func() {
+			t.s.mu.Lock()
+			t.cancel()
+			t.s.mu.Unlock()
+			t.s.finish(t, ErrTimeout)
+		},
+	}, m)
+	t.pending[pid] = size
 }
 
-func NewServer() *Server {
+// fill the window with map blocks. Must be called with the server lock held.
+func (t *transfer) fill() {
+	bsz := t.s.opts.BlockSize
+	for len(t.pending) < t.s.opts.Window && t.next <= t.blocks {
+		off := (t.next - 1) * bsz
+		end := min(off+bsz, len(t.f.Data))
+		t.queue(&MsgMapSendPacket{
+			Block: uint16(t.next),
+			Data:  t.f.Data[off:end],
+		}, end-off)
+		t.next++
+	}
+}
+
+// cancel all pending packets. Must be called with the server lock held.
+func (t *transfer) cancel() {
+	for pid := range t.pending {
+		t.st.CancelReliable(pid)
+	}
+	clear(t.pending)
+}
 
+func (t *transfer) onAck(pid udpconn.PID) {
+	s := t.s
+	s.mu.Lock()
+	size, ok := t.pending[pid]
+	if !ok || t.closed {
+		s.mu.Unlock()
+		return
+	}
+	delete(t.pending, pid)
+	t.acked += size
+	sent, total := t.acked, len(t.f.Data)
+	t.fill()
+	s.mu.Unlock()
+	_ = t.st.SendQueue()
+	if size != 0 && s.opts.OnProgress != nil {
+		s.opts.OnProgress(t.st, sent, total)
+	}
 }
-*/
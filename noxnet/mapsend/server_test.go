@@ -0,0 +1,204 @@
+package mapsend
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test/must"
+	"github.com/shoenig/test/wait"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+type testClient struct {
+	mu     sync.Mutex
+	name   string
+	size   int
+	data   []byte
+	blocks int
+	abort  bool
+}
+
+func newTestServer(t testing.TB, opts *Options, onPacket func(s udpconn.Stream, c *testClient)) (*Server, udpconn.Stream, *testClient) {
+	srvC, cliC := udpconn.NewPipe(slog.Default(), 64)
+	t.Cleanup(func() {
+		_ = cliC.Close()
+		_ = srvC.Close()
+	})
+	srv := NewServer(slog.Default(), opts)
+	t.Cleanup(srv.Close)
+	srvC.Port.OnMessage(srv.Handle)
+	t.Cleanup(srvC.Port.Close)
+
+	c := &testClient{}
+	cliC.Port.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		c.mu.Lock()
+		switch m := m.(type) {
+		case *MsgMapSendStart:
+			c.name = m.MapName.Value
+			c.size = int(m.MapSize)
+		case *MsgMapSendPacket:
+			if int(m.Block) == c.blocks+1 {
+				c.blocks++
+				c.data = append(c.data, m.Data...)
+			}
+		case *MsgMapSendAbort:
+			c.abort = true
+		}
+		c.mu.Unlock()
+		_ = s.Conn().Ack()
+		if onPacket != nil {
+			onPacket(s, c)
+		}
+		return true
+	})
+	t.Cleanup(cliC.Port.Close)
+
+	srvC.Port.Start()
+	cliC.Port.Start()
+	st := srvC.Port.Conn(cliC.Addr).Stream(1)
+	return srv, st, c
+}
+
+func testMapData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestServerSend(t *testing.T) {
+	f := &File{Name: "test.map", Data: testMapData(10*DefaultBlockSize + 100)}
+	var (
+		pmu      sync.Mutex
+		progress []int
+	)
+	srv, st, c := newTestServer(t, &Options{
+		Window: 3,
+		OnProgress: func(_ udpconn.Stream, sent, total int) {
+			pmu.Lock()
+			defer pmu.Unlock()
+			must.EqOp(t, len(f.Data), total)
+			progress = append(progress, sent)
+		},
+	}, func(s udpconn.Stream, c *testClient) {
+		c.mu.Lock()
+		done := c.size != 0 && len(c.data) == c.size
+		c.mu.Unlock()
+		if done {
+			_ = s.SendUnreliable(&MsgMapReceived{})
+		}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := srv.Send(ctx, st, f)
+	must.NoError(t, err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	must.EqOp(t, "test.map", c.name)
+	must.EqOp(t, len(f.Data), c.size)
+	must.EqOp(t, 11, c.blocks)
+	must.True(t, bytes.Equal(f.Data, c.data))
+
+	pmu.Lock()
+	defer pmu.Unlock()
+	must.SliceNotEmpty(t, progress)
+	must.EqOp(t, len(f.Data), progress[len(progress)-1])
+	for i := 1; i < len(progress); i++ {
+		must.Greater(t, progress[i-1], progress[i])
+	}
+}
+
+func TestServerCancel(t *testing.T) {
+	f := &File{Name: "test.nxz", Data: testMapData(100 * DefaultBlockSize)}
+	done := make(chan error, 1)
+	srv, st, _ := newTestServer(t, &Options{
+		Window: 1,
+		OnDone: func(_ udpconn.Stream, err error) {
+			done <- err
+		},
+	}, func(s udpconn.Stream, c *testClient) {
+		c.mu.Lock()
+		cancel := c.blocks == 5
+		c.mu.Unlock()
+		if cancel {
+			_ = s.SendUnreliable(&MsgMapCancel{})
+		}
+	})
+	err := srv.Start(st, f)
+	must.NoError(t, err)
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	must.ErrorIs(t, err, ErrCancelled)
+	_, _, ok := srv.Progress(st)
+	must.False(t, ok)
+}
+
+func TestServerAbort(t *testing.T) {
+	f := &File{Name: "test.map", Data: testMapData(100 * DefaultBlockSize)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv, st, c := newTestServer(t, &Options{
+		Window: 1,
+		OnProgress: func(_ udpconn.Stream, sent, _ int) {
+			if sent >= 3*DefaultBlockSize {
+				cancel()
+			}
+		},
+	}, nil)
+	err := srv.Send(ctx, st, f)
+	must.ErrorIs(t, err, context.Canceled)
+	must.Wait(t, wait.InitialSuccess(wait.BoolFunc(func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.abort
+	}), wait.Timeout(time.Second)))
+}
+
+func TestServerWait(t *testing.T) {
+	f := &File{Name: "test.map", Data: testMapData(100 * DefaultBlockSize)}
+	srv, st, _ := newTestServer(t, &Options{Window: 1}, func(s udpconn.Stream, c *testClient) {
+		// cancel as soon as the transfer starts
+		_ = s.SendUnreliable(&MsgMapCancel{})
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := srv.Start(st, f)
+	must.NoError(t, err)
+	// all waiters get the result
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			errs <- srv.Wait(ctx, st)
+		}()
+	}
+	for range 2 {
+		select {
+		case err = <-errs:
+			// the transfer may finish before Wait is called
+			if !errors.Is(err, ErrNotActive) {
+				must.ErrorIs(t, err, ErrCancelled)
+			}
+		case <-ctx.Done():
+			t.Fatal("timeout")
+		}
+	}
+
+	// finished transfer is never reported as a success
+	err = srv.Wait(ctx, st)
+	must.ErrorIs(t, err, ErrNotActive)
+	err = srv.Send(ctx, st, f)
+	must.ErrorIs(t, err, ErrCancelled)
+}
@@ -6,15 +6,18 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/shoenig/test/must"
 
 	"github.com/opennox/libs/binenc"
+	"github.com/opennox/libs/maps"
 	"github.com/opennox/libs/noxnet/discover"
 	"github.com/opennox/libs/noxnet/mapsend"
 	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/noxnet/netmsg/msgtest"
 	"github.com/opennox/libs/noxnet/netxfer"
 	"github.com/opennox/libs/types"
 )
@@ -56,6 +59,16 @@ func TestDecodePacket(t *testing.T) {
 				Version:    0x1039a,
 			},
 		},
+		{
+			name: "server join class",
+			packet: &MsgServerTryJoin{
+				PlayerName:  "Jack",
+				PlayerClass: 2,
+				PlayerLevel: 10,
+				Serial:      "1234567890123456789012",
+				Version:     0x1039a,
+			},
+		},
 		{
 			name: "server accept",
 			packets: []netmsg.Message{
@@ -109,6 +122,10 @@ func TestDecodePacket(t *testing.T) {
 				T: 12561,
 			},
 		},
+		{
+			name:   "need timestamp",
+			packet: &MsgNeedTimestamp{},
+		},
 		{
 			name: "join data",
 			packet: &MsgJoinData{
@@ -127,6 +144,14 @@ func TestDecodePacket(t *testing.T) {
 				T:   12561,
 			},
 		},
+		{
+			name: "use map full name",
+			packet: &MsgUseMap{
+				MapName: binenc.String{Value: "abcdefghijklmnopqrstuvwxyz012345"},
+				CRC:     0x6765031d,
+				T:       12561,
+			},
+		},
 		{
 			name: "player input",
 			packet: &MsgPlayerInput{
@@ -219,6 +244,174 @@ func TestDecodePacket(t *testing.T) {
 			name:   "fx jiggle",
 			packet: &MsgFxJiggle{Val: 17},
 		},
+		{
+			name:   "fx blue sparks",
+			packet: &MsgFxBlueSparks{FxPoint{Pos: image.Point{X: 2530, Y: 1840}}},
+		},
+		{
+			name:   "fx explosion",
+			packet: &MsgFxExplosion{FxPoint{Pos: image.Point{X: 3592, Y: 3868}}},
+		},
+		{
+			name: "fx lightning",
+			packet: &MsgFxLightning{FxLine{
+				From: image.Point{X: 3592, Y: 3868},
+				To:   image.Point{X: 3829, Y: 3900},
+			}},
+		},
+		{
+			name: "fx death ray",
+			packet: &MsgFxDeathRay{FxLine{
+				From: image.Point{X: 1000, Y: 1200},
+				To:   image.Point{X: 1100, Y: 1250},
+			}},
+		},
+		{
+			name:   "fx spark explosion",
+			packet: &MsgFxSparkExplosion{Pos: image.Point{X: 2530, Y: 1840}, Size: 30},
+		},
+		{
+			name: "fx particle",
+			packet: &MsgFxParticle{
+				Kind:  2,
+				Pos:   image.Point{X: 2530, Y: 1840},
+				Color: types.RGB{R: 255, G: 128, B: 0},
+			},
+		},
+		{
+			name:   "fx plasma",
+			packet: &MsgFxPlasma{FxTarget{Source: 192, Target: 935}},
+		},
+		{
+			name: "fx summon",
+			packet: &MsgFxSummon{
+				NetCode:  1207,
+				Pos:      image.Point{X: 2530, Y: 1840},
+				Dir:      64,
+				Type:     1354,
+				Duration: 120,
+			},
+		},
+		{
+			name:   "fx summon cancel",
+			packet: &MsgFxSummonCancel{NetCode: 1207},
+		},
+		{
+			name:   "fx shield",
+			packet: &MsgFxShield{NetCode: 192, Dir: 3},
+		},
+		{
+			name:   "fx duration spell",
+			packet: &MsgFxDurationSpell{Spell: 43, NetCode: 192, Duration: 300},
+		},
+		{
+			name:   "fx deltaz spell start",
+			packet: &MsgFxDeltaZSpellStart{Spell: 12, FxTarget: FxTarget{Source: 192, Target: 935}},
+		},
+		{
+			name: "fx vampirism",
+			packet: &MsgFxVampirism{
+				FxTarget: FxTarget{Source: 192, Target: 935},
+				Pos:      image.Point{X: 2530, Y: 1840},
+				Amount:   5,
+			},
+		},
+		{
+			name:   "fx generating map",
+			packet: &MsgFxGeneratingMap{FxProgress{Progress: 50}},
+		},
+		{
+			name:   "try use",
+			packet: &MsgTryUse{TryObject{NetCode: 1204}},
+		},
+		{
+			name:   "try equip",
+			packet: &MsgTryEquip{TryObject{NetCode: 1205}},
+		},
+		{
+			name:   "try creature command",
+			packet: &MsgTryCreatureCommand{NetCode: 1207, Command: 2},
+		},
+		{
+			name:   "try spell",
+			packet: &MsgTrySpell{Spells: [5]uint32{27, 0, 0, 0, 0}, Flags: 1},
+		},
+		{
+			name:   "try ability",
+			packet: &MsgTryAbility{Ability: 1},
+		},
+		{
+			name: "simple obj",
+			packet: &MsgSimpleObject{
+				NetCode: 1204,
+				Type:    613,
+				Pos:     image.Point{X: 2530, Y: 1840},
+			},
+		},
+		{
+			name: "complex obj",
+			packet: &MsgComplexObject{
+				NetCode: 935,
+				Type:    1354,
+				Pos:     image.Point{X: 3592, Y: 3868},
+				Complex: ComplexObjectUpdate{Unk0: 64, Unk1: 2, Unk2: 5},
+			},
+		},
+		{
+			name:   "destroy object",
+			packet: &MsgDestroyObject{ObjectCode{NetCode: 1204}},
+		},
+		{
+			name:   "object out of sight",
+			packet: &MsgObjectOutOfSight{ObjectCode{NetCode: 935}},
+		},
+		{
+			name:   "object friend add",
+			packet: &MsgObjectFriendAdd{ObjectCode{NetCode: 1207}},
+		},
+		{
+			name:   "reset friends",
+			packet: &MsgResetFriends{},
+		},
+		{
+			name:   "disable object",
+			packet: &MsgDisableObject{ObjectCode{NetCode: 1204}},
+		},
+		{
+			name:   "wall open",
+			packet: &MsgWallOpen{WallRef{Pos: maps.WallPos{X: 110, Y: 84}}},
+		},
+		{
+			name:   "wall close",
+			packet: &MsgWallClose{WallRef{Pos: maps.WallPos{X: 110, Y: 84}}},
+		},
+		{
+			name: "wall magic",
+			packet: &MsgWallMagic{
+				Pos:      maps.WallPos{X: 57, Y: 203},
+				Dir:      1,
+				Material: 12,
+				Variant:  2,
+			},
+		},
+		{
+			name:   "wall magic remove",
+			packet: &MsgWallMagicRemove{WallRef{Pos: maps.WallPos{X: 57, Y: 203}}},
+		},
+		{
+			name:   "forget drawables",
+			packet: &MsgForgetDrawables{Unk0: 0x1234},
+		},
+		{
+			name:     "server cmd",
+			toClient: true,
+			packet:   &MsgServerCmd{ID: 1, NetCode: 0x1d, Cmd: "set spell SPELL_BURN off"},
+		},
+		{
+			name:     "server cmd utf16",
+			toClient: true,
+			packet:   &MsgServerCmd{ID: 2, NetCode: 0x1d, Cmd: "say привет"},
+		},
 		{
 			name: "map send start",
 			packet: &mapsend.MsgMapSendStart{
@@ -235,6 +428,10 @@ func TestDecodePacket(t *testing.T) {
 				Data:  []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
 			},
 		},
+		{
+			name:   "map received",
+			packet: &mapsend.MsgMapReceived{},
+		},
 		{
 			name: "stat mult",
 			packet: &MsgStatMult{
@@ -344,12 +541,149 @@ func TestDecodePacket(t *testing.T) {
 			},
 		},
 		{
-			name: "client status",
-			packet: &netmsg.Unknown{
-				Op:   netmsg.MSG_REPORT_CLIENT_STATUS,
-				Data: []uint8{0xc0, 0x00, 0x00, 0x00, 0x00, 0x00},
+			name:   "report health",
+			packet: &MsgReportHealth{Health: 75, Max: 150},
+		},
+		{
+			name:   "report health delta",
+			packet: &MsgReportHealthDelta{NetCode: 935, Delta: -12},
+		},
+		{
+			name:   "report player health",
+			packet: &MsgReportPlayerHealth{Player: 2, Percent: 80},
+		},
+		{
+			name:   "report item health",
+			packet: &MsgReportItemHealth{NetCode: 1204, Health: 30, Max: 60},
+		},
+		{
+			name:   "report mana",
+			packet: &MsgReportMana{Mana: 40, Max: 125},
+		},
+		{
+			name:   "report poison",
+			packet: &MsgReportPoison{NetCode: 192, Level: 3},
+		},
+		{
+			name:   "report stamina",
+			packet: &MsgReportStamina{Stamina: 100},
+		},
+		{
+			name: "report stats",
+			packet: &MsgReportStats{
+				Health:    150,
+				Mana:      125,
+				Weight:    40,
+				Speed:     8,
+				Strength:  55,
+				MaxWeight: 200,
+				Level:     4,
 			},
 		},
+		{
+			name:   "report armor value",
+			packet: &MsgReportArmorValue{Value: 42},
+		},
+		{
+			name:   "report gold",
+			packet: &MsgReportGold{Gold: 1500},
+		},
+		{
+			name:   "report lesson",
+			packet: &MsgReportLesson{NetCode: 192, Lessons: 7},
+		},
+		{
+			name:   "report experience",
+			packet: &MsgReportExperience{Level: 3, Exp: 1250.5},
+		},
+		{
+			name:   "report pickup",
+			packet: &MsgReportPickup{NetCode: 1204, Type: 613},
+		},
+		{
+			name:   "report modifiable pickup",
+			packet: &MsgReportModifiablePickup{NetCode: 1204, Type: 613, Mods: ItemMods{1, 2, 0, 0xff}},
+		},
+		{
+			name:   "report drop",
+			packet: &MsgReportDrop{NetCode: 1204},
+		},
+		{
+			name:   "report mundane armor equip",
+			packet: &MsgReportMundaneArmorEquip{ItemSlot{NetCode: 1204, Slot: 0x2}},
+		},
+		{
+			name:   "report modifiable weapon equip",
+			packet: &MsgReportModifiableWeaponEquip{ModItemSlot{NetCode: 1205, Slot: 0x4000, Mods: ItemMods{3, 4, 5, 6}}},
+		},
+		{
+			name:   "report weapon dequip",
+			packet: &MsgReportWeaponDequip{ItemSlot{NetCode: 1205, Slot: 0x4000}},
+		},
+		{
+			name:   "report equip",
+			packet: &MsgReportEquip{NetCode: 1205},
+		},
+		{
+			name:   "report enchantment",
+			packet: &MsgReportEnchantment{NetCode: 192, Enchants: 0x20001},
+		},
+		{
+			name:   "report charges",
+			packet: &MsgReportCharges{NetCode: 1206, Charges: 5, Max: 10},
+		},
+		{
+			name:   "report inventory loaded",
+			packet: &MsgReportInventoryLoaded{},
+		},
+		{
+			name:   "report spell award",
+			packet: &MsgReportSpellAward{Spell: 27, Level: 2},
+		},
+		{
+			name:   "report spell start",
+			packet: &MsgReportSpellStart{Spell: 27},
+		},
+		{
+			name:   "client status",
+			packet: &MsgReportClientStatus{ObjectValue{NetCode: 192}},
+		},
+		{
+			name:   "report item enchantment",
+			packet: &MsgReportItemEnchantment{Unk0: 3},
+		},
+		{
+			name:   "report z plus",
+			packet: &MsgReportZPlus{ObjectByte{NetCode: 1204, Value: 5}},
+		},
+		{
+			name:   "report z minus",
+			packet: &MsgReportZMinus{ObjectByte{NetCode: 1204, Value: 5}},
+		},
+		{
+			name:   "report x status",
+			packet: &MsgReportXStatus{ObjectValue{NetCode: 192, Value: 0x10}},
+		},
+		{
+			name:   "report modifier",
+			packet: &MsgReportModifier{ObjectValue{NetCode: 1205, Value: 0x01020304}},
+		},
+		{
+			name:   "report stat modifier",
+			packet: &MsgReportStatModifier{NetCode: 192, Unk2: 1, Unk3: 20},
+		},
+		{
+			name:   "report animation frame",
+			packet: &MsgReportAnimationFrame{ObjectValue{NetCode: 1300, Value: 7}},
+		},
+		{
+			name:   "report flag winner",
+			packet: &MsgReportFlagWinner{GameWinner{Unk0: 2, Unk2: 5, Unk6: 1}},
+		},
+		{
+			name:   "report deathmatch winner",
+			packet: &MsgReportDeathmatchWinner{GameWinner{Unk0: 192, Unk2: 10}},
+		},
 	}
 	for _, c := range cases {
 		c := c
@@ -403,3 +737,180 @@ func TestDecodePacket(t *testing.T) {
 		})
 	}
 }
+
+func TestServerInfoMapName(t *testing.T) {
+	data, err := os.ReadFile("testdata/server_info.dat")
+	must.NoError(t, err)
+	// map name is limited to 8 characters, the last byte of the field must be ignored
+	data[1+15] = 'X'
+	var p discover.MsgServerInfo
+	_, err = p.Decode(data[1:])
+	must.NoError(t, err)
+	must.EqOp(t, "BluDeath", p.MapName)
+}
+
+func TestServerXorKey(t *testing.T) {
+	packet := func(hdr []byte, msgs ...netmsg.Message) []byte {
+		data := hdr
+		for _, m := range msgs {
+			var err error
+			data, err = netmsg.Append(data, m)
+			must.NoError(t, err)
+		}
+		return data
+	}
+	cases := []struct {
+		name string
+		data []byte
+		key  byte
+		ok   bool
+	}{
+		{"accept", packet([]byte{0x80, 0}, &MsgAccept{ID: 1}, &MsgServerAccept{ID: 1, XorKey: 0x5a}, &MsgFullTimestamp{T: 5}), 0x5a, true},
+		{"server accept", packet([]byte{0x80, 0}, &MsgServerAccept{ID: 1, XorKey: 0x12}), 0x12, true},
+		{"player stream", packet([]byte{0x81, 0}, &MsgServerAccept{ID: 1, XorKey: 0x12}), 0, false},
+		{"other", packet([]byte{0x80, 0}, &MsgAccept{ID: 1}, &MsgFullTimestamp{T: 5}), 0, false},
+		{"truncated", packet([]byte{0x80, 0}, &MsgAccept{ID: 1}, &MsgServerAccept{ID: 1, XorKey: 0x12})[:8], 0, false},
+		{"short", []byte{0x80, 0}, 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			key, ok := ServerXorKey(c.data)
+			must.EqOp(t, c.ok, ok)
+			must.EqOp(t, c.key, key)
+
+			data := slices.Clone(c.data)
+			key, ok = ClearServerXorKey(data)
+			must.EqOp(t, c.ok, ok)
+			must.EqOp(t, c.key, key)
+			if ok {
+				key, ok = ServerXorKey(data)
+				must.True(t, ok)
+				must.EqOp(t, 0, key)
+			}
+		})
+	}
+}
+
+func TestMsgTextSetText(t *testing.T) {
+	cases := []struct {
+		name    string
+		msg     MsgText
+		text    string
+		exp     string
+		payload []byte
+		size    byte
+	}{
+		{
+			name: "utf8",
+			msg:  MsgText{Flags: TextUTF8, Size: 13, Data: []byte("hello global\x00")},
+			text: "bye", exp: "bye",
+			size: 4,
+		},
+		{
+			name: "utf8 payload",
+			msg:  MsgText{Flags: TextUTF8 | TextExt, Size: 5, Data: []byte("\x001234")},
+			text: "hi", exp: "hi",
+			payload: []byte("1234"),
+			size:    7,
+		},
+		{
+			name: "utf16 payload",
+			msg:  MsgText{Flags: TextExt, Size: 5, Data: []byte("\x00\x0012345678")},
+			text: "привет", exp: "привет",
+			payload: []byte("12345678"),
+			size:    11,
+		},
+		{
+			name: "utf8 truncate",
+			msg:  MsgText{Flags: TextUTF8},
+			text: strings.Repeat("a", 300), exp: strings.Repeat("a", 254),
+			size: 0xff,
+		},
+		{
+			name: "utf16 odd payload",
+			msg:  MsgText{Flags: TextExt, Size: 3, Data: []byte("\x00\x00123")},
+			text: "hi", exp: "hi",
+			payload: []byte("123\x00"),
+			size:    5,
+		},
+		{
+			name: "utf16 truncate surrogate",
+			msg:  MsgText{Flags: TextExt, Size: 2, Data: []byte("\x00\x0012")},
+			text: strings.Repeat("a", 252) + "😀", exp: strings.Repeat("a", 252),
+			payload: []byte("12"),
+			size:    254,
+		},
+		{
+			name: "utf16 keep surrogate",
+			msg:  MsgText{Flags: TextExt, Size: 2, Data: []byte("\x00\x0012")},
+			text: strings.Repeat("a", 251) + "😀!", exp: strings.Repeat("a", 251) + "😀",
+			payload: []byte("12"),
+			size:    0xff,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := c.msg
+			p.SetText(c.text)
+			must.EqOp(t, c.exp, p.Text())
+			must.Eq(t, c.payload, p.Payload())
+			must.EqOp(t, c.size, p.Size)
+			// must survive encoding
+			data, err := netmsg.Append(nil, &p)
+			must.NoError(t, err)
+			var p2 MsgText
+			_, err = p2.Decode(data[1:])
+			must.NoError(t, err)
+			must.EqOp(t, c.exp, p2.Text())
+			must.Eq(t, c.payload, p2.Payload())
+		})
+	}
+}
+
+func TestStateFrame(t *testing.T) {
+	st := &netmsg.State{Options: netmsg.Options{IsClient: true}}
+	decode := func(m netmsg.Message) {
+		t.Helper()
+		data, err := netmsg.Append(nil, m)
+		must.NoError(t, err)
+		_, _, err = st.DecodeNext(data)
+		must.NoError(t, err)
+	}
+	decode(&MsgTimestamp{T: 0x1000})
+	must.EqOp(t, 0x1000, st.Frame())
+	must.False(t, st.FrameSynced())
+
+	decode(&MsgUseMap{MapName: binenc.String{Value: "estate"}, T: 0x2fff0})
+	must.EqOp(t, 0x2fff0, st.Frame())
+	must.True(t, st.FrameSynced())
+
+	decode(&MsgTimestamp{T: 0xfff5})
+	must.EqOp(t, 0x2fff5, st.Frame())
+	decode(&MsgTimestamp{T: 0xfff1}) // out of order
+	must.EqOp(t, 0x2fff5, st.Frame())
+	decode(&MsgSeqImportant{ID: 1, Msg: &MsgTimestamp{T: 0x0003}}) // overflow
+	must.EqOp(t, 0x30003, st.Frame())
+
+	// the client requests the resync; the server doesn't track the frame from MsgNeedTimestamp
+	srv := &netmsg.State{}
+	data, err := netmsg.Append(nil, &MsgNeedTimestamp{})
+	must.NoError(t, err)
+	_, _, err = srv.DecodeNext(data)
+	must.NoError(t, err)
+	must.EqOp(t, 0, srv.Frame())
+	must.True(t, st.FrameSynced())
+	st.ResyncFrame()
+	must.False(t, st.FrameSynced())
+	must.EqOp(t, 0x30003, st.Frame())
+	decode(&MsgFullTimestamp{T: 0x30010})
+	must.EqOp(t, 0x30010, st.Frame())
+	must.True(t, st.FrameSynced())
+}
+
+func TestMessagesConformance(t *testing.T) {
+	msgtest.CheckRegistered(t, nil)
+}
+
+func FuzzMessages(f *testing.F) {
+	msgtest.Fuzz(f, nil)
+}
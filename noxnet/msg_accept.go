@@ -74,6 +74,52 @@ func (p *MsgServerAccept) Decode(data []byte) (int, error) {
 	return 5, nil
 }
 
+// ServerXorKey returns the xor key from the server accept packet, if data contains one.
+//
+// Data must be a whole packet, including the header. The key is sent in MsgServerAccept,
+// optionally preceded by MsgAccept, on the server stream.
+func ServerXorKey(data []byte) (byte, bool) {
+	i := serverXorKeyOffset(data)
+	if i < 0 {
+		return 0, false
+	}
+	return data[i], true
+}
+
+// ClearServerXorKey is similar to ServerXorKey, but also sets the key in the packet to zero, disabling encryption.
+func ClearServerXorKey(data []byte) (byte, bool) {
+	i := serverXorKeyOffset(data)
+	if i < 0 {
+		return 0, false
+	}
+	key := data[i]
+	data[i] = 0
+	return key, true
+}
+
+func serverXorKeyOffset(data []byte) int {
+	if len(data) < 3 || data[0]&0x7f != 0 {
+		return -1
+	}
+	off := 2
+	if netmsg.Op(data[off]) == netmsg.MSG_ACCEPTED {
+		var accept MsgAccept
+		n, err := accept.Decode(data[off+1:])
+		if err != nil {
+			return -1
+		}
+		off += 1 + n
+	}
+	if len(data) <= off || netmsg.Op(data[off]) != netmsg.MSG_SERVER_ACCEPT {
+		return -1
+	}
+	var saccept MsgServerAccept
+	if _, err := saccept.Decode(data[off+1:]); err != nil {
+		return -1
+	}
+	return off + 1 + 4
+}
+
 type MsgClientAccept struct {
 	PlayerInfo
 	Screen image.Point // 97-104
@@ -35,6 +35,6 @@ func (p *MsgForgetDrawables) Decode(data []byte) (int, error) {
 	if len(data) < 4 {
 		return 0, io.ErrUnexpectedEOF
 	}
-	p.Unk0 = binary.BigEndian.Uint32(data[0:4])
+	p.Unk0 = binary.LittleEndian.Uint32(data[0:4])
 	return 4, nil
 }
@@ -0,0 +1,350 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgFxBlueSparks{}, false)
+	netmsg.Register(&MsgFxYellowSparks{}, false)
+	netmsg.Register(&MsgFxCyanSparks{}, false)
+	netmsg.Register(&MsgFxVioletSparks{}, false)
+	netmsg.Register(&MsgFxExplosion{}, false)
+	netmsg.Register(&MsgFxLesserExplosion{}, false)
+	netmsg.Register(&MsgFxCounterspellExplosion{}, false)
+	netmsg.Register(&MsgFxThinExplosion{}, false)
+	netmsg.Register(&MsgFxTeleport{}, false)
+	netmsg.Register(&MsgFxSmokeBlast{}, false)
+	netmsg.Register(&MsgFxDamagePoof{}, false)
+	netmsg.Register(&MsgFxRicochet{}, false)
+	netmsg.Register(&MsgFxWhiteFlash{}, false)
+	netmsg.Register(&MsgFxManaBombCancel{}, false)
+	netmsg.Register(&MsgFxMagic{}, false)
+	netmsg.Register(&MsgFxGreenExplosion{}, false)
+	netmsg.Register(&MsgFxTurnUndead{}, false)
+
+	netmsg.Register(&MsgFxLightning{}, false)
+	netmsg.Register(&MsgFxEnergyBolt{}, false)
+	netmsg.Register(&MsgFxChainLightningBolt{}, false)
+	netmsg.Register(&MsgFxDrainMana{}, false)
+	netmsg.Register(&MsgFxCharm{}, false)
+	netmsg.Register(&MsgFxGreaterHeal{}, false)
+	netmsg.Register(&MsgFxDeathRay{}, false)
+	netmsg.Register(&MsgFxSentryRay{}, false)
+	netmsg.Register(&MsgFxGreenBolt{}, false)
+	netmsg.Register(&MsgFxArrowTrap{}, false)
+
+	netmsg.Register(&MsgFxSparkExplosion{}, false)
+}
+
+// FxPoint is a common payload for effects drawn at a single point.
+type FxPoint struct {
+	Pos image.Point
+}
+
+func (*FxPoint) EncodeSize() int {
+	return 4
+}
+
+func (p *FxPoint) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.Pos.X))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(p.Pos.Y))
+	return 4, nil
+}
+
+func (p *FxPoint) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Pos.X = int(binary.LittleEndian.Uint16(data[0:2]))
+	p.Pos.Y = int(binary.LittleEndian.Uint16(data[2:4]))
+	return 4, nil
+}
+
+// FxLine is a common payload for effects drawn between two points.
+type FxLine struct {
+	From image.Point
+	To   image.Point
+}
+
+func (*FxLine) EncodeSize() int {
+	return 8
+}
+
+func (p *FxLine) Encode(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.From.X))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(p.From.Y))
+	binary.LittleEndian.PutUint16(data[4:6], uint16(p.To.X))
+	binary.LittleEndian.PutUint16(data[6:8], uint16(p.To.Y))
+	return 8, nil
+}
+
+func (p *FxLine) Decode(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.From.X = int(binary.LittleEndian.Uint16(data[0:2]))
+	p.From.Y = int(binary.LittleEndian.Uint16(data[2:4]))
+	p.To.X = int(binary.LittleEndian.Uint16(data[4:6]))
+	p.To.Y = int(binary.LittleEndian.Uint16(data[6:8]))
+	return 8, nil
+}
+
+type MsgFxBlueSparks struct {
+	FxPoint
+}
+
+func (*MsgFxBlueSparks) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_BLUE_SPARKS
+}
+
+type MsgFxYellowSparks struct {
+	FxPoint
+}
+
+func (*MsgFxYellowSparks) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_YELLOW_SPARKS
+}
+
+type MsgFxCyanSparks struct {
+	FxPoint
+}
+
+func (*MsgFxCyanSparks) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_CYAN_SPARKS
+}
+
+type MsgFxVioletSparks struct {
+	FxPoint
+}
+
+func (*MsgFxVioletSparks) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_VIOLET_SPARKS
+}
+
+type MsgFxExplosion struct {
+	FxPoint
+}
+
+func (*MsgFxExplosion) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_EXPLOSION
+}
+
+type MsgFxLesserExplosion struct {
+	FxPoint
+}
+
+func (*MsgFxLesserExplosion) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_LESSER_EXPLOSION
+}
+
+type MsgFxCounterspellExplosion struct {
+	FxPoint
+}
+
+func (*MsgFxCounterspellExplosion) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_COUNTERSPELL_EXPLOSION
+}
+
+type MsgFxThinExplosion struct {
+	FxPoint
+}
+
+func (*MsgFxThinExplosion) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_THIN_EXPLOSION
+}
+
+type MsgFxTeleport struct {
+	FxPoint
+}
+
+func (*MsgFxTeleport) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_TELEPORT
+}
+
+type MsgFxSmokeBlast struct {
+	FxPoint
+}
+
+func (*MsgFxSmokeBlast) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_SMOKE_BLAST
+}
+
+type MsgFxDamagePoof struct {
+	FxPoint
+}
+
+func (*MsgFxDamagePoof) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_DAMAGE_POOF
+}
+
+type MsgFxRicochet struct {
+	FxPoint
+}
+
+func (*MsgFxRicochet) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_RICOCHET
+}
+
+type MsgFxWhiteFlash struct {
+	FxPoint
+}
+
+func (*MsgFxWhiteFlash) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_WHITE_FLASH
+}
+
+type MsgFxManaBombCancel struct {
+	FxPoint
+}
+
+func (*MsgFxManaBombCancel) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_MANA_BOMB_CANCEL
+}
+
+type MsgFxMagic struct {
+	FxPoint
+}
+
+func (*MsgFxMagic) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_MAGIC
+}
+
+type MsgFxGreenExplosion struct {
+	FxPoint
+}
+
+func (*MsgFxGreenExplosion) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_GREEN_EXPLOSION
+}
+
+type MsgFxTurnUndead struct {
+	FxPoint
+}
+
+func (*MsgFxTurnUndead) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_TURN_UNDEAD
+}
+
+type MsgFxLightning struct {
+	FxLine
+}
+
+func (*MsgFxLightning) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_LIGHTNING
+}
+
+type MsgFxEnergyBolt struct {
+	FxLine
+}
+
+func (*MsgFxEnergyBolt) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_ENERGY_BOLT
+}
+
+type MsgFxChainLightningBolt struct {
+	FxLine
+}
+
+func (*MsgFxChainLightningBolt) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_CHAIN_LIGHTNING_BOLT
+}
+
+type MsgFxDrainMana struct {
+	FxLine
+}
+
+func (*MsgFxDrainMana) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_DRAIN_MANA
+}
+
+type MsgFxCharm struct {
+	FxLine
+}
+
+func (*MsgFxCharm) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_CHARM
+}
+
+type MsgFxGreaterHeal struct {
+	FxLine
+}
+
+func (*MsgFxGreaterHeal) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_GREATER_HEAL
+}
+
+type MsgFxDeathRay struct {
+	FxLine
+}
+
+func (*MsgFxDeathRay) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_DEATH_RAY
+}
+
+type MsgFxSentryRay struct {
+	FxLine
+}
+
+func (*MsgFxSentryRay) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_SENTRY_RAY
+}
+
+type MsgFxGreenBolt struct {
+	FxLine
+}
+
+func (*MsgFxGreenBolt) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_GREEN_BOLT
+}
+
+type MsgFxArrowTrap struct {
+	FxLine
+}
+
+func (*MsgFxArrowTrap) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_ARROW_TRAP
+}
+
+type MsgFxSparkExplosion struct {
+	Pos  image.Point
+	Size byte
+}
+
+func (*MsgFxSparkExplosion) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_SPARK_EXPLOSION
+}
+
+func (*MsgFxSparkExplosion) EncodeSize() int {
+	return 5
+}
+
+func (p *MsgFxSparkExplosion) Encode(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.Pos.X))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(p.Pos.Y))
+	data[4] = p.Size
+	return 5, nil
+}
+
+func (p *MsgFxSparkExplosion) Decode(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Pos.X = int(binary.LittleEndian.Uint16(data[0:2]))
+	p.Pos.Y = int(binary.LittleEndian.Uint16(data[2:4]))
+	p.Size = data[4]
+	return 5, nil
+}
@@ -0,0 +1,357 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/types"
+)
+
+func init() {
+	netmsg.Register(&MsgFxParticle{}, false)
+	netmsg.Register(&MsgFxPlasma{}, false)
+	netmsg.Register(&MsgFxSummon{}, false)
+	netmsg.Register(&MsgFxSummonCancel{}, false)
+	netmsg.Register(&MsgFxShield{}, false)
+	netmsg.Register(&MsgFxDurationSpell{}, false)
+	netmsg.Register(&MsgFxDeltaZSpellStart{}, false)
+	netmsg.Register(&MsgFxVampirism{}, false)
+	netmsg.Register(&MsgFxGeneratingMap{}, false)
+	netmsg.Register(&MsgFxAssemblingMap{}, false)
+	netmsg.Register(&MsgFxPopulatingMap{}, false)
+}
+
+type MsgFxParticle struct {
+	Kind  byte        // 0
+	Pos   image.Point // 1-4
+	Color types.RGB   // 5-7
+}
+
+func (*MsgFxParticle) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_PARTICLEFX
+}
+
+func (*MsgFxParticle) EncodeSize() int {
+	return 8
+}
+
+func (p *MsgFxParticle) Encode(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Kind
+	binary.LittleEndian.PutUint16(data[1:3], uint16(p.Pos.X))
+	binary.LittleEndian.PutUint16(data[3:5], uint16(p.Pos.Y))
+	data[5] = p.Color.R
+	data[6] = p.Color.G
+	data[7] = p.Color.B
+	return 8, nil
+}
+
+func (p *MsgFxParticle) Decode(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Kind = data[0]
+	p.Pos.X = int(binary.LittleEndian.Uint16(data[1:3]))
+	p.Pos.Y = int(binary.LittleEndian.Uint16(data[3:5]))
+	p.Color = types.RGB{R: data[5], G: data[6], B: data[7]}
+	return 8, nil
+}
+
+// FxTarget is a common payload for effects linking two objects.
+type FxTarget struct {
+	Source NetCode
+	Target NetCode
+}
+
+func (*FxTarget) EncodeSize() int {
+	return 4
+}
+
+func (p *FxTarget) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.Source))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(p.Target))
+	return 4, nil
+}
+
+func (p *FxTarget) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Source = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Target = NetCode(binary.LittleEndian.Uint16(data[2:4]))
+	return 4, nil
+}
+
+type MsgFxPlasma struct {
+	FxTarget
+}
+
+func (*MsgFxPlasma) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_PLASMA
+}
+
+type MsgFxSummon struct {
+	NetCode  NetCode     // 0-1
+	Pos      image.Point // 2-5
+	Dir      byte        // 6
+	Type     uint16      // 7-8
+	Duration uint16      // 9-10
+}
+
+func (*MsgFxSummon) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_SUMMON
+}
+
+func (*MsgFxSummon) EncodeSize() int {
+	return 11
+}
+
+func (p *MsgFxSummon) Encode(data []byte) (int, error) {
+	if len(data) < 11 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(p.Pos.X))
+	binary.LittleEndian.PutUint16(data[4:6], uint16(p.Pos.Y))
+	data[6] = p.Dir
+	binary.LittleEndian.PutUint16(data[7:9], p.Type)
+	binary.LittleEndian.PutUint16(data[9:11], p.Duration)
+	return 11, nil
+}
+
+func (p *MsgFxSummon) Decode(data []byte) (int, error) {
+	if len(data) < 11 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Pos.X = int(binary.LittleEndian.Uint16(data[2:4]))
+	p.Pos.Y = int(binary.LittleEndian.Uint16(data[4:6]))
+	p.Dir = data[6]
+	p.Type = binary.LittleEndian.Uint16(data[7:9])
+	p.Duration = binary.LittleEndian.Uint16(data[9:11])
+	return 11, nil
+}
+
+type MsgFxSummonCancel struct {
+	NetCode NetCode
+}
+
+func (*MsgFxSummonCancel) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_SUMMON_CANCEL
+}
+
+func (*MsgFxSummonCancel) EncodeSize() int {
+	return 2
+}
+
+func (p *MsgFxSummonCancel) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	return 2, nil
+}
+
+func (p *MsgFxSummonCancel) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	return 2, nil
+}
+
+type MsgFxShield struct {
+	NetCode NetCode
+	Dir     byte
+}
+
+func (*MsgFxShield) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_SHIELD
+}
+
+func (*MsgFxShield) EncodeSize() int {
+	return 3
+}
+
+func (p *MsgFxShield) Encode(data []byte) (int, error) {
+	if len(data) < 3 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	data[2] = p.Dir
+	return 3, nil
+}
+
+func (p *MsgFxShield) Decode(data []byte) (int, error) {
+	if len(data) < 3 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Dir = data[2]
+	return 3, nil
+}
+
+type MsgFxDurationSpell struct {
+	Spell    uint16
+	NetCode  NetCode
+	Duration uint16
+}
+
+func (*MsgFxDurationSpell) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_DURATION_SPELL
+}
+
+func (*MsgFxDurationSpell) EncodeSize() int {
+	return 6
+}
+
+func (p *MsgFxDurationSpell) Encode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], p.Spell)
+	binary.LittleEndian.PutUint16(data[2:4], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[4:6], p.Duration)
+	return 6, nil
+}
+
+func (p *MsgFxDurationSpell) Decode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Spell = binary.LittleEndian.Uint16(data[0:2])
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[2:4]))
+	p.Duration = binary.LittleEndian.Uint16(data[4:6])
+	return 6, nil
+}
+
+type MsgFxDeltaZSpellStart struct {
+	Spell byte
+	FxTarget
+}
+
+func (*MsgFxDeltaZSpellStart) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_DELTAZ_SPELL_START
+}
+
+func (*MsgFxDeltaZSpellStart) EncodeSize() int {
+	return 5
+}
+
+func (p *MsgFxDeltaZSpellStart) Encode(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Spell
+	if _, err := p.FxTarget.Encode(data[1:5]); err != nil {
+		return 0, err
+	}
+	return 5, nil
+}
+
+func (p *MsgFxDeltaZSpellStart) Decode(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Spell = data[0]
+	if _, err := p.FxTarget.Decode(data[1:5]); err != nil {
+		return 0, err
+	}
+	return 5, nil
+}
+
+type MsgFxVampirism struct {
+	FxTarget
+	Pos    image.Point
+	Amount uint16
+}
+
+func (*MsgFxVampirism) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_VAMPIRISM
+}
+
+func (*MsgFxVampirism) EncodeSize() int {
+	return 10
+}
+
+func (p *MsgFxVampirism) Encode(data []byte) (int, error) {
+	if len(data) < 10 {
+		return 0, io.ErrShortBuffer
+	}
+	if _, err := p.FxTarget.Encode(data[0:4]); err != nil {
+		return 0, err
+	}
+	binary.LittleEndian.PutUint16(data[4:6], uint16(p.Pos.X))
+	binary.LittleEndian.PutUint16(data[6:8], uint16(p.Pos.Y))
+	binary.LittleEndian.PutUint16(data[8:10], p.Amount)
+	return 10, nil
+}
+
+func (p *MsgFxVampirism) Decode(data []byte) (int, error) {
+	if len(data) < 10 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if _, err := p.FxTarget.Decode(data[0:4]); err != nil {
+		return 0, err
+	}
+	p.Pos.X = int(binary.LittleEndian.Uint16(data[4:6]))
+	p.Pos.Y = int(binary.LittleEndian.Uint16(data[6:8]))
+	p.Amount = binary.LittleEndian.Uint16(data[8:10])
+	return 10, nil
+}
+
+// FxProgress is a common payload for map generation progress effects.
+type FxProgress struct {
+	Progress byte
+}
+
+func (*FxProgress) EncodeSize() int {
+	return 1
+}
+
+func (p *FxProgress) Encode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Progress
+	return 1, nil
+}
+
+func (p *FxProgress) Decode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Progress = data[0]
+	return 1, nil
+}
+
+type MsgFxGeneratingMap struct {
+	FxProgress
+}
+
+func (*MsgFxGeneratingMap) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_GENERATING_MAP
+}
+
+type MsgFxAssemblingMap struct {
+	FxProgress
+}
+
+func (*MsgFxAssemblingMap) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_ASSEMBLING_MAP
+}
+
+type MsgFxPopulatingMap struct {
+	FxProgress
+}
+
+func (*MsgFxPopulatingMap) NetOp() netmsg.Op {
+	return netmsg.MSG_FX_POPULATING_MAP
+}
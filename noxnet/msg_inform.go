@@ -2,6 +2,7 @@ package noxnet
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 
@@ -49,10 +50,16 @@ func (*MsgInform) NetOp() netmsg.Op {
 }
 
 func (m *MsgInform) EncodeSize() int {
+	if m.Inform == nil {
+		return 1
+	}
 	return 1 + m.Inform.EncodeSize()
 }
 
 func (m *MsgInform) Encode(data []byte) (int, error) {
+	if m.Inform == nil {
+		return 0, errors.New("inform is not set")
+	}
 	if len(data) < m.EncodeSize() {
 		return 0, io.ErrShortBuffer
 	}
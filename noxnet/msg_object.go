@@ -0,0 +1,212 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgSimpleObject{}, false)
+	netmsg.Register(&MsgComplexObject{}, false)
+	netmsg.Register(&MsgDestroyObject{}, false)
+	netmsg.Register(&MsgObjectOutOfSight{}, false)
+	netmsg.Register(&MsgObjectInShadows{}, false)
+	netmsg.Register(&MsgObjectFriendAdd{}, false)
+	netmsg.Register(&MsgObjectFriendRemove{}, false)
+	netmsg.Register(&MsgResetFriends{}, false)
+	netmsg.Register(&MsgEnableObject{}, false)
+	netmsg.Register(&MsgDisableObject{}, false)
+}
+
+type MsgSimpleObject struct {
+	NetCode NetCode
+	Type    uint16
+	Pos     image.Point
+}
+
+func (*MsgSimpleObject) NetOp() netmsg.Op {
+	return netmsg.MSG_SIMPLE_OBJ
+}
+
+func (*MsgSimpleObject) EncodeSize() int {
+	return 8
+}
+
+func (p *MsgSimpleObject) Encode(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[2:4], p.Type)
+	binary.LittleEndian.PutUint16(data[4:6], uint16(p.Pos.X))
+	binary.LittleEndian.PutUint16(data[6:8], uint16(p.Pos.Y))
+	return 8, nil
+}
+
+func (p *MsgSimpleObject) Decode(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Type = binary.LittleEndian.Uint16(data[2:4])
+	p.Pos.X = int(binary.LittleEndian.Uint16(data[4:6]))
+	p.Pos.Y = int(binary.LittleEndian.Uint16(data[6:8]))
+	return 8, nil
+}
+
+type MsgComplexObject struct {
+	NetCode NetCode
+	Type    uint16
+	Pos     image.Point
+	Complex ComplexObjectUpdate
+}
+
+func (*MsgComplexObject) NetOp() netmsg.Op {
+	return netmsg.MSG_COMPLEX_OBJ
+}
+
+func (*MsgComplexObject) EncodeSize() int {
+	return 11
+}
+
+func (p *MsgComplexObject) Encode(data []byte) (int, error) {
+	if len(data) < 11 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[2:4], p.Type)
+	binary.LittleEndian.PutUint16(data[4:6], uint16(p.Pos.X))
+	binary.LittleEndian.PutUint16(data[6:8], uint16(p.Pos.Y))
+	data[8] = p.Complex.Unk0
+	data[9] = p.Complex.Unk1
+	data[10] = p.Complex.Unk2
+	return 11, nil
+}
+
+func (p *MsgComplexObject) Decode(data []byte) (int, error) {
+	if len(data) < 11 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Type = binary.LittleEndian.Uint16(data[2:4])
+	p.Pos.X = int(binary.LittleEndian.Uint16(data[4:6]))
+	p.Pos.Y = int(binary.LittleEndian.Uint16(data[6:8]))
+	p.Complex = ComplexObjectUpdate{
+		Unk0: data[8],
+		Unk1: data[9],
+		Unk2: data[10],
+	}
+	return 11, nil
+}
+
+// ObjectCode is a common payload for messages referring to a single object.
+type ObjectCode struct {
+	NetCode NetCode
+}
+
+func (*ObjectCode) EncodeSize() int {
+	return 2
+}
+
+func (p *ObjectCode) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	return 2, nil
+}
+
+func (p *ObjectCode) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	return 2, nil
+}
+
+type MsgDestroyObject struct {
+	ObjectCode
+}
+
+func (*MsgDestroyObject) NetOp() netmsg.Op {
+	return netmsg.MSG_DESTROY_OBJECT
+}
+
+type MsgObjectOutOfSight struct {
+	ObjectCode
+}
+
+func (*MsgObjectOutOfSight) NetOp() netmsg.Op {
+	return netmsg.MSG_OBJECT_OUT_OF_SIGHT
+}
+
+type MsgObjectInShadows struct {
+	ObjectCode
+}
+
+func (*MsgObjectInShadows) NetOp() netmsg.Op {
+	return netmsg.MSG_OBJECT_IN_SHADOWS
+}
+
+type MsgObjectFriendAdd struct {
+	ObjectCode
+}
+
+func (*MsgObjectFriendAdd) NetOp() netmsg.Op {
+	return netmsg.MSG_OBJECT_FRIEND_ADD
+}
+
+type MsgObjectFriendRemove struct {
+	ObjectCode
+}
+
+func (*MsgObjectFriendRemove) NetOp() netmsg.Op {
+	return netmsg.MSG_OBJECT_FRIEND_REMOVE
+}
+
+type MsgEnableObject struct {
+	ObjectCode
+}
+
+func (*MsgEnableObject) NetOp() netmsg.Op {
+	return netmsg.MSG_ENABLE_OBJECT
+}
+
+type MsgDisableObject struct {
+	ObjectCode
+}
+
+func (*MsgDisableObject) NetOp() netmsg.Op {
+	return netmsg.MSG_DISABLE_OBJECT
+}
+
+type MsgResetFriends struct {
+	Unk0 uint16
+}
+
+func (*MsgResetFriends) NetOp() netmsg.Op {
+	return netmsg.MSG_RESET_FRIENDS
+}
+
+func (*MsgResetFriends) EncodeSize() int {
+	return 2
+}
+
+func (p *MsgResetFriends) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], p.Unk0)
+	return 2, nil
+}
+
+func (p *MsgResetFriends) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Unk0 = binary.LittleEndian.Uint16(data[0:2])
+	return 2, nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/opennox/libs/binenc"
 	"github.com/opennox/libs/noxnet/netmsg"
@@ -60,6 +61,9 @@ func (m *MsgPlayerInput) Decode(data []byte) (int, error) {
 	}
 	m.Inputs = nil
 	psz := int(data[0])
+	if len(data) < 1+psz {
+		return 0, io.ErrUnexpectedEOF
+	}
 	data = data[1 : 1+psz]
 	for len(data) > 0 {
 		code := CtrlCode(data[0])
@@ -264,12 +268,22 @@ const (
 type CtrlCode byte
 
 func (code CtrlCode) String() string {
-	if name := ctrlCodes[code]; name != "" {
-		return name
+	if code < ccMax && ctrlCodes[code] != "" {
+		return ctrlCodes[code]
 	}
 	return fmt.Sprintf("CtrlCode(%d)", int(code))
 }
 
+// ParseCtrlCode returns a control code by its name, as returned by CtrlCode.String.
+func ParseCtrlCode(name string) (CtrlCode, bool) {
+	for i, v := range ctrlCodes {
+		if v != "" && strings.EqualFold(v, name) {
+			return CtrlCode(i), true
+		}
+	}
+	return 0, false
+}
+
 func (code CtrlCode) DataSize() int {
 	switch code {
 	case CCOrientation:
@@ -0,0 +1,235 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgReportHealth{}, false)
+	netmsg.Register(&MsgReportHealthDelta{}, false)
+	netmsg.Register(&MsgReportPlayerHealth{}, false)
+	netmsg.Register(&MsgReportItemHealth{}, false)
+	netmsg.Register(&MsgReportMana{}, false)
+	netmsg.Register(&MsgReportPoison{}, false)
+	netmsg.Register(&MsgReportStamina{}, false)
+}
+
+type MsgReportHealth struct {
+	Health uint16
+	Max    uint16
+}
+
+func (*MsgReportHealth) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_HEALTH
+}
+
+func (*MsgReportHealth) EncodeSize() int {
+	return 4
+}
+
+func (p *MsgReportHealth) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], p.Health)
+	binary.LittleEndian.PutUint16(data[2:4], p.Max)
+	return 4, nil
+}
+
+func (p *MsgReportHealth) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Health = binary.LittleEndian.Uint16(data[0:2])
+	p.Max = binary.LittleEndian.Uint16(data[2:4])
+	return 4, nil
+}
+
+type MsgReportHealthDelta struct {
+	NetCode NetCode
+	Delta   int16
+}
+
+func (*MsgReportHealthDelta) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_HEALTH_DELTA
+}
+
+func (*MsgReportHealthDelta) EncodeSize() int {
+	return 4
+}
+
+func (p *MsgReportHealthDelta) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(p.Delta))
+	return 4, nil
+}
+
+func (p *MsgReportHealthDelta) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Delta = int16(binary.LittleEndian.Uint16(data[2:4]))
+	return 4, nil
+}
+
+type MsgReportPlayerHealth struct {
+	Player  byte
+	Percent byte
+}
+
+func (*MsgReportPlayerHealth) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_PLAYER_HEALTH
+}
+
+func (*MsgReportPlayerHealth) EncodeSize() int {
+	return 2
+}
+
+func (p *MsgReportPlayerHealth) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Player
+	data[1] = p.Percent
+	return 2, nil
+}
+
+func (p *MsgReportPlayerHealth) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Player = data[0]
+	p.Percent = data[1]
+	return 2, nil
+}
+
+type MsgReportItemHealth struct {
+	NetCode NetCode
+	Health  uint16
+	Max     uint16
+}
+
+func (*MsgReportItemHealth) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_ITEM_HEALTH
+}
+
+func (*MsgReportItemHealth) EncodeSize() int {
+	return 6
+}
+
+func (p *MsgReportItemHealth) Encode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[2:4], p.Health)
+	binary.LittleEndian.PutUint16(data[4:6], p.Max)
+	return 6, nil
+}
+
+func (p *MsgReportItemHealth) Decode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Health = binary.LittleEndian.Uint16(data[2:4])
+	p.Max = binary.LittleEndian.Uint16(data[4:6])
+	return 6, nil
+}
+
+type MsgReportMana struct {
+	Mana uint16
+	Max  uint16
+}
+
+func (*MsgReportMana) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_MANA
+}
+
+func (*MsgReportMana) EncodeSize() int {
+	return 4
+}
+
+func (p *MsgReportMana) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], p.Mana)
+	binary.LittleEndian.PutUint16(data[2:4], p.Max)
+	return 4, nil
+}
+
+func (p *MsgReportMana) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Mana = binary.LittleEndian.Uint16(data[0:2])
+	p.Max = binary.LittleEndian.Uint16(data[2:4])
+	return 4, nil
+}
+
+type MsgReportPoison struct {
+	NetCode NetCode
+	Level   byte
+}
+
+func (*MsgReportPoison) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_POISON
+}
+
+func (*MsgReportPoison) EncodeSize() int {
+	return 3
+}
+
+func (p *MsgReportPoison) Encode(data []byte) (int, error) {
+	if len(data) < 3 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	data[2] = p.Level
+	return 3, nil
+}
+
+func (p *MsgReportPoison) Decode(data []byte) (int, error) {
+	if len(data) < 3 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Level = data[2]
+	return 3, nil
+}
+
+type MsgReportStamina struct {
+	Stamina byte
+}
+
+func (*MsgReportStamina) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_STAMINA
+}
+
+func (*MsgReportStamina) EncodeSize() int {
+	return 1
+}
+
+func (p *MsgReportStamina) Encode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Stamina
+	return 1, nil
+}
+
+func (p *MsgReportStamina) Decode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Stamina = data[0]
+	return 1, nil
+}
@@ -0,0 +1,400 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgReportPickup{}, false)
+	netmsg.Register(&MsgReportModifiablePickup{}, false)
+	netmsg.Register(&MsgReportDrop{}, false)
+	netmsg.Register(&MsgReportMundaneArmorEquip{}, false)
+	netmsg.Register(&MsgReportMundaneWeaponEquip{}, false)
+	netmsg.Register(&MsgReportModifiableWeaponEquip{}, false)
+	netmsg.Register(&MsgReportModifiableArmorEquip{}, false)
+	netmsg.Register(&MsgReportArmorDequip{}, false)
+	netmsg.Register(&MsgReportWeaponDequip{}, false)
+	netmsg.Register(&MsgReportEquip{}, false)
+	netmsg.Register(&MsgReportDequip{}, false)
+	netmsg.Register(&MsgReportEnchantment{}, false)
+	netmsg.Register(&MsgReportItemEnchantment{}, false)
+	netmsg.Register(&MsgReportCharges{}, false)
+	netmsg.Register(&MsgReportInventoryLoaded{}, false)
+}
+
+// ItemMods is a set of item modifiers: material, effectiveness, primary and secondary enchantment.
+type ItemMods [4]byte
+
+type MsgReportPickup struct {
+	NetCode NetCode
+	Type    uint16
+}
+
+func (*MsgReportPickup) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_PICKUP
+}
+
+func (*MsgReportPickup) EncodeSize() int {
+	return 4
+}
+
+func (p *MsgReportPickup) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[2:4], p.Type)
+	return 4, nil
+}
+
+func (p *MsgReportPickup) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Type = binary.LittleEndian.Uint16(data[2:4])
+	return 4, nil
+}
+
+type MsgReportModifiablePickup struct {
+	NetCode NetCode
+	Type    uint16
+	Mods    ItemMods
+}
+
+func (*MsgReportModifiablePickup) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_MODIFIABLE_PICKUP
+}
+
+func (*MsgReportModifiablePickup) EncodeSize() int {
+	return 8
+}
+
+func (p *MsgReportModifiablePickup) Encode(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[2:4], p.Type)
+	copy(data[4:8], p.Mods[:])
+	return 8, nil
+}
+
+func (p *MsgReportModifiablePickup) Decode(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Type = binary.LittleEndian.Uint16(data[2:4])
+	copy(p.Mods[:], data[4:8])
+	return 8, nil
+}
+
+type MsgReportDrop struct {
+	NetCode NetCode
+	Unk2    uint16
+}
+
+func (*MsgReportDrop) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_DROP
+}
+
+func (*MsgReportDrop) EncodeSize() int {
+	return 4
+}
+
+func (p *MsgReportDrop) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint16(data[2:4], p.Unk2)
+	return 4, nil
+}
+
+func (p *MsgReportDrop) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Unk2 = binary.LittleEndian.Uint16(data[2:4])
+	return 4, nil
+}
+
+// ItemSlot is a common payload for equip and dequip reports.
+type ItemSlot struct {
+	NetCode NetCode
+	Slot    uint32
+}
+
+func (*ItemSlot) EncodeSize() int {
+	return 6
+}
+
+func (p *ItemSlot) Encode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint32(data[2:6], p.Slot)
+	return 6, nil
+}
+
+func (p *ItemSlot) Decode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Slot = binary.LittleEndian.Uint32(data[2:6])
+	return 6, nil
+}
+
+// ModItemSlot is a common payload for equip reports of modifiable items.
+type ModItemSlot struct {
+	NetCode NetCode
+	Slot    uint32
+	Mods    ItemMods
+}
+
+func (*ModItemSlot) EncodeSize() int {
+	return 10
+}
+
+func (p *ModItemSlot) Encode(data []byte) (int, error) {
+	if len(data) < 10 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint32(data[2:6], p.Slot)
+	copy(data[6:10], p.Mods[:])
+	return 10, nil
+}
+
+func (p *ModItemSlot) Decode(data []byte) (int, error) {
+	if len(data) < 10 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Slot = binary.LittleEndian.Uint32(data[2:6])
+	copy(p.Mods[:], data[6:10])
+	return 10, nil
+}
+
+type MsgReportMundaneArmorEquip struct {
+	ItemSlot
+}
+
+func (*MsgReportMundaneArmorEquip) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_MUNDANE_ARMOR_EQUIP
+}
+
+type MsgReportMundaneWeaponEquip struct {
+	ItemSlot
+}
+
+func (*MsgReportMundaneWeaponEquip) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_MUNDANE_WEAPON_EQUIP
+}
+
+type MsgReportModifiableWeaponEquip struct {
+	ModItemSlot
+}
+
+func (*MsgReportModifiableWeaponEquip) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_MODIFIABLE_WEAPON_EQUIP
+}
+
+type MsgReportModifiableArmorEquip struct {
+	ModItemSlot
+}
+
+func (*MsgReportModifiableArmorEquip) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_MODIFIABLE_ARMOR_EQUIP
+}
+
+type MsgReportArmorDequip struct {
+	ItemSlot
+}
+
+func (*MsgReportArmorDequip) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_ARMOR_DEQUIP
+}
+
+type MsgReportWeaponDequip struct {
+	ItemSlot
+}
+
+func (*MsgReportWeaponDequip) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_WEAPON_DEQUIP
+}
+
+type MsgReportEquip struct {
+	NetCode NetCode
+}
+
+func (*MsgReportEquip) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_EQUIP
+}
+
+func (*MsgReportEquip) EncodeSize() int {
+	return 2
+}
+
+func (p *MsgReportEquip) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	return 2, nil
+}
+
+func (p *MsgReportEquip) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	return 2, nil
+}
+
+type MsgReportDequip struct {
+	NetCode NetCode
+}
+
+func (*MsgReportDequip) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_DEQUIP
+}
+
+func (*MsgReportDequip) EncodeSize() int {
+	return 2
+}
+
+func (p *MsgReportDequip) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	return 2, nil
+}
+
+func (p *MsgReportDequip) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	return 2, nil
+}
+
+type MsgReportEnchantment struct {
+	NetCode  NetCode
+	Enchants uint32
+}
+
+func (*MsgReportEnchantment) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_ENCHANTMENT
+}
+
+func (*MsgReportEnchantment) EncodeSize() int {
+	return 6
+}
+
+func (p *MsgReportEnchantment) Encode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint32(data[2:6], p.Enchants)
+	return 6, nil
+}
+
+func (p *MsgReportEnchantment) Decode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Enchants = binary.LittleEndian.Uint32(data[2:6])
+	return 6, nil
+}
+
+type MsgReportItemEnchantment struct {
+	Unk0 byte
+}
+
+func (*MsgReportItemEnchantment) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_ITEM_ENCHANTMENT
+}
+
+func (*MsgReportItemEnchantment) EncodeSize() int {
+	return 1
+}
+
+func (p *MsgReportItemEnchantment) Encode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Unk0
+	return 1, nil
+}
+
+func (p *MsgReportItemEnchantment) Decode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Unk0 = data[0]
+	return 1, nil
+}
+
+type MsgReportCharges struct {
+	NetCode NetCode
+	Charges byte
+	Max     byte
+}
+
+func (*MsgReportCharges) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_CHARGES
+}
+
+func (*MsgReportCharges) EncodeSize() int {
+	return 4
+}
+
+func (p *MsgReportCharges) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	data[2] = p.Charges
+	data[3] = p.Max
+	return 4, nil
+}
+
+func (p *MsgReportCharges) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Charges = data[2]
+	p.Max = data[3]
+	return 4, nil
+}
+
+type MsgReportInventoryLoaded struct {
+}
+
+func (*MsgReportInventoryLoaded) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_INVENTORY_LOADED
+}
+
+func (*MsgReportInventoryLoaded) EncodeSize() int {
+	return 0
+}
+
+func (*MsgReportInventoryLoaded) Encode(data []byte) (int, error) {
+	return 0, nil
+}
+
+func (*MsgReportInventoryLoaded) Decode(data []byte) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,71 @@
+package noxnet
+
+import (
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgReportSpellAward{}, false)
+	netmsg.Register(&MsgReportSpellStart{}, false)
+}
+
+type MsgReportSpellAward struct {
+	Spell byte
+	Level byte
+}
+
+func (*MsgReportSpellAward) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_SPELL_AWARD
+}
+
+func (*MsgReportSpellAward) EncodeSize() int {
+	return 2
+}
+
+func (p *MsgReportSpellAward) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Spell
+	data[1] = p.Level
+	return 2, nil
+}
+
+func (p *MsgReportSpellAward) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Spell = data[0]
+	p.Level = data[1]
+	return 2, nil
+}
+
+type MsgReportSpellStart struct {
+	Spell byte
+}
+
+func (*MsgReportSpellStart) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_SPELL_START
+}
+
+func (*MsgReportSpellStart) EncodeSize() int {
+	return 1
+}
+
+func (p *MsgReportSpellStart) Encode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Spell
+	return 1, nil
+}
+
+func (p *MsgReportSpellStart) Decode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Spell = data[0]
+	return 1, nil
+}
@@ -0,0 +1,184 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgReportStats{}, false)
+	netmsg.Register(&MsgReportArmorValue{}, false)
+	netmsg.Register(&MsgReportGold{}, false)
+	netmsg.Register(&MsgReportLesson{}, false)
+	netmsg.Register(&MsgReportExperience{}, false)
+}
+
+type MsgReportStats struct {
+	Health    uint16 // 0-1
+	Mana      uint16 // 2-3
+	Weight    uint16 // 4-5
+	Speed     uint16 // 6-7
+	Strength  uint16 // 8-9
+	MaxWeight uint16 // 10-11
+	Level     byte   // 12
+}
+
+func (*MsgReportStats) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_STATS
+}
+
+func (*MsgReportStats) EncodeSize() int {
+	return 13
+}
+
+func (p *MsgReportStats) Encode(data []byte) (int, error) {
+	if len(data) < 13 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], p.Health)
+	binary.LittleEndian.PutUint16(data[2:4], p.Mana)
+	binary.LittleEndian.PutUint16(data[4:6], p.Weight)
+	binary.LittleEndian.PutUint16(data[6:8], p.Speed)
+	binary.LittleEndian.PutUint16(data[8:10], p.Strength)
+	binary.LittleEndian.PutUint16(data[10:12], p.MaxWeight)
+	data[12] = p.Level
+	return 13, nil
+}
+
+func (p *MsgReportStats) Decode(data []byte) (int, error) {
+	if len(data) < 13 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Health = binary.LittleEndian.Uint16(data[0:2])
+	p.Mana = binary.LittleEndian.Uint16(data[2:4])
+	p.Weight = binary.LittleEndian.Uint16(data[4:6])
+	p.Speed = binary.LittleEndian.Uint16(data[6:8])
+	p.Strength = binary.LittleEndian.Uint16(data[8:10])
+	p.MaxWeight = binary.LittleEndian.Uint16(data[10:12])
+	p.Level = data[12]
+	return 13, nil
+}
+
+type MsgReportArmorValue struct {
+	Value uint32
+}
+
+func (*MsgReportArmorValue) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_ARMOR_VALUE
+}
+
+func (*MsgReportArmorValue) EncodeSize() int {
+	return 4
+}
+
+func (p *MsgReportArmorValue) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint32(data[0:4], p.Value)
+	return 4, nil
+}
+
+func (p *MsgReportArmorValue) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Value = binary.LittleEndian.Uint32(data[0:4])
+	return 4, nil
+}
+
+type MsgReportGold struct {
+	Gold uint32
+}
+
+func (*MsgReportGold) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_GOLD
+}
+
+func (*MsgReportGold) EncodeSize() int {
+	return 4
+}
+
+func (p *MsgReportGold) Encode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint32(data[0:4], p.Gold)
+	return 4, nil
+}
+
+func (p *MsgReportGold) Decode(data []byte) (int, error) {
+	if len(data) < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Gold = binary.LittleEndian.Uint32(data[0:4])
+	return 4, nil
+}
+
+type MsgReportLesson struct {
+	NetCode NetCode
+	Lessons int32
+	Unk6    uint32
+}
+
+func (*MsgReportLesson) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_LESSON
+}
+
+func (*MsgReportLesson) EncodeSize() int {
+	return 10
+}
+
+func (p *MsgReportLesson) Encode(data []byte) (int, error) {
+	if len(data) < 10 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint32(data[2:6], uint32(p.Lessons))
+	binary.LittleEndian.PutUint32(data[6:10], p.Unk6)
+	return 10, nil
+}
+
+func (p *MsgReportLesson) Decode(data []byte) (int, error) {
+	if len(data) < 10 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Lessons = int32(binary.LittleEndian.Uint32(data[2:6]))
+	p.Unk6 = binary.LittleEndian.Uint32(data[6:10])
+	return 10, nil
+}
+
+type MsgReportExperience struct {
+	Level byte
+	Exp   float32
+}
+
+func (*MsgReportExperience) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_EXPERIENCE
+}
+
+func (*MsgReportExperience) EncodeSize() int {
+	return 5
+}
+
+func (p *MsgReportExperience) Encode(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Level
+	binary.LittleEndian.PutUint32(data[1:5], math.Float32bits(p.Exp))
+	return 5, nil
+}
+
+func (p *MsgReportExperience) Decode(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Level = data[0]
+	p.Exp = math.Float32frombits(binary.LittleEndian.Uint32(data[1:5]))
+	return 5, nil
+}
@@ -0,0 +1,158 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgReportZPlus{}, false)
+	netmsg.Register(&MsgReportZMinus{}, false)
+	netmsg.Register(&MsgReportXStatus{}, false)
+	netmsg.Register(&MsgReportModifier{}, false)
+	netmsg.Register(&MsgReportStatModifier{}, false)
+	netmsg.Register(&MsgReportClientStatus{}, false)
+	netmsg.Register(&MsgReportAnimationFrame{}, false)
+}
+
+// ObjectByte is a common payload for reports of a single byte value for an object.
+// The meaning of the value depends on the message.
+type ObjectByte struct {
+	NetCode NetCode
+	Value   byte
+}
+
+func (*ObjectByte) EncodeSize() int {
+	return 3
+}
+
+func (p *ObjectByte) Encode(data []byte) (int, error) {
+	if len(data) < 3 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	data[2] = p.Value
+	return 3, nil
+}
+
+func (p *ObjectByte) Decode(data []byte) (int, error) {
+	if len(data) < 3 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Value = data[2]
+	return 3, nil
+}
+
+// ObjectValue is a common payload for reports of a single 32-bit value for an object.
+// The meaning of the value depends on the message.
+type ObjectValue struct {
+	NetCode NetCode
+	Value   uint32
+}
+
+func (*ObjectValue) EncodeSize() int {
+	return 6
+}
+
+func (p *ObjectValue) Encode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	binary.LittleEndian.PutUint32(data[2:6], p.Value)
+	return 6, nil
+}
+
+func (p *ObjectValue) Decode(data []byte) (int, error) {
+	if len(data) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Value = binary.LittleEndian.Uint32(data[2:6])
+	return 6, nil
+}
+
+type MsgReportZPlus struct {
+	ObjectByte
+}
+
+func (*MsgReportZPlus) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_Z_PLUS
+}
+
+type MsgReportZMinus struct {
+	ObjectByte
+}
+
+func (*MsgReportZMinus) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_Z_MINUS
+}
+
+type MsgReportXStatus struct {
+	ObjectValue
+}
+
+func (*MsgReportXStatus) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_X_STATUS
+}
+
+type MsgReportModifier struct {
+	ObjectValue
+}
+
+func (*MsgReportModifier) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_MODIFIER
+}
+
+type MsgReportClientStatus struct {
+	ObjectValue
+}
+
+func (*MsgReportClientStatus) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_CLIENT_STATUS
+}
+
+type MsgReportAnimationFrame struct {
+	ObjectValue
+}
+
+func (*MsgReportAnimationFrame) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_ANIMATION_FRAME
+}
+
+type MsgReportStatModifier struct {
+	NetCode NetCode // 0-1
+	Unk2    byte    // 2
+	Unk3    uint32  // 3-6
+}
+
+func (*MsgReportStatModifier) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_STAT_MODIFIER
+}
+
+func (*MsgReportStatModifier) EncodeSize() int {
+	return 7
+}
+
+func (p *MsgReportStatModifier) Encode(data []byte) (int, error) {
+	if len(data) < 7 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	data[2] = p.Unk2
+	binary.LittleEndian.PutUint32(data[3:7], p.Unk3)
+	return 7, nil
+}
+
+func (p *MsgReportStatModifier) Decode(data []byte) (int, error) {
+	if len(data) < 7 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Unk2 = data[2]
+	p.Unk3 = binary.LittleEndian.Uint32(data[3:7])
+	return 7, nil
+}
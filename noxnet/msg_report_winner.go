@@ -0,0 +1,78 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgReportFlagBallWinner{}, false)
+	netmsg.Register(&MsgReportFlagWinner{}, false)
+	netmsg.Register(&MsgReportDeathmatchWinner{}, false)
+	netmsg.Register(&MsgReportDeathmatchTeamWinner{}, false)
+}
+
+// GameWinner is a common payload for reports sent when the game round ends.
+type GameWinner struct {
+	Unk0 uint16 // 0-1
+	Unk2 uint32 // 2-5
+	Unk6 byte   // 6
+}
+
+func (*GameWinner) EncodeSize() int {
+	return 7
+}
+
+func (p *GameWinner) Encode(data []byte) (int, error) {
+	if len(data) < 7 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], p.Unk0)
+	binary.LittleEndian.PutUint32(data[2:6], p.Unk2)
+	data[6] = p.Unk6
+	return 7, nil
+}
+
+func (p *GameWinner) Decode(data []byte) (int, error) {
+	if len(data) < 7 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Unk0 = binary.LittleEndian.Uint16(data[0:2])
+	p.Unk2 = binary.LittleEndian.Uint32(data[2:6])
+	p.Unk6 = data[6]
+	return 7, nil
+}
+
+type MsgReportFlagBallWinner struct {
+	GameWinner
+}
+
+func (*MsgReportFlagBallWinner) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_FLAG_BALL_WINNER
+}
+
+type MsgReportFlagWinner struct {
+	GameWinner
+}
+
+func (*MsgReportFlagWinner) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_FLAG_WINNER
+}
+
+type MsgReportDeathmatchWinner struct {
+	GameWinner
+}
+
+func (*MsgReportDeathmatchWinner) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_DEATHMATCH_WINNER
+}
+
+type MsgReportDeathmatchTeamWinner struct {
+	GameWinner
+}
+
+func (*MsgReportDeathmatchTeamWinner) NetOp() netmsg.Op {
+	return netmsg.MSG_REPORT_DEATHMATCH_TEAM_WINNER
+}
@@ -29,6 +29,9 @@ func (m *MsgSeqImportant) EncodeSize() int {
 }
 
 func (m *MsgSeqImportant) EncodeSizeWith(s *netmsg.State) int {
+	if m.Msg == nil {
+		return 3
+	}
 	n := s.EncodeSize(m.Msg)
 	if n > 0xff {
 		n = 0xff
@@ -41,6 +44,9 @@ func (m *MsgSeqImportant) Encode(data []byte) (int, error) {
 }
 
 func (m *MsgSeqImportant) EncodeWith(s *netmsg.State, data []byte) (int, error) {
+	if m.Msg == nil {
+		return 0, errors.New("message is not set")
+	}
 	sz := s.EncodeSize(m.Msg)
 	if sz > 0xff {
 		return 0, errors.New("message is too large")
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"unicode/utf16"
 
 	"github.com/opennox/libs/binenc"
 	"github.com/opennox/libs/noxnet/netmsg"
@@ -23,12 +24,18 @@ func (*MsgServerCmd) NetOp() netmsg.Op {
 	return netmsg.MSG_SERVER_CMD
 }
 
+// cmdLen returns the length of the command in UTF-16 units.
+func (p *MsgServerCmd) cmdLen() int {
+	return len(utf16.Encode([]rune(p.Cmd)))
+}
+
 func (p *MsgServerCmd) EncodeSize() int {
-	return 4 + 2*(len(p.Cmd)+1)
+	return 4 + 2*(p.cmdLen()+1)
 }
 
 func (p *MsgServerCmd) Encode(data []byte) (int, error) {
-	if len(p.Cmd) > 0xff-1 {
+	sz := p.cmdLen()
+	if sz > 0xff-1 {
 		return 0, errors.New("command is too long")
 	}
 	if len(data) < p.EncodeSize() {
@@ -36,7 +43,7 @@ func (p *MsgServerCmd) Encode(data []byte) (int, error) {
 	}
 	data[0] = p.ID
 	binary.LittleEndian.PutUint16(data[1:3], p.NetCode)
-	data[3] = byte(len(p.Cmd) + 1)
+	data[3] = byte(sz + 1)
 	n := binenc.CStringSet16(data[4:], p.Cmd)
 	data[4+n+0] = 0
 	data[4+n+1] = 0
@@ -53,6 +60,7 @@ func (p *MsgServerCmd) Decode(data []byte) (int, error) {
 	if len(data) < 4+2*sz {
 		return 0, io.ErrUnexpectedEOF
 	}
-	p.Cmd = binenc.CString16(data[4 : 4+2*sz])
+	// last character is always a null terminator
+	p.Cmd = binenc.CString16(data[4 : 4+2*max(sz-1, 0)])
 	return 4 + 2*sz, nil
 }
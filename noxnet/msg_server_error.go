@@ -23,8 +23,8 @@ func ErrorToMsg(err error) (ErrorMsg, bool) {
 	if err == nil {
 		return nil, true
 	}
-	if e := (*ConnectError)(nil); errors.As(err, &e) {
-		return &MsgServerError{Err: *e}, true
+	if e := ConnectError(0); errors.As(err, &e) {
+		return &MsgServerError{Err: e}, true
 	} else if errors.Is(err, ErrPasswordRequired) {
 		return &MsgPasswordRequired{}, true
 	}
@@ -147,7 +147,7 @@ func (p *MsgServerError) Encode(data []byte) (int, error) {
 }
 
 func (p *MsgServerError) Decode(data []byte) (int, error) {
-	if len(data) < 9 {
+	if len(data) < 1 {
 		return 0, io.ErrUnexpectedEOF
 	}
 	p.Err = ConnectError(data[0])
@@ -41,6 +41,8 @@ func (p *MsgServerTryJoin) Encode(data []byte) (int, error) {
 	}
 	data[0] = p.Unk0
 	binenc.CStringSet16(data[1:51], p.PlayerName)
+	data[51] = p.PlayerClass
+	data[52] = p.PlayerLevel
 	binenc.CStringSet(data[53:77], p.Serial)
 	binary.LittleEndian.PutUint32(data[77:81], p.Version)
 	binary.LittleEndian.PutUint32(data[81:85], p.Team)
@@ -55,7 +57,9 @@ func (p *MsgServerTryJoin) Decode(data []byte) (int, error) {
 		return 0, io.ErrUnexpectedEOF
 	}
 	p.Unk0 = data[0]
-	p.PlayerName = binenc.CString16(data[1:53])
+	p.PlayerName = binenc.CString16(data[1:51])
+	p.PlayerClass = data[51]
+	p.PlayerLevel = data[52]
 	p.Serial = binenc.CString(data[53:77])
 	p.Version = binary.LittleEndian.Uint32(data[77:81])
 	p.Team = binary.LittleEndian.Uint32(data[81:85])
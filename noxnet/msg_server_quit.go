@@ -0,0 +1,88 @@
+package noxnet
+
+import (
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgServerQuit{}, false)
+	netmsg.Register(&MsgServerQuitAck{}, false)
+	netmsg.Register(&MsgKickNotification{}, false)
+	netmsg.Register(&MsgTimeoutNotification{}, false)
+}
+
+// MsgServerQuit is sent by the server to all players when it shuts down.
+type MsgServerQuit struct{}
+
+func (*MsgServerQuit) NetOp() netmsg.Op {
+	return netmsg.MSG_SERVER_QUIT
+}
+
+func (*MsgServerQuit) EncodeSize() int {
+	return 0
+}
+
+func (*MsgServerQuit) Encode(data []byte) (int, error) {
+	return 0, nil
+}
+
+func (*MsgServerQuit) Decode(data []byte) (int, error) {
+	return 0, nil
+}
+
+// MsgServerQuitAck is sent by the client in response to MsgServerQuit.
+type MsgServerQuitAck struct{}
+
+func (*MsgServerQuitAck) NetOp() netmsg.Op {
+	return netmsg.MSG_SERVER_QUIT_ACK
+}
+
+func (*MsgServerQuitAck) EncodeSize() int {
+	return 0
+}
+
+func (*MsgServerQuitAck) Encode(data []byte) (int, error) {
+	return 0, nil
+}
+
+func (*MsgServerQuitAck) Decode(data []byte) (int, error) {
+	return 0, nil
+}
+
+// MsgKickNotification is sent by the server to the player that was kicked.
+type MsgKickNotification struct{}
+
+func (*MsgKickNotification) NetOp() netmsg.Op {
+	return netmsg.MSG_KICK_NOTIFICATION
+}
+
+func (*MsgKickNotification) EncodeSize() int {
+	return 0
+}
+
+func (*MsgKickNotification) Encode(data []byte) (int, error) {
+	return 0, nil
+}
+
+func (*MsgKickNotification) Decode(data []byte) (int, error) {
+	return 0, nil
+}
+
+// MsgTimeoutNotification is sent by the server to the player that was disconnected due to inactivity.
+type MsgTimeoutNotification struct{}
+
+func (*MsgTimeoutNotification) NetOp() netmsg.Op {
+	return netmsg.MSG_TIMEOUT_NOTIFICATION
+}
+
+func (*MsgTimeoutNotification) EncodeSize() int {
+	return 0
+}
+
+func (*MsgTimeoutNotification) Encode(data []byte) (int, error) {
+	return 0, nil
+}
+
+func (*MsgTimeoutNotification) Decode(data []byte) (int, error) {
+	return 0, nil
+}
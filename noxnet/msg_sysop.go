@@ -0,0 +1,78 @@
+package noxnet
+
+import (
+	"io"
+
+	"github.com/opennox/libs/binenc"
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgSysopPass{}, false)
+	netmsg.Register(&MsgSysopResult{}, false)
+}
+
+// MsgSysopPass is sent by the client to request remote sysop access.
+type MsgSysopPass struct {
+	Pass string
+}
+
+func (*MsgSysopPass) NetOp() netmsg.Op {
+	return netmsg.MSG_SYSOP_PW
+}
+
+func (*MsgSysopPass) EncodeSize() int {
+	return 20
+}
+
+func (p *MsgSysopPass) Encode(data []byte) (int, error) {
+	if len(data) < 20 {
+		return 0, io.ErrShortBuffer
+	}
+	binenc.CStringSet16(data[0:20], p.Pass)
+	return 20, nil
+}
+
+func (p *MsgSysopPass) Decode(data []byte) (int, error) {
+	if len(data) < 20 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Pass = binenc.CString16(data[0:20])
+	return 20, nil
+}
+
+type SysopResult byte
+
+const (
+	SysopDenied  = SysopResult(0)
+	SysopGranted = SysopResult(1)
+)
+
+// MsgSysopResult is sent by the server in response to MsgSysopPass.
+type MsgSysopResult struct {
+	Result SysopResult
+}
+
+func (*MsgSysopResult) NetOp() netmsg.Op {
+	return netmsg.MSG_SYSOP_RESULT
+}
+
+func (*MsgSysopResult) EncodeSize() int {
+	return 1
+}
+
+func (p *MsgSysopResult) Encode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = byte(p.Result)
+	return 1, nil
+}
+
+func (p *MsgSysopResult) Decode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Result = SysopResult(data[0])
+	return 1, nil
+}
@@ -3,6 +3,9 @@ package noxnet
 import (
 	"encoding/binary"
 	"io"
+	"slices"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"github.com/opennox/libs/binenc"
 	"github.com/opennox/libs/noxnet/netmsg"
@@ -61,7 +64,7 @@ func (p *MsgText) Text() string {
 
 func (p *MsgText) Payload() []byte {
 	if !p.Flags.Has(TextUTF8) && !p.Flags.Has(TextLocalized) {
-		i := binenc.CLen16(p.Data)
+		i := 2 * binenc.CLen16(p.Data)
 		if i+2 < len(p.Data) {
 			return p.Data[i+2:]
 		}
@@ -74,6 +77,40 @@ func (p *MsgText) Payload() []byte {
 	return nil
 }
 
+// SetText replaces the text of the message, keeping the extended payload (if any).
+// The text is truncated to fit into the message.
+// For UTF-16 text, a payload of odd length is padded with a zero byte.
+func (p *MsgText) SetText(s string) {
+	payload := slices.Clone(p.Payload())
+	if p.Flags.Has(TextUTF8) || p.Flags.Has(TextLocalized) {
+		for len(s)+1+len(payload) > 0xff {
+			_, sz := utf8.DecodeLastRuneInString(s)
+			s = s[:len(s)-max(sz, 1)]
+		}
+		data := append([]byte(s), 0)
+		data = append(data, payload...)
+		p.Data, p.Size = data, byte(len(data))
+		return
+	}
+	if len(payload)%2 != 0 {
+		// size is in UTF-16 units, pad the payload so it's not cut on encoding
+		payload = append(payload, 0)
+	}
+	s16 := utf16.Encode([]rune(s))
+	if n := max(0xff-1-len(payload)/2, 0); len(s16) > n {
+		if n > 0 && utf16.IsSurrogate(rune(s16[n-1])) && s16[n-1] < 0xdc00 {
+			n-- // do not split the surrogate pair
+		}
+		s16 = s16[:n]
+	}
+	data := make([]byte, 2*(len(s16)+1), 2*(len(s16)+1)+len(payload))
+	for i, v := range s16 {
+		binary.LittleEndian.PutUint16(data[2*i:], v)
+	}
+	data = append(data, payload...)
+	p.Data, p.Size = data, byte(len(data)/2)
+}
+
 func (p *MsgText) dataSize() int {
 	if p.Size == 0 && len(p.Data) != 0 {
 		return len(p.Data)
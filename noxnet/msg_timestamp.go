@@ -11,6 +11,7 @@ import (
 func init() {
 	netmsg.Register(&MsgTimestamp{}, false)
 	netmsg.Register(&MsgFullTimestamp{}, false)
+	netmsg.Register(&MsgNeedTimestamp{}, false)
 	netmsg.Register(&MsgRateChange{}, false)
 }
 
@@ -27,17 +28,7 @@ func (t *Timestamp) Set(v Timestamp) {
 }
 
 func (t *Timestamp) Set16(v uint16) {
-	ts := uint32(*t)
-	ts16 := uint16(ts & 0xFFFF)
-	overflow := (ts16 >= 0xC000) && (v < 0x4000)
-	if !overflow && v < ts16 {
-		return // out of order
-	}
-	ts = (ts & 0xFFFF0000) | uint32(v)
-	if overflow {
-		ts += 0x10000
-	}
-	*t = Timestamp(ts)
+	*t = Timestamp(netmsg.ExpandFrame16(uint32(*t), v))
 }
 
 type MsgTimestamp struct {
@@ -68,6 +59,10 @@ func (m *MsgTimestamp) Decode(data []byte) (int, error) {
 	return 2, nil
 }
 
+func (m *MsgTimestamp) UpdateFrame(s *netmsg.State) {
+	s.SetFrame16(m.T)
+}
+
 type MsgFullTimestamp struct {
 	T Timestamp
 }
@@ -96,6 +91,32 @@ func (m *MsgFullTimestamp) Decode(data []byte) (int, error) {
 	return 4, nil
 }
 
+func (m *MsgFullTimestamp) UpdateFrame(s *netmsg.State) {
+	s.SetFrame(uint32(m.T))
+}
+
+// MsgNeedTimestamp is sent by the client to request MsgFullTimestamp, when it lost the frame sync.
+//
+// It doesn't affect the frame when decoded, since it's received by the server.
+// The client should call netmsg.State.ResyncFrame on its own state when sending it.
+type MsgNeedTimestamp struct{}
+
+func (*MsgNeedTimestamp) NetOp() netmsg.Op {
+	return netmsg.MSG_NEED_TIMESTAMP
+}
+
+func (*MsgNeedTimestamp) EncodeSize() int {
+	return 0
+}
+
+func (*MsgNeedTimestamp) Encode(data []byte) (int, error) {
+	return 0, nil
+}
+
+func (*MsgNeedTimestamp) Decode(data []byte) (int, error) {
+	return 0, nil
+}
+
 type MsgRateChange struct {
 	Rate byte
 }
@@ -0,0 +1,217 @@
+package noxnet
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgTryDrop{}, false)
+	netmsg.Register(&MsgTryGet{}, false)
+	netmsg.Register(&MsgTryUse{}, false)
+	netmsg.Register(&MsgTryEquip{}, false)
+	netmsg.Register(&MsgTryDequip{}, false)
+	netmsg.Register(&MsgTryTarget{}, false)
+	netmsg.Register(&MsgTryCreatureCommand{}, false)
+	netmsg.Register(&MsgTrySpell{}, false)
+	netmsg.Register(&MsgTryAbility{}, false)
+	netmsg.Register(&MsgTryCollide{}, false)
+}
+
+// TryMsg is implemented by all player action requests (MSG_TRY_*) sent from the client.
+type TryMsg interface {
+	netmsg.Message
+	isTryMsg()
+}
+
+var (
+	_ TryMsg = (*MsgTryDrop)(nil)
+	_ TryMsg = (*MsgTryCreatureCommand)(nil)
+	_ TryMsg = (*MsgTrySpell)(nil)
+	_ TryMsg = (*MsgTryAbility)(nil)
+)
+
+// TryObject is a common payload for player actions on a single object.
+type TryObject struct {
+	NetCode NetCode
+}
+
+func (*TryObject) isTryMsg() {}
+
+func (*TryObject) EncodeSize() int {
+	return 2
+}
+
+func (p *TryObject) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	return 2, nil
+}
+
+func (p *TryObject) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	return 2, nil
+}
+
+type MsgTryDrop struct {
+	TryObject
+}
+
+func (*MsgTryDrop) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_DROP
+}
+
+type MsgTryGet struct {
+	TryObject
+}
+
+func (*MsgTryGet) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_GET
+}
+
+type MsgTryUse struct {
+	TryObject
+}
+
+func (*MsgTryUse) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_USE
+}
+
+type MsgTryEquip struct {
+	TryObject
+}
+
+func (*MsgTryEquip) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_EQUIP
+}
+
+type MsgTryDequip struct {
+	TryObject
+}
+
+func (*MsgTryDequip) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_DEQUIP
+}
+
+type MsgTryTarget struct {
+	TryObject
+}
+
+func (*MsgTryTarget) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_TARGET
+}
+
+type MsgTryCollide struct {
+	TryObject
+}
+
+func (*MsgTryCollide) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_COLLIDE
+}
+
+type MsgTryCreatureCommand struct {
+	NetCode NetCode
+	Command byte
+}
+
+func (*MsgTryCreatureCommand) isTryMsg() {}
+
+func (*MsgTryCreatureCommand) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_CREATURE_COMMAND
+}
+
+func (*MsgTryCreatureCommand) EncodeSize() int {
+	return 3
+}
+
+func (p *MsgTryCreatureCommand) Encode(data []byte) (int, error) {
+	if len(data) < 3 {
+		return 0, io.ErrShortBuffer
+	}
+	binary.LittleEndian.PutUint16(data[0:2], uint16(p.NetCode))
+	data[2] = p.Command
+	return 3, nil
+}
+
+func (p *MsgTryCreatureCommand) Decode(data []byte) (int, error) {
+	if len(data) < 3 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.NetCode = NetCode(binary.LittleEndian.Uint16(data[0:2]))
+	p.Command = data[2]
+	return 3, nil
+}
+
+type MsgTrySpell struct {
+	Spells [5]uint32 // 0-19
+	Flags  byte      // 20
+}
+
+func (*MsgTrySpell) isTryMsg() {}
+
+func (*MsgTrySpell) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_SPELL
+}
+
+func (*MsgTrySpell) EncodeSize() int {
+	return 21
+}
+
+func (p *MsgTrySpell) Encode(data []byte) (int, error) {
+	if len(data) < 21 {
+		return 0, io.ErrShortBuffer
+	}
+	for i, sp := range p.Spells {
+		binary.LittleEndian.PutUint32(data[4*i:], sp)
+	}
+	data[20] = p.Flags
+	return 21, nil
+}
+
+func (p *MsgTrySpell) Decode(data []byte) (int, error) {
+	if len(data) < 21 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	for i := range p.Spells {
+		p.Spells[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	p.Flags = data[20]
+	return 21, nil
+}
+
+type MsgTryAbility struct {
+	Ability byte
+}
+
+func (*MsgTryAbility) isTryMsg() {}
+
+func (*MsgTryAbility) NetOp() netmsg.Op {
+	return netmsg.MSG_TRY_ABILITY
+}
+
+func (*MsgTryAbility) EncodeSize() int {
+	return 1
+}
+
+func (p *MsgTryAbility) Encode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Ability
+	return 1, nil
+}
+
+func (p *MsgTryAbility) Decode(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Ability = data[0]
+	return 1, nil
+}
@@ -88,6 +88,9 @@ func (p *MsgUpdateStream) decodeHeader(data []byte) (int, error) {
 	left = left[n:]
 	p.ID = id
 
+	if len(left) < 6 {
+		return 0, io.ErrUnexpectedEOF
+	}
 	p.Pos.X = int(binary.LittleEndian.Uint16(left[0:2]))
 	p.Pos.Y = int(binary.LittleEndian.Uint16(left[2:4]))
 	p.Flags = left[4]
@@ -96,6 +99,9 @@ func (p *MsgUpdateStream) decodeHeader(data []byte) (int, error) {
 	if p.Flags&0x80 != 0 {
 		p.Unk4 = left[0]
 		left = left[1:]
+		if len(left) < 1 {
+			return 0, io.ErrUnexpectedEOF
+		}
 	}
 	p.Unk5 = left[0]
 	left = left[1:]
@@ -103,6 +109,20 @@ func (p *MsgUpdateStream) decodeHeader(data []byte) (int, error) {
 }
 
 func (p *MsgUpdateStream) Decode(data []byte) (int, error) {
+	return p.DecodeResolved(data, nil)
+}
+
+// UpdateResolver provides object types required to decode MsgUpdateStream.
+type UpdateResolver interface {
+	// AliasType returns the object type for an alias.
+	AliasType(alias byte) (uint16, bool)
+	// IsComplex checks if objects of the type are complex, and thus carry additional state in updates.
+	IsComplex(typ uint16) bool
+}
+
+// DecodeResolved decodes the message, using the resolver to find object types of aliases.
+// Without the resolver, updates of complex objects referred by aliases cannot be decoded correctly.
+func (p *MsgUpdateStream) DecodeResolved(data []byte, r UpdateResolver) (int, error) {
 	left := data
 
 	n, err := p.decodeHeader(left)
@@ -114,7 +134,7 @@ func (p *MsgUpdateStream) Decode(data []byte) (int, error) {
 	p.Objects = nil
 	for len(left) != 0 {
 		var u ObjectUpdate
-		n, err = u.Decode(left, p.Pos)
+		n, err = u.decode(left, p.Pos, r)
 		if err == io.EOF {
 			left = left[n:]
 			break
@@ -234,6 +254,14 @@ type ComplexObjectUpdate struct {
 }
 
 func (p *ObjectUpdate) Decode(data []byte, par image.Point) (int, error) {
+	return p.decode(data, par, nil)
+}
+
+func (p *ObjectUpdate) decode(data []byte, par image.Point, r UpdateResolver) (int, error) {
+	isComplex := objectTypeIsComplex
+	if r != nil {
+		isComplex = r.IsComplex
+	}
 	left := data
 	if len(left) < 3 {
 		return 0, io.ErrUnexpectedEOF
@@ -249,32 +277,48 @@ func (p *ObjectUpdate) Decode(data []byte, par image.Point) (int, error) {
 		left = left[1:]
 		rel = false
 	}
-	isComplex := false
+	complexObj := false
 	if alias != 0xff {
 		p.ID = &UpdateAlias{alias}
-		// FIXME: cannot check if it's a complex object without a map
+		if r != nil {
+			if typ, ok := r.AliasType(alias); ok {
+				complexObj = isComplex(typ)
+			}
+		}
 	} else {
+		if len(left) < 4 {
+			return 0, io.ErrUnexpectedEOF
+		}
 		id := NetCode(binary.LittleEndian.Uint16(left[0:2]))
 		typ := binary.LittleEndian.Uint16(left[2:4])
 		left = left[4:]
 		p.ID = &UpdateObjectID{ID: id, Type: typ}
-		isComplex = objectTypeIsComplex(typ)
+		complexObj = isComplex(typ)
 	}
 	if !rel {
+		if len(left) < 4 {
+			return 0, io.ErrUnexpectedEOF
+		}
 		x := binary.LittleEndian.Uint16(left[0:2])
 		y := binary.LittleEndian.Uint16(left[2:4])
 		left = left[4:]
 		p.Pos = image.Point{X: int(x), Y: int(y)}
 	} else {
+		if len(left) < 2 {
+			return 0, io.ErrUnexpectedEOF
+		}
 		dx := left[0]
 		dy := left[1]
 		left = left[2:]
 		p.Pos = par.Add(image.Point{X: int(dx), Y: int(dy)})
 	}
-	if !isComplex {
+	if !complexObj {
 		p.Complex = nil
 		return len(data) - len(left), nil
 	}
+	if len(left) < 3 {
+		return 0, io.ErrUnexpectedEOF
+	}
 	unk0 := left[0]
 	unk1 := left[1]
 	unk2 := left[2]
@@ -45,3 +45,7 @@ func (m *MsgUseMap) Decode(data []byte) (int, error) {
 	m.T = Timestamp(binary.LittleEndian.Uint32(data[36:40]))
 	return 40, nil
 }
+
+func (m *MsgUseMap) UpdateFrame(s *netmsg.State) {
+	s.SetFrame(uint32(m.T))
+}
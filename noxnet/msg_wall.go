@@ -0,0 +1,105 @@
+package noxnet
+
+import (
+	"io"
+
+	"github.com/opennox/libs/maps"
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func init() {
+	netmsg.Register(&MsgWallOpen{}, false)
+	netmsg.Register(&MsgWallClose{}, false)
+	netmsg.Register(&MsgWallMagic{}, false)
+	netmsg.Register(&MsgWallMagicRemove{}, false)
+}
+
+// WallRef is a common payload for messages referring to a single wall on the grid.
+type WallRef struct {
+	Pos maps.WallPos
+}
+
+func (*WallRef) EncodeSize() int {
+	return 2
+}
+
+func (p *WallRef) Encode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Pos.X
+	data[1] = p.Pos.Y
+	return 2, nil
+}
+
+func (p *WallRef) Decode(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Pos.X = data[0]
+	p.Pos.Y = data[1]
+	return 2, nil
+}
+
+type MsgWallOpen struct {
+	WallRef
+}
+
+func (*MsgWallOpen) NetOp() netmsg.Op {
+	return netmsg.MSG_OPEN_WALL
+}
+
+type MsgWallClose struct {
+	WallRef
+}
+
+func (*MsgWallClose) NetOp() netmsg.Op {
+	return netmsg.MSG_CLOSE_WALL
+}
+
+type MsgWallMagicRemove struct {
+	WallRef
+}
+
+func (*MsgWallMagicRemove) NetOp() netmsg.Op {
+	return netmsg.MSG_REMOVE_WALL_MAGIC
+}
+
+type MsgWallMagic struct {
+	Pos      maps.WallPos
+	Dir      byte
+	Material byte
+	Variant  byte
+}
+
+func (*MsgWallMagic) NetOp() netmsg.Op {
+	return netmsg.MSG_CHANGE_OR_ADD_WALL_MAGIC
+}
+
+func (*MsgWallMagic) EncodeSize() int {
+	return 5
+}
+
+func (p *MsgWallMagic) Encode(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, io.ErrShortBuffer
+	}
+	data[0] = p.Pos.X
+	data[1] = p.Pos.Y
+	data[2] = p.Dir
+	data[3] = p.Material
+	data[4] = p.Variant
+	return 5, nil
+}
+
+func (p *MsgWallMagic) Decode(data []byte) (int, error) {
+	if len(data) < 5 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	p.Pos.X = data[0]
+	p.Pos.Y = data[1]
+	p.Dir = data[2]
+	p.Material = data[3]
+	p.Variant = data[4]
+	return 5, nil
+}
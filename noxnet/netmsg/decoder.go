@@ -28,6 +28,7 @@ func (s *State) Decode(data []byte, p Message) (int, error) {
 		if err != nil {
 			return 0, err
 		}
+		s.decoded(p)
 		return 1 + n, err
 	}
 	if s == nil {
@@ -37,6 +38,7 @@ func (s *State) Decode(data []byte, p Message) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	s.decoded(cp)
 	return 1 + n, nil
 }
 
@@ -73,6 +75,7 @@ func (s *State) DecodeNext(data []byte) (Message, int, error) {
 		if err != nil {
 			return nil, 0, err
 		}
+		s.decoded(p)
 		return p, 1 + n, nil
 	}
 	if s == nil {
@@ -85,5 +88,6 @@ func (s *State) DecodeNext(data []byte) (Message, int, error) {
 	if err != nil {
 		return nil, 0, err
 	}
+	s.decoded(cp)
 	return cp, 1 + n, nil
 }
@@ -3,6 +3,7 @@ package netmsg
 import (
 	"io"
 	"reflect"
+	"slices"
 
 	"github.com/opennox/libs/binenc"
 )
@@ -57,6 +58,53 @@ func RegisterServer(p Message, dynamic bool) {
 	opLen[op] = -1
 }
 
+// Registration describes a message type registered for an opcode.
+type Registration struct {
+	Op Op
+	// Type is a struct type of the message.
+	Type reflect.Type
+	// Client is set for messages registered with RegisterClient.
+	Client bool
+	// Server is set for messages registered with RegisterServer.
+	Server bool
+}
+
+// New creates a new message of this type.
+func (r Registration) New() Message {
+	return reflect.New(r.Type).Interface().(Message)
+}
+
+// Registered returns all registered message types, sorted by opcode.
+// Messages registered for both the client and the server are listed first.
+func Registered() []Registration {
+	out := make([]Registration, 0, len(byOp)+len(byOpCli)+len(byOpSrv))
+	for op, rt := range byOp {
+		out = append(out, Registration{Op: op, Type: rt})
+	}
+	for op, rt := range byOpCli {
+		out = append(out, Registration{Op: op, Type: rt, Client: true})
+	}
+	for op, rt := range byOpSrv {
+		out = append(out, Registration{Op: op, Type: rt, Server: true})
+	}
+	side := func(r Registration) int {
+		switch {
+		case r.Client:
+			return 1
+		case r.Server:
+			return 2
+		}
+		return 0
+	}
+	slices.SortFunc(out, func(a, b Registration) int {
+		if a.Op != b.Op {
+			return int(a.Op) - int(b.Op)
+		}
+		return side(a) - side(b)
+	})
+	return out
+}
+
 type Message interface {
 	NetOp() Op
 	binenc.Encoded
@@ -0,0 +1,198 @@
+// Package msgtest implements conformance checks for netmsg.Message implementations.
+//
+// Packages that define their own messages can run the checks in their tests:
+//
+//	func TestMessages(t *testing.T) {
+//		msgtest.CheckRegistered(t, msgtest.InPackage("example.com/mygame/msgs"))
+//	}
+//
+//	func FuzzMessages(f *testing.F) {
+//		msgtest.Fuzz(f, msgtest.InPackage("example.com/mygame/msgs"))
+//	}
+package msgtest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+// RandomTries is the number of random payloads tried for each message type by CheckRegistered.
+var RandomTries = 200
+
+// Filter selects registered messages to check.
+type Filter func(r netmsg.Registration) bool
+
+// InPackage selects messages defined in a given Go package.
+func InPackage(path string) Filter {
+	return func(r netmsg.Registration) bool {
+		return r.Type.PkgPath() == path
+	}
+}
+
+// Registrations returns registered messages matching the filter. Nil filter selects all messages.
+func Registrations(filter Filter) []netmsg.Registration {
+	var out []netmsg.Registration
+	for _, r := range netmsg.Registered() {
+		if filter == nil || filter(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// States returns decoder states which can be used for the message.
+// Side-specific messages get one state, while common messages are checked on both sides.
+func States(r netmsg.Registration) []*netmsg.State {
+	switch {
+	case r.Client:
+		return []*netmsg.State{{Options: netmsg.Options{IsClient: true}}}
+	case r.Server:
+		return []*netmsg.State{{}}
+	}
+	return []*netmsg.State{{}, {Options: netmsg.Options{IsClient: true}}}
+}
+
+func newMessage(m netmsg.Message) netmsg.Message {
+	return reflect.New(reflect.TypeOf(m).Elem()).Interface().(netmsg.Message)
+}
+
+// Encode encodes the message with the opcode and checks that EncodeSize matches the number of bytes written.
+func Encode(t testing.TB, st *netmsg.State, m netmsg.Message) []byte {
+	t.Helper()
+	sz := st.EncodeSize(m)
+	// extra space to catch writes past the declared size
+	buf := make([]byte, sz+16)
+	n, err := st.Encode(buf, m)
+	must.NoError(t, err, must.Sprintf("%v: encode", m.NetOp()))
+	must.EqOp(t, sz, n, must.Sprintf("%v: EncodeSize doesn't match encoded size", m.NetOp()))
+	must.SliceEmpty(t, bytes.Trim(buf[n:], "\x00"), must.Sprintf("%v: Encode wrote past EncodeSize", m.NetOp()))
+	return buf[:n]
+}
+
+// Check encodes and decodes the message, and checks that the result is the same as the original message.
+//
+// Complex messages are encoded and decoded with a given state. State can be nil for simple messages.
+func Check(t testing.TB, st *netmsg.State, m netmsg.Message) {
+	t.Helper()
+	data := Encode(t, st, m)
+
+	m2 := newMessage(m)
+	n, err := st.Decode(data, m2)
+	must.NoError(t, err, must.Sprintf("%v: decode %x", m.NetOp(), data))
+	must.EqOp(t, len(data), n, must.Sprintf("%v: decoded size doesn't match encoded size", m.NetOp()))
+	// NaN floats and empty slices cannot be distinguished after decoding
+	must.Eq(t, m, m2, must.Cmp(cmpopts.EquateNaNs(), cmpopts.EquateEmpty()), must.Sprintf("%v: decoded message differs", m.NetOp()))
+
+	data2 := Encode(t, st, m2)
+	must.Eq(t, data, data2, must.Sprintf("%v: encoding is not stable", m.NetOp()))
+}
+
+// CheckDecoded decodes the message from data and checks the result with Check.
+// It returns false if data cannot be decoded.
+func CheckDecoded(t testing.TB, st *netmsg.State, data []byte) bool {
+	t.Helper()
+	m, _, err := st.DecodeNext(data)
+	if err != nil {
+		return false
+	}
+	if _, ok := m.(*netmsg.Unknown); ok {
+		return false
+	}
+	Check(t, st, m)
+	return true
+}
+
+// randomPayload generates random data for a message. Size is picked around the encoded size of the zero value.
+func randomPayload(rnd *rand.Rand, r netmsg.Registration, st *netmsg.State) []byte {
+	sz := st.EncodeSize(r.New())
+	switch rnd.IntN(4) {
+	case 0:
+		sz += rnd.IntN(64)
+	case 1:
+		sz += rnd.IntN(4)
+	}
+	data := make([]byte, sz)
+	for i := range data {
+		data[i] = byte(rnd.Uint32())
+	}
+	data[0] = byte(r.Op)
+	return data
+}
+
+// CheckRegistered runs conformance checks for all registered messages matching the filter.
+//
+// Each message type is checked with a zero value and with values decoded from random payloads.
+// Zero values are skipped if they cannot be encoded, for example when a required interface field is nil.
+func CheckRegistered(t *testing.T, filter Filter) {
+	list := Registrations(filter)
+	if len(list) == 0 {
+		t.Fatal("no messages to check")
+	}
+	for _, r := range list {
+		t.Run(fmt.Sprintf("%v/%s", r.Op, r.Type.Name()), func(t *testing.T) {
+			for _, st := range States(r) {
+				if _, err := st.Append(nil, r.New()); err != nil {
+					t.Logf("client=%v: zero value cannot be encoded: %v", st.IsClient, err)
+				} else {
+					Check(t, st, r.New())
+				}
+				rnd := rand.New(rand.NewPCG(uint64(r.Op), 0))
+				decoded := 0
+				for range RandomTries {
+					if CheckDecoded(t, st, randomPayload(rnd, r, st)) {
+						decoded++
+					}
+				}
+				t.Logf("client=%v: %d/%d random payloads decoded", st.IsClient, decoded, RandomTries)
+			}
+		})
+	}
+}
+
+// Fuzz runs a fuzz target that feeds random data to decoders of registered messages matching the filter.
+//
+// The first byte of the input selects the side (odd for the client), and the rest is a message with the opcode.
+// Messages that decode successfully must pass Check.
+func Fuzz(f *testing.F, filter Filter) {
+	list := Registrations(filter)
+	if len(list) == 0 {
+		f.Fatal("no messages to check")
+	}
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for _, r := range list {
+		for _, st := range States(r) {
+			side := byte(0)
+			if st.IsClient {
+				side = 1
+			}
+			data, err := st.Append([]byte{side}, r.New())
+			if err == nil {
+				f.Add(data)
+			}
+			f.Add(append([]byte{side}, randomPayload(rnd, r, st)...))
+		}
+	}
+	ops := make(map[netmsg.Op]struct{}, len(list))
+	for _, r := range list {
+		ops[r.Op] = struct{}{}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < 2 {
+			return
+		}
+		st := &netmsg.State{Options: netmsg.Options{IsClient: data[0]%2 == 1}}
+		data = data[1:]
+		if _, ok := ops[netmsg.Op(data[0])]; !ok {
+			return
+		}
+		CheckDecoded(t, st, data)
+	})
+}
@@ -6,4 +6,68 @@ type Options struct {
 
 type State struct {
 	Options
+	frame  uint32
+	synced bool
+}
+
+// FrameMessage is implemented by messages that carry the server frame number.
+// State calls UpdateFrame for each decoded message of this kind.
+type FrameMessage interface {
+	Message
+	UpdateFrame(s *State)
+}
+
+// Frame returns the last known server frame.
+func (s *State) Frame() uint32 {
+	if s == nil {
+		return 0
+	}
+	return s.frame
+}
+
+// FrameSynced reports if the full server frame number is known.
+// It becomes false after ResyncFrame, until the next full timestamp.
+func (s *State) FrameSynced() bool {
+	return s != nil && s.synced
+}
+
+// SetFrame sets the full server frame number.
+func (s *State) SetFrame(v uint32) {
+	s.frame = v
+	s.synced = true
+}
+
+// SetFrame16 updates the server frame from the lower 16 bits. See ExpandFrame16.
+func (s *State) SetFrame16(v uint16) {
+	s.frame = ExpandFrame16(s.frame, v)
+}
+
+// ResyncFrame marks the frame as out of sync, until the next SetFrame.
+// The client calls it when it requests the full timestamp from the server.
+func (s *State) ResyncFrame() {
+	s.synced = false
+}
+
+// ExpandFrame16 reconstructs the full frame number from the lower 16 bits, given the last known frame.
+// Out of order values are ignored.
+func ExpandFrame16(cur uint32, v uint16) uint32 {
+	cur16 := uint16(cur & 0xFFFF)
+	overflow := (cur16 >= 0xC000) && (v < 0x4000)
+	if !overflow && v < cur16 {
+		return cur // out of order
+	}
+	ts := (cur & 0xFFFF0000) | uint32(v)
+	if overflow {
+		ts += 0x10000
+	}
+	return ts
+}
+
+func (s *State) decoded(p Message) {
+	if s == nil {
+		return
+	}
+	if fm, ok := p.(FrameMessage); ok {
+		fm.UpdateFrame(s)
+	}
 }
@@ -24,10 +24,16 @@ func (*MsgXfer) NetOp() netmsg.Op {
 }
 
 func (m *MsgXfer) EncodeSize() int {
+	if m.Msg == nil {
+		return 1
+	}
 	return EncodeSize(m.Msg)
 }
 
 func (m *MsgXfer) Encode(data []byte) (int, error) {
+	if m.Msg == nil {
+		return 0, errors.New("xfer message is not set")
+	}
 	return Encode(data, m.Msg)
 }
 
@@ -1,17 +1,25 @@
 package noxnet
 
 import (
+	"bytes"
 	"context"
 	"log/slog"
 	"net/netip"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/shoenig/test/must"
+	"github.com/shoenig/test/wait"
 
+	"github.com/opennox/libs/console"
 	"github.com/opennox/libs/noxnet/discover"
+	"github.com/opennox/libs/noxnet/netmsg"
 	"github.com/opennox/libs/noxnet/udpconn"
+	"github.com/opennox/libs/strman"
 )
 
 const (
@@ -19,16 +27,26 @@ const (
 )
 
 func newServerAndClient(t testing.TB, e Engine) (*Server, *Client) {
+	return newServerAndClientIP(t, e, netip.Addr{}, netip.Addr{}, nil)
+}
+
+func newServerAndClientIP(t testing.TB, e Engine, srvIP, cliIP netip.Addr, opts *ServerOptions) (*Server, *Client) {
 	log := slog.Default()
 	srvC, cliC := udpconn.NewPipe(log, 10)
-	srvC.Addr = netip.AddrPortFrom(srvC.Addr.Addr(), udpconn.DefaultPort)
+	if !srvIP.IsValid() {
+		srvIP = srvC.Addr.Addr()
+	}
+	if cliIP.IsValid() {
+		cliC.Addr = netip.AddrPortFrom(cliIP, cliC.Addr.Port())
+	}
+	srvC.Addr = netip.AddrPortFrom(srvIP, udpconn.DefaultPort)
 	srvC.Debug = true
 	cliC.Debug = true
 	t.Cleanup(func() {
 		_ = cliC.Close()
 		_ = srvC.Close()
 	})
-	srv := NewServer(log, srvC, e, nil)
+	srv := NewServer(log, srvC, e, opts)
 	t.Cleanup(srv.Close)
 	cli := NewClient(log, cliC)
 	t.Cleanup(cli.Close)
@@ -38,11 +56,29 @@ func newServerAndClient(t testing.TB, e Engine) (*Server, *Client) {
 type testEngine struct {
 	t testing.TB
 
-	Info  discover.MsgServerInfo
-	OnTry func(req *MsgServerTryJoin) error
-	Pass  string
+	Info      discover.MsgServerInfo
+	OnTry     func(req *MsgServerTryJoin) error
+	OnAction  func(p Player, m TryMsg)
+	OnConnect func(addr netip.AddrPort) (Player, error)
+	Flood     func(addr netip.AddrPort, reason FloodReason) bool
+	Pass      string
+}
+
+type testPlayer struct {
+	id   PlayerID
+	name string
+}
+
+func (p *testPlayer) PlayerID() PlayerID {
+	return p.id
 }
 
+func (p *testPlayer) PlayerName() string {
+	return p.name
+}
+
+func (p *testPlayer) Disconnect() {}
+
 func (e *testEngine) ServerInfo(addr netip.AddrPort) *discover.MsgServerInfo {
 	v := e.Info
 	return &v
@@ -65,35 +101,64 @@ func (e *testEngine) CheckPass(addr netip.AddrPort, pass string) error {
 	return nil
 }
 
+func (e *testEngine) PlayerAction(p Player, m TryMsg) bool {
+	if e.OnAction == nil {
+		return false
+	}
+	e.OnAction(p, m)
+	return true
+}
+
 func (e *testEngine) Connect(addr netip.AddrPort) (Player, error) {
-	//TODO implement me
-	panic("implement me")
+	if e.OnConnect == nil {
+		return nil, ErrFull
+	}
+	return e.OnConnect(addr)
+}
+
+func (e *testEngine) OnFlood(addr netip.AddrPort, reason FloodReason) bool {
+	if e.Flood == nil {
+		return false
+	}
+	return e.Flood(addr, reason)
 }
 
 func TestDiscover(t *testing.T) {
-	e := &testEngine{t: t, Info: discover.MsgServerInfo{
-		PlayersCur: 3,
-		PlayersMax: 250,
-		MapName:    "testmap",
-		ServerName: "TestServer",
-	}}
-	srv, cli := newServerAndClient(t, e)
-	ctx, cancel := context.WithTimeout(context.Background(), resendTick)
-	defer cancel()
+	cases := []struct {
+		name string
+		srv  netip.Addr
+		cli  netip.Addr
+	}{
+		{"ipv4", netip.Addr{}, netip.Addr{}},
+		{"ipv6", netip.MustParseAddr("fe80::1"), netip.MustParseAddr("fe80::2")},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := &testEngine{t: t, Info: discover.MsgServerInfo{
+				PlayersCur: 3,
+				PlayersMax: 250,
+				MapName:    "testmap",
+				ServerName: "TestServer",
+			}}
+			srv, cli := newServerAndClientIP(t, e, c.srv, c.cli, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), resendTick)
+			defer cancel()
 
-	out := make(chan ServerInfoResp, 10)
-	err := cli.Discover(ctx, 0, out)
-	must.NoError(t, err)
-	close(out)
+			out := make(chan ServerInfoResp, 10)
+			err := cli.Discover(ctx, 0, out)
+			must.NoError(t, err)
+			close(out)
 
-	var got []ServerInfoResp
-	for v := range out {
-		v.Info.Token = 0
-		got = append(got, v)
+			var got []ServerInfoResp
+			for v := range out {
+				v.Info.Token = 0
+				got = append(got, v)
+			}
+			must.Eq(t, []ServerInfoResp{
+				{Addr: srv.LocalAddr(), Info: e.Info},
+			}, got)
+		})
 	}
-	must.Eq(t, []ServerInfoResp{
-		{Addr: srv.LocalAddr(), Info: e.Info},
-	}, got)
 }
 
 func TestPreJoin(t *testing.T) {
@@ -120,3 +185,656 @@ func TestPreJoin(t *testing.T) {
 	err = cli.TryPassword(ctx, pass)
 	must.NoError(t, err)
 }
+
+// TestConnectLostAck checks that the client doesn't depend on the ACK for MsgConnect.
+// The server enables encryption once MsgServerAccept is acknowledged, so the ACK may reach the client
+// already encrypted, which is the same as losing it.
+func TestConnectLostAck(t *testing.T) {
+	log := slog.Default()
+	srvC, cliC := udpconn.NewPipe(log, 10)
+	srvC.Addr = netip.AddrPortFrom(srvC.Addr.Addr(), udpconn.DefaultPort)
+	t.Cleanup(func() {
+		_ = cliC.Close()
+		_ = srvC.Close()
+	})
+	srvC.Drop = func(data []byte) bool {
+		var h udpconn.Header
+		h.Decode([2]byte{data[0], data[1]})
+		// MsgConnect is the first reliable packet from the client, thus ACK 1 covers only that packet
+		return !h.Flags.Has(udpconn.Reliable) && h.Seq == 1
+	}
+	pl := &testPlayer{id: 1, name: "Player"}
+	e := &testEngine{
+		t: t,
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			return pl, nil
+		},
+	}
+	srv := NewServer(log, srvC, e, nil)
+	t.Cleanup(srv.Close)
+	srv.Port.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		if _, ok := m.(*MsgClientAccept); ok {
+			_ = s.SendUnreliable(&MsgJoinData{NetCode: 1})
+			return true
+		}
+		return false
+	})
+	cli := NewClient(log, cliC)
+	t.Cleanup(cli.Close)
+
+	// shorter than the resend interval, so the client cannot wait for MsgConnect to be resent
+	ctx, cancel := context.WithTimeout(context.Background(), 25*resendTick)
+	defer cancel()
+	err := cli.Connect(ctx, srv.LocalAddr(), &MsgClientAccept{})
+	must.NoError(t, err)
+}
+
+// TestServerEncrypt checks that the server encrypts the connection only after MsgServerAccept is acknowledged.
+func TestServerEncrypt(t *testing.T) {
+	log := slog.Default()
+	srvC, cliC := udpconn.NewPipe(log, 10)
+	srvC.Addr = netip.AddrPortFrom(srvC.Addr.Addr(), udpconn.DefaultPort)
+	t.Cleanup(func() {
+		_ = cliC.Close()
+		_ = srvC.Close()
+	})
+	var (
+		mu   sync.Mutex
+		sent [][]byte
+	)
+	srvC.Drop = func(data []byte) bool {
+		mu.Lock()
+		sent = append(sent, slices.Clone(data))
+		mu.Unlock()
+		return false
+	}
+	lastSent := func() []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return sent[len(sent)-1]
+	}
+	pl := &testPlayer{id: 1, name: "Player"}
+	e := &testEngine{
+		t: t,
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			return pl, nil
+		},
+	}
+	srv := NewServer(log, srvC, e, nil)
+	t.Cleanup(srv.Close)
+	srv.Port.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		if _, ok := m.(*MsgClientAccept); ok {
+			_ = s.SendUnreliable(&MsgJoinData{NetCode: 1})
+			return true
+		}
+		return false
+	})
+
+	// raw client, to control when the key is acknowledged
+	cli := udpconn.NewPort(log, cliC, netmsg.Options{IsClient: true})
+	t.Cleanup(cli.Close)
+	got := make(chan netmsg.Message, 10)
+	cli.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		switch m.(type) {
+		case *MsgServerAccept, *MsgJoinData:
+			got <- m
+			return true
+		}
+		return false
+	})
+	cli.Start()
+	recv := func() netmsg.Message {
+		t.Helper()
+		select {
+		case m := <-got:
+			return m
+		case <-time.After(10 * resendTick):
+			t.Fatal("expected a message")
+			return nil
+		}
+	}
+	conn := cli.Conn(srv.LocalAddr())
+	s := conn.Stream(udpconn.MaxStreamID)
+	s.QueueReliable(udpconn.Options{}, &MsgConnect{})
+	err := s.SendQueue()
+	must.NoError(t, err)
+	acc, ok := recv().(*MsgServerAccept)
+	must.True(t, ok)
+	must.NonZero(t, acc.XorKey)
+	// the key itself is sent in plain text
+	plain, err := netmsg.Append(nil, acc)
+	must.NoError(t, err)
+	mu.Lock()
+	must.SliceContainsFunc(t, sent, plain, bytes.Contains)
+	mu.Unlock()
+
+	err = conn.Ack()
+	must.NoError(t, err)
+	conn.Encrypt(acc.XorKey)
+	err = conn.Stream(udpconn.SID(acc.ID)).SendUnreliable(&MsgClientAccept{PlayerInfo: PlayerInfo{PlayerName: pl.name}})
+	must.NoError(t, err)
+	// the server could only decode it if it decrypts the connection
+	must.Eq[netmsg.Message](t, &MsgJoinData{NetCode: 1}, recv())
+	raw := lastSent()
+	must.NotEqOp(t, byte(netmsg.MSG_JOIN_DATA), raw[2])
+	must.EqOp(t, byte(netmsg.MSG_JOIN_DATA), raw[2]^acc.XorKey)
+}
+
+func TestConnectRejected(t *testing.T) {
+	pl := &testPlayer{id: 1, name: "Player"}
+	e := &testEngine{
+		t: t,
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			return pl, nil
+		},
+	}
+	srv, cli := newServerAndClient(t, e)
+	srv.Port.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		if _, ok := m.(*MsgClientAccept); ok {
+			// the rejection arrives after the ACK
+			time.AfterFunc(10*resendTick, func() {
+				_ = s.Conn().Stream(udpconn.ServerStreamID).SendUnreliable(&MsgServerError{Err: ErrBanned})
+			})
+			return true
+		}
+		return false
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*resendTick)
+	defer cancel()
+	err := cli.Connect(ctx, srv.LocalAddr(), &MsgClientAccept{})
+	must.ErrorIs(t, err, ErrBanned)
+}
+
+func TestPlayerAction(t *testing.T) {
+	got := make(chan TryMsg, 1)
+	pl := &testPlayer{id: 1, name: "Player"}
+	e := &testEngine{
+		t: t,
+		OnAction: func(p Player, m TryMsg) {
+			must.EqOp[Player](t, pl, p)
+			got <- m
+		},
+	}
+	srv, cli := newServerAndClient(t, e)
+	sid, err := srv.players.mapper.NewPlayer(cli.LocalAddr(), pl, pl)
+	must.NoError(t, err)
+
+	exp := &MsgTryUse{TryObject{NetCode: 1204}}
+	err = cli.Port.Conn(srv.LocalAddr()).Stream(sid).SendUnreliable(exp)
+	must.NoError(t, err)
+
+	select {
+	case m := <-got:
+		must.Eq[TryMsg](t, exp, m)
+	case <-time.After(5 * resendTick):
+		t.Fatal("expected an action")
+	}
+}
+
+func TestServerSysop(t *testing.T) {
+	pl := &testPlayer{id: 1, name: "Player"}
+	cn := console.New(nil)
+	cn.Localize(strman.New())
+	cn.Register(&console.Command{
+		Token: "echo", Flags: console.Server,
+		Func: func(ctx context.Context, c *console.Console, tokens []string) bool {
+			must.True(t, console.IsServer(ctx))
+			c.Print(console.ColorWhite, strings.Join(tokens, " "))
+			return true
+		},
+	})
+	var srv *Server
+	// commands may use sysop state of the server
+	cn.Register(&console.Command{
+		Token: "logout", Flags: console.Server,
+		Func: func(ctx context.Context, c *console.Console, tokens []string) bool {
+			srv.RevokeSysop(pl)
+			c.Printf(console.ColorWhite, "sysop: %v", srv.IsSysop(pl))
+			return true
+		},
+	})
+	srv, cli := newServerAndClientIP(t, &testEngine{t: t}, netip.Addr{}, netip.Addr{}, &ServerOptions{
+		Sysop: &SysopOptions{Password: "secret", Console: cn},
+	})
+	sid, err := srv.players.mapper.NewPlayer(cli.LocalAddr(), pl, pl)
+	must.NoError(t, err)
+
+	got := make(chan netmsg.Message, 10)
+	cli.Port.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		switch m.(type) {
+		case *MsgSysopResult, *MsgText:
+			got <- m
+			return true
+		}
+		return false
+	})
+	recv := func() netmsg.Message {
+		t.Helper()
+		select {
+		case m := <-got:
+			return m
+		case <-time.After(5 * resendTick):
+			t.Fatal("expected sysop result")
+			return nil
+		}
+	}
+	expect := func(exp netmsg.Message) {
+		t.Helper()
+		must.Eq(t, exp, recv())
+	}
+	s := cli.Port.Conn(srv.LocalAddr()).Stream(sid)
+
+	// commands are ignored before login
+	err = s.SendUnreliable(&MsgServerCmd{Cmd: "echo hello"})
+	must.NoError(t, err)
+	err = s.SendUnreliable(&MsgSysopPass{Pass: "wrong"})
+	must.NoError(t, err)
+	expect(&MsgSysopResult{Result: SysopDenied})
+	must.False(t, srv.IsSysop(pl))
+
+	err = s.SendUnreliable(&MsgSysopPass{Pass: "secret"})
+	must.NoError(t, err)
+	expect(&MsgSysopResult{Result: SysopGranted})
+	must.True(t, srv.IsSysop(pl))
+
+	err = s.SendUnreliable(&MsgServerCmd{Cmd: "echo hello world"})
+	must.NoError(t, err)
+	expect(noticeText("hello world"))
+
+	err = s.SendUnreliable(&MsgServerCmd{Cmd: "unknown"})
+	must.NoError(t, err)
+	m, ok := recv().(*MsgText)
+	must.True(t, ok)
+	must.True(t, m.Flags.Has(TextNotice))
+
+	err = s.SendUnreliable(&MsgServerCmd{Cmd: "logout"})
+	must.NoError(t, err)
+	expect(noticeText("sysop: false"))
+	must.False(t, srv.IsSysop(pl))
+}
+
+func TestServerSysopLockout(t *testing.T) {
+	pl := &testPlayer{id: 1, name: "Player"}
+	flood := make(chan FloodReason, 10)
+	e := &testEngine{
+		t: t,
+		Flood: func(addr netip.AddrPort, reason FloodReason) bool {
+			flood <- reason
+			return true
+		},
+	}
+	srv, cli := newServerAndClientIP(t, e, netip.Addr{}, netip.Addr{}, &ServerOptions{
+		Sysop: &SysopOptions{Password: "secret", Console: console.New(nil), MaxAttempts: 2},
+	})
+	sid, err := srv.players.mapper.NewPlayer(cli.LocalAddr(), pl, pl)
+	must.NoError(t, err)
+
+	got := make(chan SysopResult, 10)
+	cli.Port.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		if m, ok := m.(*MsgSysopResult); ok {
+			got <- m.Result
+			return true
+		}
+		return false
+	})
+	s := cli.Port.Conn(srv.LocalAddr()).Stream(sid)
+	login := func(pass string) SysopResult {
+		t.Helper()
+		err := s.SendUnreliable(&MsgSysopPass{Pass: pass})
+		must.NoError(t, err)
+		select {
+		case res := <-got:
+			return res
+		case <-time.After(5 * resendTick):
+			t.Fatal("expected sysop result")
+			return 0
+		}
+	}
+
+	must.EqOp(t, SysopDenied, login("wrong1"))
+	must.EqOp(t, 0, len(flood))
+	must.EqOp(t, SysopDenied, login("wrong2"))
+	select {
+	case reason := <-flood:
+		must.EqOp(t, FloodSysop, reason)
+	case <-time.After(5 * resendTick):
+		t.Fatal("expected flood report")
+	}
+	// locked out, even with a correct password
+	must.EqOp(t, SysopDenied, login("secret"))
+	must.False(t, srv.IsSysop(pl))
+
+	// the address stays blocked after the player reconnects
+	srv.RevokeSysop(pl)
+	must.EqOp(t, SysopDenied, login("secret"))
+	srv.Unblock(cli.LocalAddr().Addr())
+	must.EqOp(t, SysopGranted, login("secret"))
+	must.True(t, srv.IsSysop(pl))
+}
+
+func TestFloodDiscover(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reasons []FloodReason
+	)
+	e := &testEngine{
+		t: t,
+		Flood: func(addr netip.AddrPort, reason FloodReason) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			reasons = append(reasons, reason)
+			return reason == FloodAddrRate
+		},
+	}
+	srv, cli := newServerAndClientIP(t, e, netip.Addr{}, netip.Addr{}, &ServerOptions{
+		Flood: FloodOptions{PerAddr: RateLimit{Rate: 0.01, Burst: 2}},
+	})
+	got := make(chan ServerInfoResp, 10)
+	cli.discover.Lock()
+	cli.discover.byToken = map[uint32]chan<- ServerInfoResp{1: got}
+	cli.discover.Unlock()
+
+	s := cli.Port.Conn(srv.LocalAddr()).Stream(udpconn.ServerStreamID)
+	for range 5 {
+		err := s.SendUnreliable(&discover.MsgDiscover{Token: 1})
+		must.NoError(t, err)
+	}
+	must.Wait(t, wait.InitialSuccess(
+		wait.BoolFunc(func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(reasons) == 3
+		}),
+		wait.Timeout(5*resendTick),
+		wait.Gap(resendTick/4),
+	))
+	mu.Lock()
+	must.SliceEqOp(t, []FloodReason{FloodAddrRate, FloodBlocked, FloodBlocked}, reasons)
+	mu.Unlock()
+	must.Eq(t, 2, len(got))
+}
+
+func TestFloodHalfOpen(t *testing.T) {
+	var connects atomic.Int32
+	halfOpen := make(chan netip.AddrPort, 1)
+	pl := &testPlayer{id: 1, name: "Player"}
+	e := &testEngine{
+		t: t,
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			connects.Add(1)
+			return pl, nil
+		},
+		Flood: func(addr netip.AddrPort, reason FloodReason) bool {
+			if reason == FloodHalfOpen {
+				halfOpen <- addr
+			}
+			return false
+		},
+	}
+	srv, cli := newServerAndClientIP(t, e, netip.Addr{}, netip.Addr{}, &ServerOptions{
+		Flood: FloodOptions{MaxPending: 1, HalfOpenTimeout: 5 * resendTick},
+	})
+	err := cli.Port.Conn(srv.LocalAddr()).Stream(udpconn.MaxStreamID).SendUnreliable(&MsgConnect{})
+	must.NoError(t, err)
+	must.Wait(t, wait.InitialSuccess(
+		wait.BoolFunc(func() bool { return srv.Pending() == 1 }),
+		wait.Timeout(5*resendTick),
+		wait.Gap(resendTick/4),
+	))
+
+	// second join must be rejected without calling the engine
+	other := netip.MustParseAddrPort("10.0.0.1:1234")
+	srv.handleConnectMsg(srv.Port.Conn(other).Stream(udpconn.ServerStreamID), &MsgConnect{})
+	must.EqOp(t, 1, srv.Pending())
+	must.EqOp(t, 1, connects.Load())
+
+	select {
+	case addr := <-halfOpen:
+		must.EqOp(t, cli.LocalAddr(), addr)
+	case <-time.After(20 * resendTick):
+		t.Fatal("expected a half-open timeout")
+	}
+	must.EqOp(t, 0, srv.Pending())
+	must.Nil(t, srv.players.mapper.GetPlayer(cli.LocalAddr(), 1))
+}
+
+func TestFloodJoinComplete(t *testing.T) {
+	got := make(chan TryMsg, 1)
+	pl := &testPlayer{id: 1, name: "Player"}
+	pl2 := &testPlayer{id: 2, name: "Player2"}
+	var connected atomic.Int32
+	e := &testEngine{
+		t: t,
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			if connected.Add(1) == 1 {
+				return pl, nil
+			}
+			return pl2, nil
+		},
+		OnAction: func(p Player, m TryMsg) {
+			got <- m
+		},
+	}
+	srv, cli := newServerAndClientIP(t, e, netip.Addr{}, netip.Addr{}, &ServerOptions{
+		Flood: FloodOptions{MaxPending: 1, HalfOpenTimeout: time.Minute},
+		NoXor: true, // the raw client never enables encryption
+	})
+	conn := cli.Port.Conn(srv.LocalAddr())
+	err := conn.Stream(udpconn.MaxStreamID).SendUnreliable(&MsgConnect{})
+	must.NoError(t, err)
+	must.Wait(t, wait.InitialSuccess(
+		wait.BoolFunc(func() bool { return srv.Pending() == 1 }),
+		wait.Timeout(5*resendTick),
+		wait.Gap(resendTick/4),
+	))
+
+	err = conn.Stream(1).SendUnreliable(&MsgTryUse{TryObject{NetCode: 1204}})
+	must.NoError(t, err)
+	select {
+	case <-got:
+	case <-time.After(5 * resendTick):
+		t.Fatal("expected an action")
+	}
+	must.EqOp(t, 0, srv.Pending())
+	must.EqOp[Player](t, pl, srv.players.mapper.GetPlayer(cli.LocalAddr(), 1))
+
+	// only the first message completes the join, later ones must not affect new connections
+	err = conn.Stream(udpconn.MaxStreamID).SendUnreliable(&MsgConnect{})
+	must.NoError(t, err)
+	must.Wait(t, wait.InitialSuccess(
+		wait.BoolFunc(func() bool { return srv.Pending() == 1 }),
+		wait.Timeout(5*resendTick),
+		wait.Gap(resendTick/4),
+	))
+	err = conn.Stream(1).SendUnreliable(&MsgTryUse{TryObject{NetCode: 1204}})
+	must.NoError(t, err)
+	select {
+	case <-got:
+	case <-time.After(5 * resendTick):
+		t.Fatal("expected an action")
+	}
+	must.EqOp(t, 1, srv.Pending())
+}
+
+func TestFloodManyAddrs(t *testing.T) {
+	srv, _ := newServerAndClientIP(t, &testEngine{t: t}, netip.Addr{}, netip.Addr{}, &ServerOptions{
+		Flood: FloodOptions{
+			PerAddr: RateLimit{Rate: 1, Burst: 1},
+			Global:  RateLimit{Rate: 1, Burst: 2*maxFloodAddrs + 1},
+		},
+	})
+	addrAt := func(i int) netip.AddrPort {
+		return netip.AddrPortFrom(netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}), 1234)
+	}
+	now := time.Now()
+	first := addrAt(0)
+	must.EqOp(t, 0, srv.checkPacket(first, now))
+	// spoofed addresses fill the table, but it stays bounded
+	for i := 1; i <= 2*maxFloodAddrs; i++ {
+		srv.checkPacket(addrAt(i), now)
+		must.LessEq(t, maxFloodAddrs, len(srv.flood.byAddr))
+	}
+	// global limit is reached, new addresses are rejected without being tracked
+	must.EqOp(t, FloodGlobalRate, srv.checkPacket(addrAt(3*maxFloodAddrs), now))
+	must.MapNotContainsKey(t, srv.flood.byAddr, addrAt(3*maxFloodAddrs).Addr())
+	// least recently used buckets are evicted first
+	must.MapNotContainsKey(t, srv.flood.byAddr, first.Addr())
+	must.MapContainsKey(t, srv.flood.byAddr, addrAt(2*maxFloodAddrs).Addr())
+	must.EqOp(t, maxFloodAddrs, srv.flood.addrs.Len())
+
+	// expired blocks are removed, even if these addresses are never seen again
+	for i := range 10 {
+		srv.Block(addrAt(i).Addr(), time.Second)
+	}
+	must.MapLen(t, 10, srv.flood.blocked)
+	srv.checkPacket(first, now.Add(floodPruneInterval+time.Second))
+	must.MapEmpty(t, srv.flood.blocked)
+}
+
+// connectTestPlayer connects a player to the server and completes the join with MsgClientAccept.
+func connectTestPlayer(t testing.TB, opts *ServerOptions) (*Server, *Client, *testPlayer, chan netmsg.Message) {
+	pl := &testPlayer{id: 1, name: "Player"}
+	e := &testEngine{
+		t: t,
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			return pl, nil
+		},
+	}
+	if opts == nil {
+		opts = &ServerOptions{}
+	}
+	opts.NoXor = true
+	srv, cli := newServerAndClientIP(t, e, netip.Addr{}, netip.Addr{}, opts)
+	got := make(chan netmsg.Message, 10)
+	cli.Port.OnMessage(func(s udpconn.Stream, m netmsg.Message, flags udpconn.PacketFlags) bool {
+		switch m.(type) {
+		case *MsgServerAccept, *MsgText, *MsgKickNotification, *MsgTimeoutNotification, *MsgServerQuit:
+			got <- m
+			return true
+		}
+		return false
+	})
+	conn := cli.Port.Conn(srv.LocalAddr())
+	err := conn.Stream(udpconn.MaxStreamID).SendUnreliable(&MsgConnect{})
+	must.NoError(t, err)
+	// like a real client, wait for the stream ID before using it
+	expectMsg(t, got, &MsgServerAccept{ID: 1})
+	// half-open connections are not listed
+	must.SliceEmpty(t, srv.Players())
+	err = conn.Stream(1).SendUnreliable(&MsgClientAccept{PlayerInfo: PlayerInfo{PlayerName: pl.name}})
+	must.NoError(t, err)
+	must.Wait(t, wait.InitialSuccess(
+		wait.BoolFunc(func() bool { return len(srv.Players()) == 1 }),
+		wait.Timeout(5*resendTick),
+		wait.Gap(resendTick/4),
+	))
+	return srv, cli, pl, got
+}
+
+func expectMsg(t testing.TB, got <-chan netmsg.Message, exp netmsg.Message) {
+	t.Helper()
+	select {
+	case m := <-got:
+		must.Eq(t, exp, m)
+	case <-time.After(10 * resendTick):
+		t.Fatalf("expected %T", exp)
+	}
+}
+
+func TestServerKick(t *testing.T) {
+	srv, cli, pl, got := connectTestPlayer(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*resendTick)
+	defer cancel()
+	err := srv.Kick(ctx, pl, "bye")
+	must.NoError(t, err)
+	expectMsg(t, got, noticeText("bye"))
+	expectMsg(t, got, &MsgKickNotification{})
+	must.SliceEmpty(t, srv.Players())
+	must.Nil(t, srv.players.mapper.GetPlayer(cli.LocalAddr(), 1))
+
+	err = srv.Kick(ctx, pl, "")
+	must.Error(t, err)
+}
+
+func TestServerKickBusy(t *testing.T) {
+	srv, cli, pl, got := connectTestPlayer(t, nil)
+	// game traffic keeps the reliable queue busy, while the client acknowledges it
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		conn := srv.Port.Conn(cli.LocalAddr())
+		cconn := cli.Port.Conn(srv.LocalAddr())
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(resendTick / 4):
+			}
+			conn.Stream(1).QueueReliable(udpconn.Options{}, &MsgTimestamp{T: 1})
+			_ = conn.SendQueue()
+			_ = cconn.Ack()
+		}
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		wg.Wait()
+	})
+	time.Sleep(2 * resendTick)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*resendTick)
+	defer cancel()
+	err := srv.Kick(ctx, pl, "")
+	must.NoError(t, err)
+	expectMsg(t, got, &MsgKickNotification{})
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	srv, cli, _, got := connectTestPlayer(t, &ServerOptions{IdleTimeout: 5 * resendTick})
+	expectMsg(t, got, &MsgTimeoutNotification{})
+	must.Wait(t, wait.InitialSuccess(
+		wait.BoolFunc(func() bool { return srv.players.mapper.GetPlayer(cli.LocalAddr(), 1) == nil }),
+		wait.Timeout(20*resendTick),
+		wait.Gap(resendTick/4),
+	))
+	must.SliceEmpty(t, srv.Players())
+}
+
+func TestServerIdlePending(t *testing.T) {
+	pl := &testPlayer{id: 1, name: "Player"}
+	e := &testEngine{
+		t: t,
+		OnConnect: func(addr netip.AddrPort) (Player, error) {
+			return pl, nil
+		},
+	}
+	srv, cli := newServerAndClientIP(t, e, netip.Addr{}, netip.Addr{}, &ServerOptions{
+		NoXor:       true,
+		IdleTimeout: 2 * resendTick,
+		Flood:       FloodOptions{HalfOpenTimeout: time.Minute},
+	})
+	err := cli.Port.Conn(srv.LocalAddr()).Stream(udpconn.MaxStreamID).SendUnreliable(&MsgConnect{})
+	must.NoError(t, err)
+	must.Wait(t, wait.InitialSuccess(
+		wait.BoolFunc(func() bool { return srv.players.mapper.GetPlayer(cli.LocalAddr(), 1) != nil }),
+		wait.Timeout(5*resendTick),
+		wait.Gap(resendTick/4),
+	))
+	// half-open connections are left to the half-open timeout
+	time.Sleep(5 * resendTick)
+	must.NotNil(t, srv.players.mapper.GetPlayer(cli.LocalAddr(), 1))
+	must.EqOp(t, 1, srv.Pending())
+	must.SliceEmpty(t, srv.Players())
+}
+
+func TestServerShutdown(t *testing.T) {
+	srv, cli, _, got := connectTestPlayer(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*resendTick)
+	defer cancel()
+	err := srv.Shutdown(ctx, 5*resendTick)
+	must.NoError(t, err)
+	expectMsg(t, got, noticeText("Server is shutting down in 1s"))
+	expectMsg(t, got, &MsgServerQuit{})
+	must.SliceEmpty(t, srv.Players())
+	must.Nil(t, srv.players.mapper.GetPlayer(cli.LocalAddr(), 1))
+}
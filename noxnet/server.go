@@ -7,6 +7,7 @@ import (
 	"net/netip"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/opennox/libs/noxnet/discover"
 	"github.com/opennox/libs/noxnet/netmsg"
@@ -21,6 +22,10 @@ type Player interface {
 	Disconnect()
 }
 
+// Mapper assigns stream IDs to players.
+//
+// Addresses passed to Mapper are normalized with udpconn.NormalizeAddr,
+// so IPv4 clients connected to a dual-stack port are always seen with plain IPv4 addresses.
 type Mapper interface {
 	NewPlayer(addr netip.AddrPort, cli Player, p Player) (udpconn.SID, error)
 	GetPlayer(addr netip.AddrPort, sid udpconn.SID) Player
@@ -83,6 +88,17 @@ type Engine interface {
 	Connect(addr netip.AddrPort) (Player, error)
 }
 
+// ActionEngine is an optional interface for Engine that handles player action requests.
+type ActionEngine interface {
+	PlayerAction(p Player, m TryMsg) bool
+}
+
+// MessageEngine is an optional interface for Engine that handles other player messages.
+// The stream can be used to respond to the player.
+type MessageEngine interface {
+	PlayerMessage(s udpconn.Stream, p Player, m netmsg.Message) bool
+}
+
 func NewServer(log *slog.Logger, conn udpconn.PacketConn, e Engine, opts *ServerOptions) *Server {
 	p := udpconn.NewPort(log, conn, netmsg.Options{IsClient: false})
 	return NewServerWithPort(log, p, e, opts)
@@ -90,7 +106,18 @@ func NewServer(log *slog.Logger, conn udpconn.PacketConn, e Engine, opts *Server
 
 type ServerOptions struct {
 	PlayerMap Mapper
-	NoXor     bool
+	// NoXor disables encryption of player traffic. Otherwise, each player gets a random key with MsgServerAccept,
+	// and the server encrypts the connection once the client acknowledges it.
+	NoXor bool
+	// Flood configures rate limits for discovery and connection requests.
+	Flood FloodOptions
+	// Access is checked before the engine when players join or connect. If nil, everyone is allowed.
+	Access *AccessList
+	// Sysop enables remote sysop access. If nil, sysop messages are passed to the engine.
+	Sysop *SysopOptions
+	// IdleTimeout disconnects players that sent nothing for a given time, with MsgTimeoutNotification.
+	// Players that have not completed the join are dropped by FloodOptions.HalfOpenTimeout instead. Zero disables the timeout.
+	IdleTimeout time.Duration
 }
 
 func NewServerWithPort(log *slog.Logger, port *udpconn.Port, e Engine, opts *ServerOptions) *Server {
@@ -107,8 +134,16 @@ func NewServerWithPort(log *slog.Logger, port *udpconn.Port, e Engine, opts *Ser
 	}
 	s.players.mapper = opts.PlayerMap
 	s.players.noXor = opts.NoXor
+	s.flood.opts = opts.Flood.withDefaults()
+	s.access = opts.Access
+	if opts.Sysop != nil {
+		s.sysop.opts = opts.Sysop.withDefaults()
+	}
 	s.Port.OnMessage(s.handleMsg)
 	s.Port.Start()
+	if opts.IdleTimeout > 0 {
+		s.startIdleCheck(opts.IdleTimeout)
+	}
 	return s
 }
 
@@ -121,19 +156,39 @@ type Server struct {
 		mapper Mapper
 		noXor  bool
 	}
+
+	flood  floodState
+	access *AccessList
+	sysop  sysopState
+	active activeState
+}
+
+// Access returns the access list used by the server, or nil if it is not set.
+func (s *Server) Access() *AccessList {
+	return s.access
 }
 
 func (s *Server) LocalAddr() netip.AddrPort {
 	return s.Port.LocalAddr()
 }
 
+// ServerInfo returns the server info from Engine.ServerInfo, as it is reported to the lobby.
+// The engine is called with an empty address.
+func (s *Server) ServerInfo() *discover.MsgServerInfo {
+	return s.e.ServerInfo(netip.AddrPort{})
+}
+
+// Close stops the server without notifying players. See Shutdown.
 func (s *Server) Close() {
-	// TODO: disconnect all clients
+	s.stopIdleCheck()
 	s.Reset()
 	s.Port.Close()
 }
 
 func (s *Server) Reset() {
+	s.resetFlood()
+	s.resetSysop()
+	s.resetActive()
 	s.Port.Reset()
 }
 
@@ -146,10 +201,19 @@ func (s *Server) handleMsg(p udpconn.Stream, m netmsg.Message, flags udpconn.Pac
 			s.log.Warn("unhandled player message", "sid", p.SID(), "type", reflect.TypeOf(m).String(), "msg", m)
 			return false
 		}
+		if s.touchActive(pl) {
+			s.removePending(p.Conn().RemoteAddr(), nil)
+		}
 		return s.handlePlayerMsg(srv, pl, m)
 	case udpconn.ServerStreamID:
+		if !s.allowPacket(p.Conn().RemoteAddr()) {
+			return true // drop
+		}
 		return s.handleGlobalMsg(srv, m)
 	case udpconn.MaxStreamID:
+		if !s.allowPacket(p.Conn().RemoteAddr()) {
+			return true // drop
+		}
 		return s.handleConnectMsg(srv, m)
 	}
 }
@@ -167,8 +231,22 @@ func (s *Server) handleGlobalMsg(conn udpconn.Stream, m netmsg.Message) bool {
 		info.Token = m.Token
 		_ = conn.SendUnreliable(info)
 		return true
+	case *MsgServerQuitAck:
+		return true
 	case *MsgServerTryJoin:
-		err := s.e.PreJoin(conn.Addr(), m)
+		if s.isClosing() {
+			_ = conn.SendUnreliable(&MsgJoinFailed{})
+			return true
+		}
+		err := s.access.CheckAddr(conn.Addr().Addr())
+		if err == nil {
+			err = s.access.CheckName(m.PlayerName)
+		}
+		if err != nil {
+			s.log.Warn("join rejected", "addr", conn.Addr(), "name", m.PlayerName, "err", err)
+		} else {
+			err = s.e.PreJoin(conn.Addr(), m)
+		}
 		if e, ok := ErrorToMsg(err); ok && e != nil {
 			_ = conn.SendUnreliable(e)
 			return true
@@ -200,25 +278,53 @@ func (s *Server) handleConnectMsg(conn udpconn.Stream, m netmsg.Message) bool {
 	case *MsgConnect:
 		addr := conn.Addr()
 		log := s.log.With("addr", addr)
+		if s.isClosing() {
+			return true // shutting down
+		}
+		if err := s.access.CheckAddr(addr.Addr()); err != nil {
+			log.Warn("connection rejected", "err", err)
+			if e, ok := ErrorToMsg(err); ok && e != nil {
+				_ = conn.SendUnreliable(e)
+			}
+			return true
+		}
+		if dup, ok := s.addPending(addr); dup {
+			return true // already connecting
+		} else if !ok {
+			log.Warn("too many pending connections")
+			s.reportFlood(addr, FloodPending)
+			return true
+		}
 		p, err := s.e.Connect(addr)
 		if err != nil {
+			s.removePending(addr, nil)
 			log.Error("cannot connect player", "err", err)
 			return true
 		}
 		sid, err := s.players.mapper.NewPlayer(addr, p, p)
 		if err != nil {
+			s.removePending(addr, nil)
 			// TODO: send error to the client
 			log.Error("cannot create player", "err", err)
 			return true
 		}
+		s.addActive(addr, p, sid)
+		s.startPending(addr, p, sid)
 		var xor byte
 		if !s.players.noXor {
 			xor = byte(rand.UintN(0xff))
 		}
 		conn.QueueReliable(udpconn.Options{
 			Context: ctx,
+			OnDone: func() {
+				// the client enables encryption after acknowledging the key
+				conn.Conn().Encrypt(xor)
+			},
 			OnTimeout: func() {
+				s.takeActive(p)
+				s.removePending(addr, nil)
 				s.players.mapper.DelPlayer(addr, p, sid)
+				s.RevokeSysop(p)
 				p.Disconnect()
 			},
 		},
@@ -241,8 +347,26 @@ func (s *Server) handleConnectMsg(conn udpconn.Stream, m netmsg.Message) bool {
 
 func (s *Server) handlePlayerMsg(conn udpconn.Stream, p Player, m netmsg.Message) bool {
 	switch m := m.(type) {
-	default:
-		s.log.Warn("unhandled player message", "player", p.PlayerID(), "type", reflect.TypeOf(m).String(), "msg", m)
+	case *MsgServerQuitAck:
+		return true
+	case *MsgSysopPass:
+		if s.handleSysopLogin(conn, p, m) {
+			return true
+		}
+	case *MsgServerCmd:
+		if s.handleSysopCmd(conn, p, m) {
+			return true
+		}
+	case TryMsg:
+		if e, ok := s.e.(ActionEngine); ok {
+			return e.PlayerAction(p, m)
+		}
+		s.log.Warn("unhandled player action", "player", p.PlayerID(), "type", reflect.TypeOf(m).String(), "msg", m)
 		return false
 	}
+	if e, ok := s.e.(MessageEngine); ok && e.PlayerMessage(conn, p, m) {
+		return true
+	}
+	s.log.Warn("unhandled player message", "player", p.PlayerID(), "type", reflect.TypeOf(m).String(), "msg", m)
+	return false
 }
@@ -0,0 +1,298 @@
+package noxnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+// minIdleCheck is the min interval for checking idle players.
+const minIdleCheck = 10 * time.Millisecond
+
+var errNotConnected = errors.New("player is not connected")
+
+type activePlayer struct {
+	addr     netip.AddrPort
+	sid      udpconn.SID
+	p        Player
+	lastSeen time.Time
+	joined   bool // received the first message on the player stream
+}
+
+type activeState struct {
+	mu      sync.Mutex
+	closing bool
+	byP     map[Player]*activePlayer
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+// addActive registers a new connection. The player is not listed until touchActive is called.
+func (s *Server) addActive(addr netip.AddrPort, p Player, sid udpconn.SID) {
+	a := &s.active
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.byP == nil {
+		a.byP = make(map[Player]*activePlayer)
+	}
+	a.byP[p] = &activePlayer{addr: addr, sid: sid, p: p, lastSeen: time.Now()}
+}
+
+// touchActive marks the join as completed and updates the last seen time.
+// It returns true if this is the first message on the player stream.
+func (s *Server) touchActive(p Player) bool {
+	a := &s.active
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ap := a.byP[p]
+	if ap == nil {
+		return false
+	}
+	ap.lastSeen = time.Now()
+	first := !ap.joined
+	ap.joined = true
+	return first
+}
+
+// takeActive removes the player from the active list, so that only one caller can disconnect it.
+func (s *Server) takeActive(p Player) *activePlayer {
+	a := &s.active
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ap := a.byP[p]
+	delete(a.byP, p)
+	return ap
+}
+
+// listActive returns active players sorted by the stream ID.
+// If pending is set, players that have not completed the join are included as well.
+func (s *Server) listActive(pending bool) []*activePlayer {
+	a := &s.active
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]*activePlayer, 0, len(a.byP))
+	for _, ap := range a.byP {
+		if ap.joined || pending {
+			list = append(list, ap)
+		}
+	}
+	slices.SortFunc(list, func(a, b *activePlayer) int {
+		return int(a.sid) - int(b.sid)
+	})
+	return list
+}
+
+func (s *Server) resetActive() {
+	a := &s.active
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.byP = nil
+}
+
+func (s *Server) isClosing() bool {
+	a := &s.active
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closing
+}
+
+// Players returns connected players, sorted by the stream ID.
+// Players that have not completed the join are not included.
+func (s *Server) Players() []Player {
+	list := s.listActive(false)
+	out := make([]Player, 0, len(list))
+	for _, ap := range list {
+		out = append(out, ap.p)
+	}
+	return out
+}
+
+// Kick disconnects the player and notifies the client with MsgKickNotification.
+// If the reason is set, it is sent to the player as a text message first.
+// Kick waits until the notification is delivered, the context is canceled, or the packet times out.
+//
+// Kick must not be called from message handlers (Engine methods, console commands executed by sysop),
+// since ACKs are processed by the same goroutine. Call it in a separate goroutine instead.
+func (s *Server) Kick(ctx context.Context, p Player, reason string) error {
+	s.log.Info("kicking player", "player", p.PlayerID(), "name", p.PlayerName(), "reason", reason)
+	return s.dropPlayer(ctx, p, reason, &MsgKickNotification{})
+}
+
+func (s *Server) dropPlayer(ctx context.Context, p Player, reason string, note netmsg.Message) error {
+	ap := s.takeActive(p)
+	if ap == nil {
+		return errNotConnected
+	}
+	var msgs []netmsg.Message
+	if reason != "" {
+		msgs = append(msgs, noticeText(reason))
+	}
+	msgs = append(msgs, note)
+	done := s.notifyPlayer(ctx, ap, msgs...)
+	err := waitNotified(ctx, done)
+	s.releasePlayer(ap)
+	return err
+}
+
+// notifyPlayer sends reliable messages to the player. The returned channel is closed
+// when the packet is acknowledged or times out.
+func (s *Server) notifyPlayer(ctx context.Context, ap *activePlayer, msgs ...netmsg.Message) <-chan struct{} {
+	done := make(chan struct{})
+	var once sync.Once
+	finish := func() {
+		once.Do(func() { close(done) })
+	}
+	conn := s.Port.Conn(ap.addr)
+	conn.Stream(udpconn.ServerStreamID).QueueReliable(udpconn.Options{
+		Context:   ctx,
+		OnDone:    finish,
+		OnTimeout: finish,
+	}, msgs...)
+	_ = conn.SendQueue()
+	return done
+}
+
+// waitNotified waits until all notifications are delivered or timed out, or the context is canceled.
+func waitNotified(ctx context.Context, list ...<-chan struct{}) error {
+	for _, done := range list {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+		}
+	}
+	return nil
+}
+
+// releasePlayer frees the player slot. The player must be removed from the active list first.
+func (s *Server) releasePlayer(ap *activePlayer) {
+	s.removePending(ap.addr, nil)
+	s.players.mapper.DelPlayer(ap.addr, ap.p, ap.sid)
+	s.RevokeSysop(ap.p)
+	ap.p.Disconnect()
+	s.Port.Conn(ap.addr).Reset()
+}
+
+func noticeText(text string) *MsgText {
+	m := &MsgText{Flags: TextNotice}
+	m.SetText(text)
+	return m
+}
+
+// Broadcast sends a notice text message to all connected players.
+func (s *Server) Broadcast(text string) {
+	for _, ap := range s.listActive(false) {
+		conn := s.Port.Conn(ap.addr)
+		conn.Stream(udpconn.ServerStreamID).QueueReliable(udpconn.Options{}, noticeText(text))
+		_ = conn.SendQueue()
+	}
+}
+
+func (s *Server) startIdleCheck(timeout time.Duration) {
+	s.active.stop = make(chan struct{})
+	go s.idleLoop(timeout, s.active.stop)
+}
+
+func (s *Server) stopIdleCheck() {
+	s.active.stopped.Do(func() {
+		if s.active.stop != nil {
+			close(s.active.stop)
+		}
+	})
+}
+
+func (s *Server) idleLoop(timeout time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(max(timeout/4, minIdleCheck))
+	defer t.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-stop:
+			return
+		case now = <-t.C:
+		}
+		// pending joins are dropped by the half-open timeout instead
+		for _, ap := range s.listActive(false) {
+			s.active.mu.Lock()
+			idle := now.Sub(ap.lastSeen)
+			s.active.mu.Unlock()
+			if idle < timeout {
+				continue
+			}
+			s.log.Info("player timed out", "player", ap.p.PlayerID(), "name", ap.p.PlayerName(), "idle", idle)
+			go func(p Player) {
+				_ = s.dropPlayer(context.Background(), p, "", &MsgTimeoutNotification{})
+			}(ap.p)
+		}
+	}
+}
+
+// Shutdown gracefully stops the server.
+//
+// New connections are rejected right away. If countdown is set, players are notified with text messages
+// and the server waits for the countdown to finish. After that, all players receive MsgServerQuit,
+// and the server waits until it is delivered. The context sets a deadline for the whole process.
+// Player slots are released in order of stream IDs, and the server is closed, even if the context is canceled.
+func (s *Server) Shutdown(ctx context.Context, countdown time.Duration) error {
+	s.active.mu.Lock()
+	s.active.closing = true
+	s.active.mu.Unlock()
+
+	err := s.countdown(ctx, countdown)
+
+	var (
+		list []*activePlayer
+		sent []<-chan struct{}
+	)
+	for _, ap := range s.listActive(true) {
+		if s.takeActive(ap.p) == nil {
+			continue // already disconnecting
+		}
+		list = append(list, ap)
+		sent = append(sent, s.notifyPlayer(ctx, ap, &MsgServerQuit{}))
+	}
+	if err2 := waitNotified(ctx, sent...); err == nil {
+		err = err2
+	}
+	for _, ap := range list {
+		s.releasePlayer(ap)
+	}
+	s.Close()
+	return err
+}
+
+func (s *Server) countdown(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	end := time.Now().Add(d)
+	first := true
+	for {
+		left := time.Until(end)
+		if left <= 0 {
+			return nil
+		}
+		sec := int((left + time.Second - 1) / time.Second)
+		if first || sec%10 == 0 || sec <= 5 {
+			in := time.Duration(sec) * time.Second
+			s.log.Info("server shutting down", "in", in)
+			s.Broadcast(fmt.Sprintf("Server is shutting down in %v", in))
+		}
+		first = false
+		// wake up on the next whole second before the end
+		t := time.NewTimer(left - time.Duration(sec-1)*time.Second)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package noxnet
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/opennox/libs/console"
+	"github.com/opennox/libs/noxnet/udpconn"
+)
+
+const (
+	// maxSysopLine is the max length of a single MsgText line, in UTF-16 units.
+	maxSysopLine = 0xff - 1
+	// defaultSysopAttempts is the default number of failed sysop logins allowed for a player.
+	defaultSysopAttempts = 3
+)
+
+// SysopOptions configures remote sysop access.
+//
+// Players log in with MsgSysopPass and get MsgSysopResult in response. After that, commands they send with MsgServerCmd
+// are executed in the console as server-side commands, and the output is sent back as MsgText notices.
+type SysopOptions struct {
+	// Password for the sysop access. Empty password disables remote sysop.
+	Password string
+	// MaxAttempts is the number of failed logins after which the player is denied without checking the password,
+	// until it reconnects. FloodEngine is notified with FloodSysop and may block the IP. Default is 3.
+	MaxAttempts int
+	// Console used to execute commands. Commands are executed from the network goroutine,
+	// so the console must not be used concurrently, except by other sysop commands.
+	Console *console.Console
+}
+
+type sysopState struct {
+	opts SysopOptions
+
+	mu      sync.Mutex
+	players map[Player]struct{}
+	failed  map[Player]int
+
+	// execMu serializes command execution. It is separate from mu,
+	// so that commands can call IsSysop, RevokeSysop or Kick.
+	execMu sync.Mutex
+}
+
+func (o SysopOptions) withDefaults() SysopOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaultSysopAttempts
+	}
+	return o
+}
+
+func (s *sysopState) enabled() bool {
+	return s.opts.Password != "" && s.opts.Console != nil
+}
+
+func (s *sysopState) isSysop(p Player) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.players[p]
+	return ok
+}
+
+// IsSysop checks if the player has logged in as a remote sysop.
+func (s *Server) IsSysop(p Player) bool {
+	return s.sysop.isSysop(p)
+}
+
+// RevokeSysop removes sysop access from the player and forgets failed logins. It must be called when the player leaves.
+func (s *Server) RevokeSysop(p Player) {
+	s.sysop.mu.Lock()
+	delete(s.sysop.players, p)
+	delete(s.sysop.failed, p)
+	s.sysop.mu.Unlock()
+}
+
+func (s *Server) resetSysop() {
+	s.sysop.mu.Lock()
+	s.sysop.players = nil
+	s.sysop.failed = nil
+	s.sysop.mu.Unlock()
+}
+
+// login checks the password and grants the access. It returns true if the player must be reported as flooding.
+func (s *sysopState) login(p Player, pass string, blocked bool) (res SysopResult, flood bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if blocked || s.failed[p] >= s.opts.MaxAttempts {
+		return SysopDenied, false
+	}
+	if subtle.ConstantTimeCompare([]byte(pass), []byte(s.opts.Password)) != 1 {
+		if s.failed == nil {
+			s.failed = make(map[Player]int)
+		}
+		s.failed[p]++
+		return SysopDenied, s.failed[p] >= s.opts.MaxAttempts
+	}
+	if s.players == nil {
+		s.players = make(map[Player]struct{})
+	}
+	s.players[p] = struct{}{}
+	delete(s.failed, p)
+	return SysopGranted, false
+}
+
+func (s *Server) handleSysopLogin(conn udpconn.Stream, p Player, m *MsgSysopPass) bool {
+	if !s.sysop.enabled() {
+		return false
+	}
+	log := s.log.With("player", p.PlayerID(), "name", p.PlayerName())
+	addr := conn.Conn().RemoteAddr()
+	res, flood := s.sysop.login(p, m.Pass, s.isBlocked(addr.Addr()))
+	if res == SysopGranted {
+		log.Info("sysop access granted")
+	} else {
+		log.Warn("sysop access denied")
+	}
+	conn.QueueReliable(udpconn.Options{}, &MsgSysopResult{Result: res})
+	_ = conn.SendQueue()
+	if flood {
+		s.reportFlood(addr, FloodSysop)
+	}
+	return true
+}
+
+func (s *Server) handleSysopCmd(conn udpconn.Stream, p Player, m *MsgServerCmd) bool {
+	if !s.sysop.enabled() || !s.sysop.isSysop(p) {
+		return false
+	}
+	s.log.Info("sysop command", "player", p.PlayerID(), "name", p.PlayerName(), "cmd", m.Cmd)
+	out := &sysopPrinter{}
+	cn := s.sysop.opts.Console
+	ctx := console.AsServer(context.Background())
+
+	s.sysop.execMu.Lock()
+	prev := cn.SetPrinter(out)
+	if prev != nil {
+		// still show the output to the server operator
+		cn.SetPrinter(console.NewMultiPrinter(prev, out))
+	}
+	// console prints a hint for unknown commands itself
+	_ = cn.Exec(ctx, m.Cmd)
+	cn.SetPrinter(prev)
+	s.sysop.execMu.Unlock()
+
+	for _, line := range out.lines {
+		conn.QueueReliable(udpconn.Options{}, noticeText(line))
+	}
+	_ = conn.SendQueue()
+	return true
+}
+
+// sysopPrinter collects console output as lines that fit into MsgText.
+type sysopPrinter struct {
+	lines []string
+}
+
+func (p *sysopPrinter) Print(cl console.Color, str string) {
+	for _, line := range strings.Split(strings.TrimRight(str, "\n"), "\n") {
+		for {
+			text := utf16.Encode([]rune(line))
+			if len(text) <= maxSysopLine {
+				break
+			}
+			n := maxSysopLine
+			if text[n-1] >= 0xd800 && text[n-1] < 0xdc00 {
+				n-- // do not split surrogate pairs
+			}
+			p.lines = append(p.lines, string(utf16.Decode(text[:n])))
+			line = string(utf16.Decode(text[n:]))
+		}
+		p.lines = append(p.lines, line)
+	}
+}
+
+func (p *sysopPrinter) Printf(cl console.Color, format string, args ...interface{}) {
+	p.Print(cl, fmt.Sprintf(format, args...))
+}
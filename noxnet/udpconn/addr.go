@@ -0,0 +1,87 @@
+package udpconn
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+var (
+	// multicastIP6 is a link-local all-nodes multicast group used for discovery over IPv6.
+	// It mirrors IPv4 broadcast and doesn't require joining the group.
+	multicastIP6 = netip.IPv6LinkLocalAllNodes()
+)
+
+// NormalizeAddr converts IPv4-mapped IPv6 address to IPv4 address.
+//
+// Dual-stack sockets report IPv4 peers as IPv4-mapped addresses (::ffff:a.b.c.d),
+// while the same peer may be referred to by a plain IPv4 address elsewhere.
+// All connections on the Port are keyed by normalized addresses.
+func NormalizeAddr(addr netip.AddrPort) netip.AddrPort {
+	if ip := addr.Addr(); ip.Is4In6() {
+		return netip.AddrPortFrom(ip.Unmap(), addr.Port())
+	}
+	return addr
+}
+
+// Listen opens a UDP socket for the Port. If the address is unspecified (or zero), the socket is dual-stack,
+// if the platform allows it. Otherwise, the socket will only use the address family of addr.
+func Listen(addr netip.AddrPort) (*net.UDPConn, error) {
+	network := "udp"
+	ip := addr.Addr()
+	if ip.IsValid() && !ip.IsUnspecified() {
+		if ip.Unmap().Is4() {
+			network = "udp4"
+		} else {
+			network = "udp6"
+		}
+	} else if ip.Is4() {
+		network = "udp4"
+	}
+	return net.ListenUDP(network, net.UDPAddrFromAddrPort(addr))
+}
+
+// discoveryAddrs returns broadcast or multicast addresses for the discovery, depending on the socket address family.
+func (p *Port) discoveryAddrs(port uint16) []netip.AddrPort {
+	local := p.LocalAddr().Addr()
+	switch {
+	case local.Is4():
+		return []netip.AddrPort{netip.AddrPortFrom(broadcastIP4, port)}
+	case local.IsUnspecified():
+		// dual-stack
+		return []netip.AddrPort{
+			netip.AddrPortFrom(broadcastIP4, port),
+			netip.AddrPortFrom(multicastIP6, port),
+		}
+	default:
+		return []netip.AddrPort{netip.AddrPortFrom(multicastIP6, port)}
+	}
+}
+
+// BroadcastMsg sends an unreliable message to all hosts on the local network.
+//
+// IPv4 sockets use broadcast, IPv6 sockets use link-local all-nodes multicast, and dual-stack sockets use both.
+// An error is returned only if all the attempts failed.
+func (p *Port) BroadcastMsg(port int, m netmsg.Message) error {
+	if port <= 0 {
+		port = DefaultPort
+	}
+	var errs []error
+	addrs := p.discoveryAddrs(uint16(port))
+	for _, addr := range addrs {
+		if err := p.WriteMsg(addr, 0, Header{Flags: Unreliable}, nil, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == len(addrs) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// isDiscoveryAddr checks if the address is used for broadcast or multicast discovery.
+func isDiscoveryAddr(addr netip.Addr) bool {
+	return addr == broadcastIP4 || addr.WithZone("") == multicastIP6
+}
@@ -0,0 +1,95 @@
+package udpconn
+
+import "time"
+
+const (
+	defaultMinRTO  = 100 * time.Millisecond
+	defaultBackoff = 2.0
+)
+
+// Config controls delivery of reliable packets.
+//
+// Zero value corresponds to the vanilla behavior: packets are resent every second
+// and dropped if there's no ACK after 5 retries.
+type Config struct {
+	// Adaptive enables retransmission timeout (RTO) derived from measured RTT.
+	// Until the first RTT sample is available, MaxRTO is used.
+	Adaptive bool
+	// MinRTO is the lower bound for adaptive RTO. Default is 100ms.
+	MinRTO time.Duration
+	// MaxRTO is the upper bound for RTO. If Adaptive is not set, it is used as a fixed resend interval.
+	// Default is 1s.
+	MaxRTO time.Duration
+	// Backoff multiplies RTO for each consecutive resend of the same packet (only when Adaptive is set).
+	// Default is 2.
+	Backoff float64
+	// Timeout is the default time to wait for an ACK before giving up on a packet.
+	// Default is a bit more than 5 resends with MaxRTO.
+	Timeout time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxRTO <= 0 {
+		c.MaxRTO = resendInterval
+	}
+	if c.MinRTO <= 0 {
+		c.MinRTO = min(defaultMinRTO, c.MaxRTO)
+	}
+	if c.MinRTO > c.MaxRTO {
+		c.MinRTO = c.MaxRTO
+	}
+	if c.Backoff < 1 {
+		c.Backoff = defaultBackoff
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = c.MaxRTO*resendRetries + resendTick
+	}
+	return c
+}
+
+// SetConfig changes reliable delivery settings for all current and future connections on the port.
+func (p *Port) SetConfig(cfg Config) {
+	cfg = cfg.withDefaults()
+	p.hmu.Lock()
+	defer p.hmu.Unlock()
+	p.cfg = cfg
+	for _, h := range p.byAddr {
+		h.setConfig(cfg)
+	}
+}
+
+// SetConfig changes reliable delivery settings for this connection.
+func (p *Conn) SetConfig(cfg Config) {
+	p.setConfig(cfg.withDefaults())
+}
+
+func (p *Conn) setConfig(cfg Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cfg = cfg
+}
+
+// Config returns reliable delivery settings for this connection.
+func (p *Conn) Config() Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cfg
+}
+
+// rto returns current retransmission timeout. Must be called with the lock held.
+func (p *Conn) rto() time.Duration {
+	if !p.cfg.Adaptive || p.stats.RTT == 0 {
+		return p.cfg.MaxRTO
+	}
+	rto := p.stats.RTT + 4*p.stats.RTTVar
+	return min(max(rto, p.cfg.MinRTO), p.cfg.MaxRTO)
+}
+
+// backoff returns the next retransmission timeout for a packet. Must be called with the lock held.
+func (p *Conn) backoff(rto time.Duration) time.Duration {
+	if !p.cfg.Adaptive {
+		return p.cfg.MaxRTO
+	}
+	rto = time.Duration(float64(rto) * p.cfg.Backoff)
+	return min(rto, p.cfg.MaxRTO)
+}
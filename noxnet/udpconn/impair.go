@@ -0,0 +1,247 @@
+package udpconn
+
+import (
+	"container/heap"
+	"encoding/hex"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// reorderDelay is a minimal extra delay for packets selected for reordering.
+const reorderDelay = 5 * time.Millisecond
+
+// Impairment describes network conditions simulated by PipeConn.
+//
+// All random decisions are made by RNG seeded with Seed, so the same sequence of writes
+// produces the same sequence of drops, duplicates and delays.
+type Impairment struct {
+	// Seed for the random number generator.
+	Seed uint64
+	// Latency is a base one-way delay for each packet.
+	Latency time.Duration
+	// Jitter is a maximal random delay added to Latency.
+	Jitter time.Duration
+	// Loss is a probability of dropping a packet, in [0, 1].
+	Loss float64
+	// Duplicate is a probability of delivering a packet twice, in [0, 1].
+	Duplicate float64
+	// Reorder is a probability of delaying a packet past the ones sent after it, in [0, 1].
+	Reorder float64
+	// Bandwidth limits the link throughput in bytes per second. Zero means unlimited.
+	Bandwidth int
+}
+
+// IsZero checks if impairment describes a perfect link.
+func (imp Impairment) IsZero() bool {
+	return imp.Latency <= 0 && imp.Jitter <= 0 && imp.Loss <= 0 &&
+		imp.Duplicate <= 0 && imp.Reorder <= 0 && imp.Bandwidth <= 0
+}
+
+// Impair sets network conditions for packets sent from this end of the pipe.
+// Calling it with zero Impairment restores a perfect link.
+//
+// Packets already in flight are delivered with previous settings.
+func (c *PipeConn) Impair(imp Impairment) {
+	c.lmu.Lock()
+	defer c.lmu.Unlock()
+	if c.link != nil {
+		c.link.stop()
+		c.link = nil
+	}
+	if imp.IsZero() {
+		return
+	}
+	l := &pipeLink{
+		c:    c,
+		imp:  imp,
+		rng:  rand.New(rand.NewPCG(imp.Seed, imp.Seed)),
+		wake: make(chan struct{}, 1),
+	}
+	c.link = l
+	go l.run()
+}
+
+func (c *PipeConn) getLink() *pipeLink {
+	c.lmu.Lock()
+	defer c.lmu.Unlock()
+	return c.link
+}
+
+type pipePacket struct {
+	at   time.Time
+	seq  uint64
+	data []byte
+}
+
+type pipeQueue []pipePacket
+
+func (q pipeQueue) Len() int {
+	return len(q)
+}
+
+func (q pipeQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q pipeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *pipeQueue) Push(x any) {
+	*q = append(*q, x.(pipePacket))
+}
+
+func (q *pipeQueue) Pop() any {
+	old := *q
+	n := len(old)
+	p := old[n-1]
+	*q = old[:n-1]
+	return p
+}
+
+// pipeLink delivers packets to the peer according to Impairment.
+type pipeLink struct {
+	c   *PipeConn
+	imp Impairment
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	seq     uint64
+	busy    time.Time
+	queue   pipeQueue
+	wake    chan struct{}
+	stopped bool // no new packets are expected
+	exited  bool // the delivery goroutine is stopped
+}
+
+// stop the link after delivering packets that are already in flight.
+func (l *pipeLink) stop() {
+	l.mu.Lock()
+	l.stopped = true
+	l.mu.Unlock()
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// tryExit stops the link if it was replaced and all packets are delivered.
+func (l *pipeLink) tryExit() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.stopped || len(l.queue) != 0 {
+		return false
+	}
+	l.exited = true
+	return true
+}
+
+func (l *pipeLink) chance(p float64) bool {
+	return p > 0 && l.rng.Float64() < p
+}
+
+// send schedules the packet for delivery. It returns false if the link is already stopped.
+func (l *pipeLink) send(data []byte) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.exited {
+		return false
+	}
+	if l.chance(l.imp.Loss) {
+		if c := l.c; c.Log != nil && c.Debug {
+			c.Log.Info("LOSS", "srv", c.Addr, "data", hex.EncodeToString(data))
+		}
+		return true
+	}
+	copies := 1
+	if l.chance(l.imp.Duplicate) {
+		copies = 2
+	}
+	now := time.Now()
+	at := now
+	if bw := l.imp.Bandwidth; bw > 0 {
+		if l.busy.After(now) {
+			at = l.busy
+		}
+		at = at.Add(time.Duration(len(data)) * time.Second / time.Duration(bw))
+		l.busy = at
+	}
+	at = at.Add(l.imp.Latency)
+	if l.imp.Jitter > 0 {
+		at = at.Add(time.Duration(l.rng.Int64N(int64(l.imp.Jitter))))
+	}
+	if l.chance(l.imp.Reorder) {
+		at = at.Add(max(l.imp.Latency+l.imp.Jitter, reorderDelay))
+	}
+	for range copies {
+		l.seq++
+		heap.Push(&l.queue, pipePacket{at: at, seq: l.seq, data: data})
+	}
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// next returns the next packet ready for delivery, or the time when it will be ready.
+func (l *pipeLink) next(now time.Time) ([]byte, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.queue) == 0 {
+		return nil, time.Time{}, false
+	}
+	if p := l.queue[0]; p.at.After(now) {
+		return nil, p.at, false
+	}
+	p := heap.Pop(&l.queue).(pipePacket)
+	return p.data, time.Time{}, true
+}
+
+func (l *pipeLink) run() {
+	c := l.c
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		data, at, ok := l.next(time.Now())
+		if ok {
+			select {
+			case c.peer.recv <- data:
+				if c.Log != nil && c.Debug {
+					c.Log.Info("SEND", "srv", c.Addr, "dst", c.peer.Addr, "data", hex.EncodeToString(data))
+				}
+			case <-c.closed:
+				return
+			case <-c.peer.closed:
+				return
+			}
+			continue
+		}
+		if at.IsZero() && l.tryExit() {
+			return
+		}
+		var wait <-chan time.Time
+		if !at.IsZero() {
+			timer.Reset(time.Until(at))
+			wait = timer.C
+		}
+		select {
+		case <-c.closed:
+			return
+		case <-c.peer.closed:
+			return
+		case <-l.wake:
+		case <-wait:
+		}
+		if wait != nil && !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
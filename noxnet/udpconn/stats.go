@@ -0,0 +1,138 @@
+package udpconn
+
+import (
+	"net/netip"
+	"time"
+)
+
+// Stats contains transport statistics for a connection.
+type Stats struct {
+	// RTT is a smoothed round-trip time estimate, measured from reliable packets and their ACKs.
+	// It includes the ACK delay of the peer and is zero until the first sample.
+	RTT time.Duration
+	// RTTVar is a mean deviation of RTT samples.
+	RTTVar time.Duration
+	// RTO is the current retransmission timeout for new packets.
+	RTO time.Duration
+
+	PacketsIn  uint64
+	PacketsOut uint64
+	BytesIn    uint64
+	BytesOut   uint64
+
+	// Resends is the number of reliable packets sent more than once.
+	Resends uint64
+	// Timeouts is the number of reliable packets removed from the queue without an ACK.
+	Timeouts uint64
+	// Dropped is the number of reliable packets ignored because they arrived out of order.
+	Dropped uint64
+	// Duplicates is the number of reliable packets that were already received before.
+	// These are also counted in Dropped.
+	Duplicates uint64
+	// Errors is the number of messages that failed to decode.
+	Errors uint64
+
+	// Queued is the number of reliable packets waiting for an ACK, per stream.
+	Queued map[SID]int
+	// LastAck is the time when the last ACK was received from the peer.
+	LastAck time.Time
+}
+
+// SinceLastAck returns time passed since the last ACK from the peer.
+// It returns zero if no ACK was received yet.
+func (s *Stats) SinceLastAck() time.Duration {
+	if s.LastAck.IsZero() {
+		return 0
+	}
+	return time.Since(s.LastAck)
+}
+
+// QueuedTotal returns the number of reliable packets waiting for an ACK in all streams.
+func (s *Stats) QueuedTotal() int {
+	n := 0
+	for _, v := range s.Queued {
+		n += v
+	}
+	return n
+}
+
+// add counters from another stats instance.
+func (s *Stats) add(s2 *Stats) {
+	s.RTT = max(s.RTT, s2.RTT)
+	s.RTTVar = max(s.RTTVar, s2.RTTVar)
+	s.RTO = max(s.RTO, s2.RTO)
+	s.PacketsIn += s2.PacketsIn
+	s.PacketsOut += s2.PacketsOut
+	s.BytesIn += s2.BytesIn
+	s.BytesOut += s2.BytesOut
+	s.Resends += s2.Resends
+	s.Timeouts += s2.Timeouts
+	s.Dropped += s2.Dropped
+	s.Duplicates += s2.Duplicates
+	s.Errors += s2.Errors
+	if s2.LastAck.After(s.LastAck) {
+		s.LastAck = s2.LastAck
+	}
+	for sid, n := range s2.Queued {
+		if s.Queued == nil {
+			s.Queued = make(map[SID]int)
+		}
+		s.Queued[sid] += n
+	}
+}
+
+// addRTT updates RTT estimate with a new sample.
+func (s *Stats) addRTT(rtt time.Duration) {
+	if s.RTT == 0 {
+		s.RTT = rtt
+		s.RTTVar = rtt / 2
+		return
+	}
+	d := s.RTT - rtt
+	if d < 0 {
+		d = -d
+	}
+	s.RTTVar = (3*s.RTTVar + d) / 4
+	s.RTT = (7*s.RTT + rtt) / 8
+}
+
+// Stats returns transport statistics for the connection.
+func (p *Conn) Stats() Stats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	s := p.stats
+	s.RTO = p.rto()
+	s.Queued = make(map[SID]int)
+	for _, m := range p.queue {
+		s.Queued[m.hdr.SID]++
+	}
+	return s
+}
+
+// PortStats contains transport statistics for all connections on the port.
+type PortStats struct {
+	// Total contains the sum of all connection counters.
+	// RTT and RTO are the largest ones among all connections, LastAck is the latest one.
+	Total Stats
+	Conns map[netip.AddrPort]Stats
+}
+
+// Stats returns transport statistics for all connections on the port.
+func (p *Port) Stats() PortStats {
+	p.hmu.RLock()
+	conns := make([]*Conn, 0, len(p.byAddr))
+	for _, h := range p.byAddr {
+		conns = append(conns, h)
+	}
+	p.hmu.RUnlock()
+	ps := PortStats{
+		Conns: make(map[netip.AddrPort]Stats, len(conns)),
+	}
+	ps.Total.Queued = make(map[SID]int)
+	for _, h := range conns {
+		s := h.Stats()
+		ps.Conns[h.addr] = s
+		ps.Total.add(&s)
+	}
+	return ps
+}
@@ -31,7 +31,6 @@ const (
 	resendTick     = 20 * time.Millisecond
 	resendInterval = time.Second
 	resendRetries  = 5
-	defaultTimeout = resendInterval*resendRetries + resendTick
 	maxAckDelay    = 100 * time.Millisecond
 	maxAckMsgs     = 50
 )
@@ -125,6 +124,7 @@ func NewPort(log *slog.Logger, conn PacketConn, opts netmsg.Options) *Port {
 		conn:   conn,
 		debug:  log.Enabled(context.Background(), slog.LevelDebug),
 		byAddr: make(map[netip.AddrPort]*Conn),
+		cfg:    Config{}.withDefaults(),
 		closed: make(chan struct{}),
 	}
 }
@@ -142,6 +142,7 @@ type Port struct {
 
 	hmu    sync.RWMutex
 	byAddr map[netip.AddrPort]*Conn
+	cfg    Config
 
 	closed chan struct{}
 	debug  bool
@@ -158,8 +159,7 @@ func (p *Port) Close() {
 
 func (p *Port) LocalAddr() netip.AddrPort {
 	addr := p.conn.LocalAddr().(*net.UDPAddr)
-	ip, _ := netip.AddrFromSlice(addr.IP)
-	return netip.AddrPortFrom(ip, uint16(addr.Port))
+	return NormalizeAddr(addr.AddrPort())
 }
 
 func (p *Port) OnMessage(fnc OnMessageFunc) {
@@ -176,12 +176,14 @@ func (p *Port) Reset() {
 }
 
 func (p *Port) getConn(addr netip.AddrPort) *Conn {
+	addr = NormalizeAddr(addr)
 	p.hmu.RLock()
 	defer p.hmu.RUnlock()
 	return p.byAddr[addr]
 }
 
 func (p *Port) Conn(addr netip.AddrPort) *Conn {
+	addr = NormalizeAddr(addr)
 	p.hmu.RLock()
 	h := p.byAddr[addr]
 	p.hmu.RUnlock()
@@ -198,6 +200,7 @@ func (p *Port) Conn(addr netip.AddrPort) *Conn {
 		p:    p,
 		addr: addr,
 		log:  p.log.With("remote", addr),
+		cfg:  p.cfg,
 	}
 	h.enc.Options = p.opts
 	if p.OnConn != nil && !p.OnConn(h) {
@@ -208,6 +211,11 @@ func (p *Port) Conn(addr netip.AddrPort) *Conn {
 }
 
 func (p *Port) WriteMsg(addr netip.AddrPort, xor byte, hdr Header, enc *netmsg.State, msgs ...netmsg.Message) error {
+	_, err := p.writeMsg(addr, xor, hdr, enc, msgs...)
+	return err
+}
+
+func (p *Port) writeMsg(addr netip.AddrPort, xor byte, hdr Header, enc *netmsg.State, msgs ...netmsg.Message) (int, error) {
 	h := hdr.Encode()
 	p.wmu.Lock()
 	defer p.wmu.Unlock()
@@ -217,22 +225,13 @@ func (p *Port) WriteMsg(addr netip.AddrPort, xor byte, hdr Header, enc *netmsg.S
 	for _, m := range msgs {
 		p.wbuf, err = enc.Append(p.wbuf, m)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 	if xor != 0 {
 		xorBuf(xor, p.wbuf)
 	}
-	_, err = p.conn.WriteToUDPAddrPort(p.wbuf, addr)
-	return err
-}
-
-func (p *Port) BroadcastMsg(port int, m netmsg.Message) error {
-	if port <= 0 {
-		port = DefaultPort
-	}
-	addr := netip.AddrPortFrom(broadcastIP4, uint16(port))
-	return p.WriteMsg(addr, 0, Header{Flags: Unreliable}, nil, m)
+	return p.conn.WriteToUDPAddrPort(p.wbuf, addr)
 }
 
 func (p *Port) Start() {
@@ -301,14 +300,16 @@ func (v Seq) Before(v2 Seq) bool {
 type PID uintptr
 
 type packet struct {
-	pid      PID
-	hdr      Header
-	xor      byte
-	lastSend time.Time
-	deadline time.Time
-	msgs     []netmsg.Message
-	done     func()
-	timeout  func()
+	pid       PID
+	hdr       Header
+	xor       byte
+	firstSend time.Time
+	lastSend  time.Time
+	rto       time.Duration
+	deadline  time.Time
+	msgs      []netmsg.Message
+	done      func()
+	timeout   func()
 }
 
 func (p *packet) QueueID() QueueID {
@@ -332,6 +333,8 @@ type Conn struct {
 	needAck  int
 	nextPing time.Time
 	queue    []*packet
+	stats    Stats
+	cfg      Config
 
 	onMessage onMessageFuncs
 }
@@ -372,6 +375,8 @@ func (p *Conn) OnMessage(fnc OnMessageFunc) {
 func (p *Conn) handlePacket(data []byte) {
 	var doneFuncs []func()
 	p.mu.Lock()
+	p.stats.PacketsIn++
+	p.stats.BytesIn += uint64(len(data))
 	if xor := p.xor; xor != 0 {
 		xorBuf(xor, data)
 	}
@@ -391,6 +396,10 @@ func (p *Conn) handlePacket(data []byte) {
 		// New reliable message that we should ACK in the future.
 		exp := p.ack
 		if h.Seq != exp {
+			p.stats.Dropped++
+			if h.Seq.Before(exp) {
+				p.stats.Duplicates++
+			}
 			p.mu.Unlock()
 			return // Ignore out of order packets.
 		}
@@ -403,8 +412,14 @@ func (p *Conn) handlePacket(data []byte) {
 		}
 	} else {
 		// Unreliable message with ACK for our reliable messages.
+		now := time.Now()
+		p.stats.LastAck = now
 		p.queue = slices.DeleteFunc(p.queue, func(m *packet) bool {
 			del := m.hdr.Seq.Before(h.Seq)
+			if del && !m.firstSend.IsZero() && m.firstSend.Equal(m.lastSend) {
+				// Only use packets sent once, since we cannot tell which copy was acknowledged otherwise.
+				p.stats.addRTT(now.Sub(m.firstSend))
+			}
 			if del && m.done != nil {
 				doneFuncs = append(doneFuncs, m.done)
 			}
@@ -428,6 +443,9 @@ func (p *Conn) handlePacket(data []byte) {
 		if err != nil {
 			op := data[0]
 			p.log.Error("Failed to decode packet", "op", op, "err", err)
+			p.mu.Lock()
+			p.stats.Errors++
+			p.mu.Unlock()
 			break
 		}
 		data = data[n:]
@@ -473,8 +491,8 @@ func (p *Conn) QueuedFor(sid SID, ops ...netmsg.Op) int {
 }
 
 func (p *Conn) DeleteQueue(fnc func(id QueueID, msgs []netmsg.Message) bool) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.queue = slices.DeleteFunc(p.queue, func(m *packet) bool {
 		return fnc(m.QueueID(), m.msgs)
 	})
@@ -503,7 +521,18 @@ func (p *Conn) sendUnreliable(sid SID, msgs ...netmsg.Message) error {
 		p.log.Debug("SEND", "ack", seq, "sid", sid, "type", typ, "msgs", msgs)
 	}
 	h := Header{SID: sid, Seq: seq, Flags: Unreliable}
-	return p.p.WriteMsg(p.addr, p.xor, h, &p.enc, msgs...)
+	return p.writeMsg(p.xor, h, msgs...)
+}
+
+// writeMsg sends a packet to the peer and updates stats. Must be called with the lock held.
+func (p *Conn) writeMsg(xor byte, hdr Header, msgs ...netmsg.Message) error {
+	n, err := p.p.writeMsg(p.addr, xor, hdr, &p.enc, msgs...)
+	if err != nil {
+		return err
+	}
+	p.stats.PacketsOut++
+	p.stats.BytesOut += uint64(n)
+	return nil
 }
 
 func (p *Conn) sendAckPing() error {
@@ -535,11 +564,11 @@ func (p *Conn) QueueReliable(sid SID, opts Options, msgs ...netmsg.Message) PID
 	if opts.Context != nil {
 		deadline, _ = opts.Context.Deadline()
 	}
-	if deadline.IsZero() {
-		deadline = now.Add(defaultTimeout)
-	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if deadline.IsZero() {
+		deadline = now.Add(p.cfg.Timeout)
+	}
 	seq := p.syn
 	p.syn++
 	pid := PID(p.packetID.Add(1))
@@ -562,14 +591,14 @@ func (p *Conn) QueueReliable(sid SID, opts Options, msgs ...netmsg.Message) PID
 
 func (p *Conn) CancelReliable(pid PID) {
 	p.DeleteQueue(func(id QueueID, _ []netmsg.Message) bool {
-		return pid != id.Packet
+		return pid == id.Packet
 	})
 }
 
 func (p *Conn) SendReliable(ctx context.Context, sid SID, msgs ...netmsg.Message) error {
 	var cancel func()
 	if _, ok := ctx.Deadline(); !ok {
-		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		ctx, cancel = context.WithTimeout(ctx, p.Config().Timeout)
 	} else {
 		ctx, cancel = context.WithCancel(ctx)
 	}
@@ -607,6 +636,9 @@ func (p *Conn) sendQueue(filter func(p *packet) bool) error {
 			return false // keep
 		}
 		del := m.deadline.Before(now)
+		if del {
+			p.stats.Timeouts++
+		}
 		if del && m.timeout != nil {
 			doneFuncs = append(doneFuncs, m.timeout)
 		}
@@ -617,12 +649,19 @@ func (p *Conn) sendQueue(filter func(p *packet) bool) error {
 		if filter != nil && !filter(m) {
 			continue
 		}
-		if m.lastSend.Add(resendInterval).Before(now) {
+		if m.lastSend.Add(m.rto).Before(now) {
+			if m.firstSend.IsZero() {
+				m.firstSend = now
+				m.rto = p.rto()
+			} else {
+				p.stats.Resends++
+				m.rto = p.backoff(m.rto)
+			}
 			m.lastSend = now
 			if p.p.debug {
 				p.log.Debug("SEND", "syn", m.hdr.Seq, "sid", m.hdr.SID, "msgs", m.msgs)
 			}
-			if err := p.p.WriteMsg(p.addr, m.xor, m.hdr, &p.enc, m.msgs...); err != nil {
+			if err := p.writeMsg(m.xor, m.hdr, m.msgs...); err != nil {
 				lastErr = err
 			}
 		}
@@ -3,6 +3,9 @@ package udpconn
 import (
 	"context"
 	"log/slog"
+	"net/netip"
+	"slices"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -32,16 +35,21 @@ func TestSeqBefore(t *testing.T) {
 	}
 }
 
+// TestAck checks that ACK numbers are handled the same way they are produced by the receiving side:
+// the ACK carries the next sequence number the peer expects.
 func TestStream(t *testing.T) {
 	const maxMessages = 300
 
-	newTest := func(t testing.TB, fast, debug bool, drop func(b []byte) bool) (*Conn, <-chan netmsg.Message) {
+	newTest := func(t testing.TB, fast, debug bool, drop func(b []byte) bool, imp Impairment) (*Conn, <-chan netmsg.Message) {
 		srvC, cliC := NewPipe(slog.Default(), maxAckMsgs)
 		t.Cleanup(func() {
 			_ = cliC.Close()
 			_ = srvC.Close()
 		})
 		cliC.Drop = drop
+		cliC.Impair(imp)
+		imp.Seed++
+		srvC.Impair(imp)
 		cliC.Debug = debug
 		srvC.Debug = debug
 		srvAddr := srvC.Addr
@@ -77,7 +85,7 @@ func TestStream(t *testing.T) {
 
 	// Test that general ACK mechanism works for a long sequence of messages.
 	t.Run("sequential", func(t *testing.T) {
-		cliConn, srvRecv := newTest(t, true, true, nil)
+		cliConn, srvRecv := newTest(t, true, true, nil, Impairment{})
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*resendTick)
 		defer cancel()
@@ -102,7 +110,7 @@ func TestStream(t *testing.T) {
 
 	// Test that large queue works.
 	t.Run("long queue", func(t *testing.T) {
-		cliConn, srvRecv := newTest(t, false, false, nil)
+		cliConn, srvRecv := newTest(t, false, false, nil, Impairment{})
 
 		ctx := context.Background()
 
@@ -131,7 +139,7 @@ func TestStream(t *testing.T) {
 				return true
 			}
 			return false
-		})
+		}, Impairment{})
 
 		ctx := context.Background()
 
@@ -147,4 +155,296 @@ func TestStream(t *testing.T) {
 			t.Fatal("expected a message")
 		}
 	})
+
+	// Test that transport stats are collected.
+	t.Run("stats", func(t *testing.T) {
+		dropped := 0
+		cliConn, srvRecv := newTest(t, true, false, func(data []byte) bool {
+			if dropped < 2 {
+				dropped++
+				return true
+			}
+			return false
+		}, Impairment{})
+
+		ctx := context.Background()
+		const n = 5
+		for i := 0; i < n; i++ {
+			exp := &discover.MsgDiscover{Token: uint32(i + 1)}
+			err := cliConn.SendReliable(ctx, 0, exp)
+			must.NoError(t, err)
+			<-srvRecv
+		}
+		st := cliConn.Stats()
+		must.EqOp(t, 2, st.Resends)
+		must.EqOp(t, n+2, st.PacketsOut)
+		must.Positive(t, st.BytesOut)
+		must.GreaterEq(t, n, st.PacketsIn)
+		must.Positive(t, st.BytesIn)
+		must.Positive(t, st.RTT)
+		must.Zero(t, st.Timeouts)
+		must.Zero(t, st.QueuedTotal())
+		must.False(t, st.LastAck.IsZero())
+		must.Less(t, time.Second, st.SinceLastAck())
+
+		cliConn.QueueReliable(3, Options{}, &discover.MsgDiscover{Token: 100})
+		st = cliConn.Stats()
+		must.MapEq(t, map[SID]int{3: 1}, st.Queued)
+
+		ps := cliConn.Port().Stats()
+		must.MapLen(t, 1, ps.Conns)
+		must.EqOp(t, st.PacketsOut, ps.Total.PacketsOut)
+		must.EqOp(t, 1, ps.Total.Queued[3])
+	})
+
+	// Test that retransmission timeout adapts to RTT.
+	t.Run("adaptive", func(t *testing.T) {
+		var drop atomic.Int32
+		cliConn, srvRecv := newTest(t, true, false, func(data []byte) bool {
+			return drop.Add(-1) >= 0
+		}, Impairment{})
+		cliConn.SetConfig(Config{Adaptive: true})
+
+		ctx := context.Background()
+		send := func(i int) time.Duration {
+			start := time.Now()
+			exp := &discover.MsgDiscover{Token: uint32(i + 1)}
+			err := cliConn.SendReliable(ctx, 0, exp)
+			must.NoError(t, err)
+			m := <-srvRecv
+			must.Eq[netmsg.Message](t, exp, m)
+			return time.Since(start)
+		}
+		for i := 0; i < 5; i++ {
+			send(i)
+		}
+		st := cliConn.Stats()
+		must.Positive(t, st.RTT)
+		must.EqOp(t, defaultMinRTO, st.RTO)
+
+		// Packet is lost twice: the first resend happens after RTO, the second one after 2*RTO.
+		drop.Store(2)
+		dt := send(5)
+		must.GreaterEq(t, 3*defaultMinRTO, dt)
+		must.Less(t, resendInterval, dt)
+		must.EqOp(t, 2, cliConn.Stats().Resends)
+	})
+
+	// Test that reliable messages survive a bad link.
+	// There is no loss: duplicates are not acknowledged, so a lost ACK is only repeated by later traffic.
+	t.Run("impaired", func(t *testing.T) {
+		const n = 10
+		cliConn, srvRecv := newTest(t, true, false, nil, Impairment{
+			Seed:      1,
+			Latency:   5 * time.Millisecond,
+			Jitter:    5 * time.Millisecond,
+			Duplicate: 0.1,
+			Reorder:   0.1,
+		})
+
+		ctx := context.Background()
+		timeout := Config{}.withDefaults().Timeout
+		for i := 0; i < n; i++ {
+			exp := &discover.MsgDiscover{Token: uint32(i + 1)}
+			err := cliConn.SendReliable(ctx, 0, exp)
+			must.NoError(t, err)
+			select {
+			case m := <-srvRecv:
+				must.Eq[netmsg.Message](t, exp, m)
+			case <-time.After(timeout):
+				t.Fatal("expected a message")
+			}
+		}
+		must.Zero(t, cliConn.QueuedFor(0))
+		select {
+		case m := <-srvRecv:
+			t.Fatalf("unexpected message: %#v", m)
+		default:
+		}
+	})
+}
+
+func readPipe(t testing.TB, c *PipeConn, timeout time.Duration) []byte {
+	t.Helper()
+	var buf [64]byte
+	done := make(chan int, 1)
+	go func() {
+		n, _, err := c.ReadFromUDPAddrPort(buf[:])
+		if err != nil {
+			n = -1
+		}
+		done <- n
+	}()
+	select {
+	case n := <-done:
+		if n < 0 {
+			return nil
+		}
+		return buf[:n]
+	case <-time.After(timeout):
+		_ = c.Close()
+		<-done
+		return nil
+	}
+}
+
+func TestPipeImpair(t *testing.T) {
+	newPipe := func(t testing.TB, imp Impairment) (*PipeConn, *PipeConn) {
+		srv, cli := NewPipe(slog.Default(), 256)
+		t.Cleanup(func() {
+			_ = cli.Close()
+			_ = srv.Close()
+		})
+		cli.Impair(imp)
+		return srv, cli
+	}
+	send := func(t testing.TB, cli *PipeConn, srv *PipeConn, n int) {
+		for i := 0; i < n; i++ {
+			_, err := cli.WriteToUDPAddrPort([]byte{byte(i)}, srv.Addr)
+			must.NoError(t, err)
+		}
+	}
+	recvAll := func(t testing.TB, srv *PipeConn) []byte {
+		var out []byte
+		for {
+			b := readPipe(t, srv, 100*time.Millisecond)
+			if b == nil {
+				return out
+			}
+			out = append(out, b...)
+		}
+	}
+	t.Run("loss", func(t *testing.T) {
+		var runs [2][]byte
+		for i := range runs {
+			srv, cli := newPipe(t, Impairment{Seed: 42, Loss: 0.3})
+			send(t, cli, srv, 100)
+			runs[i] = recvAll(t, srv)
+		}
+		must.Less(t, 100, len(runs[0]))
+		must.Greater(t, 50, len(runs[0]))
+		must.Eq(t, runs[0], runs[1])
+	})
+	t.Run("duplicate", func(t *testing.T) {
+		srv, cli := newPipe(t, Impairment{Seed: 1, Duplicate: 1})
+		send(t, cli, srv, 10)
+		got := recvAll(t, srv)
+		must.Eq(t, []byte{0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9}, got)
+	})
+	t.Run("reorder", func(t *testing.T) {
+		srv, cli := newPipe(t, Impairment{Seed: 1, Reorder: 0.5})
+		send(t, cli, srv, 20)
+		got := recvAll(t, srv)
+		must.Len(t, 20, got)
+		must.False(t, slices.IsSorted(got))
+		slices.Sort(got)
+		for i, v := range got {
+			must.EqOp(t, byte(i), v)
+		}
+	})
+	t.Run("latency", func(t *testing.T) {
+		const latency = 50 * time.Millisecond
+		srv, cli := newPipe(t, Impairment{Seed: 1, Latency: latency})
+		start := time.Now()
+		send(t, cli, srv, 1)
+		b := readPipe(t, srv, time.Second)
+		must.NotNil(t, b)
+		must.GreaterEq(t, latency, time.Since(start))
+	})
+	t.Run("bandwidth", func(t *testing.T) {
+		srv, cli := newPipe(t, Impairment{Seed: 1, Bandwidth: 100})
+		start := time.Now()
+		send(t, cli, srv, 10)
+		for i := 0; i < 10; i++ {
+			b := readPipe(t, srv, time.Second)
+			must.NotNil(t, b)
+		}
+		must.GreaterEq(t, 100*time.Millisecond, time.Since(start))
+	})
+	t.Run("replace", func(t *testing.T) {
+		srv, cli := newPipe(t, Impairment{Seed: 1, Latency: 50 * time.Millisecond})
+		old := cli.getLink()
+		_, err := cli.WriteToUDPAddrPort([]byte{1}, srv.Addr)
+		must.NoError(t, err)
+		cli.Impair(Impairment{})
+		_, err = cli.WriteToUDPAddrPort([]byte{2}, srv.Addr)
+		must.NoError(t, err)
+		must.Eq(t, []byte{2, 1}, recvAll(t, srv))
+		old.mu.Lock()
+		exited := old.exited
+		old.mu.Unlock()
+		must.True(t, exited)
+	})
+}
+
+func TestNormalizeAddr(t *testing.T) {
+	cases := []struct {
+		in, exp string
+	}{
+		{"1.2.3.4:100", "1.2.3.4:100"},
+		{"[::ffff:1.2.3.4]:100", "1.2.3.4:100"},
+		{"[fe80::1]:100", "[fe80::1]:100"},
+		{"[::]:100", "[::]:100"},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			got := NormalizeAddr(netip.MustParseAddrPort(c.in))
+			must.EqOp(t, netip.MustParseAddrPort(c.exp), got)
+		})
+	}
+}
+
+func TestMappedAddr(t *testing.T) {
+	srvC, cliC := NewPipe(slog.Default(), maxAckMsgs)
+	t.Cleanup(func() {
+		_ = cliC.Close()
+		_ = srvC.Close()
+	})
+	// Dual-stack socket reports IPv4 peers with IPv4-mapped addresses.
+	plain := cliC.Addr
+	cliC.Addr = netip.AddrPortFrom(netip.AddrFrom16(plain.Addr().As16()), plain.Port())
+
+	srvRecv := make(chan Stream, 1)
+	srv := srvC.Port
+	srv.OnMessage(func(s Stream, m netmsg.Message, flags PacketFlags) bool {
+		srvRecv <- s
+		return true
+	})
+	t.Cleanup(srv.Close)
+	cli := cliC.Port
+	t.Cleanup(cli.Close)
+	srv.Start()
+	cli.Start()
+
+	err := cli.Conn(srvC.Addr).SendReliable(context.Background(), 0, &discover.MsgDiscover{Token: 1})
+	must.NoError(t, err)
+	s := <-srvRecv
+	must.EqOp(t, plain, s.Addr())
+	must.EqOp(t, s.Conn(), srv.Conn(plain))
+	must.EqOp(t, s.Conn(), srv.Conn(cliC.Addr))
+	must.MapContainsKey(t, srv.Stats().Conns, plain)
+}
+
+func TestListen(t *testing.T) {
+	cases := []struct {
+		name string
+		addr netip.AddrPort
+		ip   netip.Addr
+	}{
+		{"ipv4", netip.MustParseAddrPort("127.0.0.1:0"), netip.MustParseAddr("127.0.0.1")},
+		{"ipv6", netip.MustParseAddrPort("[::1]:0"), netip.MustParseAddr("::1")},
+		{"dual-stack", netip.AddrPort{}, netip.IPv6Unspecified()},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn, err := Listen(c.addr)
+			if err != nil {
+				t.Skip("cannot listen:", err)
+			}
+			p := NewPort(slog.Default(), conn, netmsg.Options{})
+			defer p.Close()
+			must.EqOp(t, c.ip, p.LocalAddr().Addr())
+			must.NonZero(t, p.LocalAddr().Port())
+		})
+	}
 }
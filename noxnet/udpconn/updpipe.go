@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/netip"
 	"slices"
+	"sync"
 
 	"github.com/opennox/libs/noxnet/netmsg"
 )
@@ -16,6 +17,9 @@ type PipeConn struct {
 	peer   *PipeConn
 	closed chan struct{}
 
+	lmu  sync.Mutex
+	link *pipeLink
+
 	Log   *slog.Logger
 	Addr  netip.AddrPort
 	Port  *Port
@@ -31,7 +35,7 @@ func (c *PipeConn) LocalAddr() net.Addr {
 }
 
 func (c *PipeConn) WriteToUDPAddrPort(data []byte, addr netip.AddrPort) (int, error) {
-	if addr != c.peer.Addr && (addr.Addr() != broadcastIP4 || addr.Port() != c.peer.Addr.Port()) {
+	if NormalizeAddr(addr) != NormalizeAddr(c.peer.Addr) && (!isDiscoveryAddr(addr.Addr()) || addr.Port() != c.peer.Addr.Port()) {
 		return len(data), nil // ignore
 	}
 	drop := c.Drop
@@ -41,6 +45,19 @@ func (c *PipeConn) WriteToUDPAddrPort(data []byte, addr netip.AddrPort) (int, er
 		}
 		return len(data), nil
 	}
+	// the link may stop concurrently if Impair is called; retry with the current one
+	for l := c.getLink(); l != nil; l = c.getLink() {
+		select {
+		case <-c.closed:
+			return 0, io.ErrClosedPipe
+		case <-c.peer.closed:
+			return 0, io.ErrClosedPipe
+		default:
+		}
+		if l.send(slices.Clone(data)) {
+			return len(data), nil
+		}
+	}
 	select {
 	case c.peer.recv <- slices.Clone(data):
 		if c.Log != nil && c.Debug {
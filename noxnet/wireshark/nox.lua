@@ -0,0 +1,892 @@
+-- Code generated by "noxtools net wireshark"; DO NOT EDIT.
+
+-- Wireshark dissector for Nox network protocol.
+-- Copy this file to Wireshark plugins directory (for example, ~/.local/lib/wireshark/plugins).
+
+local nox = Proto("nox", "Nox")
+
+nox.prefs.port = Pref.uint("UDP port", 18590, "Game server port")
+nox.prefs.xor = Pref.uint("XOR key", 0, "Key for decrypting packets after the server accept message")
+
+local ops = {
+	[0] = "MSG_SERVER_CONNECT",
+	[1] = "MSG_SERVER_ACCEPT",
+	[2] = "MSG_CODE2",
+	[3] = "MSG_CODE3",
+	[4] = "MSG_CODE4",
+	[5] = "MSG_CODE5",
+	[6] = "MSG_CLIENT_PING",
+	[7] = "MSG_CODE7",
+	[8] = "MSG_CLIENT_PONG",
+	[9] = "MSG_CODE9",
+	[10] = "MSG_CLIENT_CLOSE",
+	[11] = "MSG_SERVER_CLOSE",
+	[12] = "MSG_SERVER_DISCOVER",
+	[13] = "MSG_SERVER_INFO",
+	[14] = "MSG_SERVER_TRY_JOIN",
+	[15] = "MSG_PASSWORD_REQUIRED",
+	[16] = "MSG_SERVER_PING",
+	[17] = "MSG_SERVER_PASSWORD",
+	[18] = "MSG_SERVER_PONG",
+	[19] = "MSG_SERVER_ERROR",
+	[20] = "MSG_SERVER_JOIN_OK",
+	[21] = "MSG_SERVER_JOIN_FAIL",
+	[22] = "MSG_CODE22",
+	[23] = "MSG_CODE23",
+	[24] = "MSG_CODE24",
+	[25] = "MSG_CODE25",
+	[26] = "MSG_CODE26",
+	[27] = "MSG_CODE27",
+	[28] = "MSG_CODE28",
+	[29] = "MSG_CODE29",
+	[30] = "MSG_CODE30",
+	[31] = "MSG_ACCEPTED",
+	[32] = "MSG_CLIENT_ACCEPT",
+	[33] = "MSG_SERVER_CLOSE_ACK",
+	[34] = "MSG_CLIENT_CLOSE_ACK",
+	[35] = "MSG_SPEED",
+	[36] = "MSG_PING",
+	[37] = "MSG_CODE37",
+	[38] = "MSG_CODE38",
+	[39] = "MSG_TIMESTAMP",
+	[40] = "MSG_FULL_TIMESTAMP",
+	[41] = "MSG_NEED_TIMESTAMP",
+	[42] = "MSG_SIMULATED_TIMESTAMP",
+	[43] = "MSG_USE_MAP",
+	[44] = "MSG_JOIN_DATA",
+	[45] = "MSG_NEW_PLAYER",
+	[46] = "MSG_PLAYER_QUIT",
+	[47] = "MSG_SIMPLE_OBJ",
+	[48] = "MSG_COMPLEX_OBJ",
+	[49] = "MSG_DESTROY_OBJECT",
+	[50] = "MSG_OBJECT_OUT_OF_SIGHT",
+	[51] = "MSG_OBJECT_IN_SHADOWS",
+	[52] = "MSG_OBJECT_FRIEND_ADD",
+	[53] = "MSG_OBJECT_FRIEND_REMOVE",
+	[54] = "MSG_RESET_FRIENDS",
+	[55] = "MSG_ENABLE_OBJECT",
+	[56] = "MSG_DISABLE_OBJECT",
+	[57] = "MSG_DRAW_FRAME",
+	[58] = "MSG_DESTROY_WALL",
+	[59] = "MSG_OPEN_WALL",
+	[60] = "MSG_CLOSE_WALL",
+	[61] = "MSG_CHANGE_OR_ADD_WALL_MAGIC",
+	[62] = "MSG_REMOVE_WALL_MAGIC",
+	[63] = "MSG_PLAYER_INPUT",
+	[64] = "MSG_PLAYER_SET_WAYPOINT",
+	[65] = "MSG_REPORT_HEALTH",
+	[66] = "MSG_REPORT_HEALTH_DELTA",
+	[67] = "MSG_REPORT_PLAYER_HEALTH",
+	[68] = "MSG_REPORT_ITEM_HEALTH",
+	[69] = "MSG_REPORT_MANA",
+	[70] = "MSG_REPORT_POISON",
+	[71] = "MSG_REPORT_STAMINA",
+	[72] = "MSG_REPORT_STATS",
+	[73] = "MSG_REPORT_ARMOR_VALUE",
+	[74] = "MSG_REPORT_GOLD",
+	[75] = "MSG_REPORT_PICKUP",
+	[76] = "MSG_REPORT_MODIFIABLE_PICKUP",
+	[77] = "MSG_REPORT_DROP",
+	[78] = "MSG_REPORT_LESSON",
+	[79] = "MSG_REPORT_MUNDANE_ARMOR_EQUIP",
+	[80] = "MSG_REPORT_MUNDANE_WEAPON_EQUIP",
+	[81] = "MSG_REPORT_MODIFIABLE_WEAPON_EQUIP",
+	[82] = "MSG_REPORT_MODIFIABLE_ARMOR_EQUIP",
+	[83] = "MSG_REPORT_ARMOR_DEQUIP",
+	[84] = "MSG_REPORT_WEAPON_DEQUIP",
+	[85] = "MSG_REPORT_TREASURE_COUNT",
+	[86] = "MSG_REPORT_FLAG_BALL_WINNER",
+	[87] = "MSG_REPORT_FLAG_WINNER",
+	[88] = "MSG_REPORT_DEATHMATCH_WINNER",
+	[89] = "MSG_REPORT_DEATHMATCH_TEAM_WINNER",
+	[90] = "MSG_REPORT_ENCHANTMENT",
+	[91] = "MSG_REPORT_ITEM_ENCHANTMENT",
+	[92] = "MSG_REPORT_LIGHT_COLOR",
+	[93] = "MSG_REPORT_LIGHT_INTENSITY",
+	[94] = "MSG_REPORT_Z_PLUS",
+	[95] = "MSG_REPORT_Z_MINUS",
+	[96] = "MSG_REPORT_EQUIP",
+	[97] = "MSG_REPORT_DEQUIP",
+	[98] = "MSG_REPORT_ACQUIRE_SPELL",
+	[99] = "MSG_REPORT_TARGET",
+	[100] = "MSG_REPORT_CHARGES",
+	[101] = "MSG_REPORT_X_STATUS",
+	[102] = "MSG_REPORT_PLAYER_STATUS",
+	[103] = "MSG_REPORT_MODIFIER",
+	[104] = "MSG_REPORT_STAT_MODIFIER",
+	[105] = "MSG_REPORT_NPC",
+	[106] = "MSG_REPORT_CLIENT_STATUS",
+	[107] = "MSG_REPORT_ANIMATION_FRAME",
+	[108] = "MSG_REPORT_ACQUIRE_CREATURE",
+	[109] = "MSG_REPORT_LOSE_CREATURE",
+	[110] = "MSG_REPORT_EXPERIENCE",
+	[111] = "MSG_REPORT_SPELL_AWARD",
+	[112] = "MSG_REPORT_SPELL_START",
+	[113] = "MSG_REPORT_INVENTORY_LOADED",
+	[114] = "MSG_TRY_DROP",
+	[115] = "MSG_TRY_GET",
+	[116] = "MSG_TRY_USE",
+	[117] = "MSG_TRY_EQUIP",
+	[118] = "MSG_TRY_DEQUIP",
+	[119] = "MSG_TRY_TARGET",
+	[120] = "MSG_TRY_CREATURE_COMMAND",
+	[121] = "MSG_TRY_SPELL",
+	[122] = "MSG_TRY_ABILITY",
+	[123] = "MSG_TRY_COLLIDE",
+	[124] = "MSG_FX_PARTICLEFX",
+	[125] = "MSG_FX_PLASMA",
+	[126] = "MSG_FX_SUMMON",
+	[127] = "MSG_FX_SUMMON_CANCEL",
+	[128] = "MSG_FX_SHIELD",
+	[129] = "MSG_FX_BLUE_SPARKS",
+	[130] = "MSG_FX_YELLOW_SPARKS",
+	[131] = "MSG_FX_CYAN_SPARKS",
+	[132] = "MSG_FX_VIOLET_SPARKS",
+	[133] = "MSG_FX_EXPLOSION",
+	[134] = "MSG_FX_LESSER_EXPLOSION",
+	[135] = "MSG_FX_COUNTERSPELL_EXPLOSION",
+	[136] = "MSG_FX_THIN_EXPLOSION",
+	[137] = "MSG_FX_TELEPORT",
+	[138] = "MSG_FX_SMOKE_BLAST",
+	[139] = "MSG_FX_DAMAGE_POOF",
+	[140] = "MSG_FX_LIGHTNING",
+	[141] = "MSG_FX_ENERGY_BOLT",
+	[142] = "MSG_FX_CHAIN_LIGHTNING_BOLT",
+	[143] = "MSG_FX_DRAIN_MANA",
+	[144] = "MSG_FX_CHARM",
+	[145] = "MSG_FX_GREATER_HEAL",
+	[146] = "MSG_FX_MAGIC",
+	[147] = "MSG_FX_SPARK_EXPLOSION",
+	[148] = "MSG_FX_DEATH_RAY",
+	[149] = "MSG_FX_SENTRY_RAY",
+	[150] = "MSG_FX_RICOCHET",
+	[151] = "MSG_FX_JIGGLE",
+	[152] = "MSG_FX_GREEN_BOLT",
+	[153] = "MSG_FX_GREEN_EXPLOSION",
+	[154] = "MSG_FX_WHITE_FLASH",
+	[155] = "MSG_FX_GENERATING_MAP",
+	[156] = "MSG_FX_ASSEMBLING_MAP",
+	[157] = "MSG_FX_POPULATING_MAP",
+	[158] = "MSG_FX_DURATION_SPELL",
+	[159] = "MSG_FX_DELTAZ_SPELL_START",
+	[160] = "MSG_FX_TURN_UNDEAD",
+	[161] = "MSG_FX_ARROW_TRAP",
+	[162] = "MSG_FX_VAMPIRISM",
+	[163] = "MSG_FX_MANA_BOMB_CANCEL",
+	[164] = "MSG_UPDATE_STREAM",
+	[165] = "MSG_NEW_ALIAS",
+	[166] = "MSG_AUDIO_EVENT",
+	[167] = "MSG_AUDIO_PLAYER_EVENT",
+	[168] = "MSG_TEXT_MESSAGE",
+	[169] = "MSG_INFORM",
+	[170] = "MSG_IMPORTANT",
+	[171] = "MSG_IMPORTANT_ACK",
+	[172] = "MSG_MOUSE",
+	[173] = "MSG_INCOMING_CLIENT",
+	[174] = "MSG_OUTGOING_CLIENT",
+	[175] = "MSG_GAME_SETTINGS",
+	[176] = "MSG_GAME_SETTINGS_2",
+	[177] = "MSG_UPDATE_GUI_GAME_SETTINGS",
+	[178] = "MSG_DOOR_ANGLE",
+	[179] = "MSG_OBELISK_CHARGE",
+	[180] = "MSG_PENTAGRAM_ACTIVATE",
+	[181] = "MSG_CLIENT_PREDICT_LINEAR",
+	[182] = "MSG_REQUEST_MAP",
+	[183] = "MSG_CANCEL_MAP",
+	[184] = "MSG_MAP_SEND_START",
+	[185] = "MSG_MAP_SEND_PACKET",
+	[186] = "MSG_MAP_SEND_ABORT",
+	[187] = "MSG_SERVER_CMD",
+	[188] = "MSG_SYSOP_PW",
+	[189] = "MSG_SYSOP_RESULT",
+	[190] = "MSG_KEEP_ALIVE",
+	[191] = "MSG_RECEIVED_MAP",
+	[192] = "MSG_CLIENT_READY",
+	[193] = "MSG_REQUEST_SAVE_PLAYER",
+	[194] = "MSG_XFER_MSG",
+	[195] = "MSG_PLAYER_OBJ",
+	[196] = "MSG_TEAM_MSG",
+	[197] = "MSG_KICK_NOTIFICATION",
+	[198] = "MSG_TIMEOUT_NOTIFICATION",
+	[199] = "MSG_SERVER_QUIT",
+	[200] = "MSG_SERVER_QUIT_ACK",
+	[201] = "MSG_TRADE",
+	[202] = "MSG_CHAT_KILL",
+	[203] = "MSG_MESSAGES_KILL",
+	[204] = "MSG_SEQ_IMPORTANT",
+	[205] = "MSG_REPORT_ABILITY_AWARD",
+	[206] = "MSG_REPORT_ABILITY_STATE",
+	[207] = "MSG_REPORT_ACTIVE_ABILITIES",
+	[208] = "MSG_DIALOG",
+	[209] = "MSG_REPORT_GUIDE_AWARD",
+	[210] = "MSG_INTERESTING_ID",
+	[211] = "MSG_TIMER_STATUS",
+	[212] = "MSG_REQUEST_TIMER_STATUS",
+	[213] = "MSG_JOURNAL_MSG",
+	[214] = "MSG_CHAPTER_END",
+	[215] = "MSG_REPORT_ALL_LATENCY",
+	[216] = "MSG_REPORT_FLAG_STATUS",
+	[217] = "MSG_REPORT_BALL_STATUS",
+	[218] = "MSG_REPORT_OBJECT_POISON",
+	[219] = "MSG_REPORT_MONITOR_CREATURE",
+	[220] = "MSG_REPORT_UNMONITOR_CREATURE",
+	[221] = "MSG_REPORT_TOTAL_HEALTH",
+	[222] = "MSG_REPORT_TOTAL_MANA",
+	[223] = "MSG_REPORT_SPELL_STAT",
+	[224] = "MSG_REPORT_SECONDARY_WEAPON",
+	[225] = "MSG_REPORT_LAST_QUIVER",
+	[226] = "MSG_INFO_BOOK_DATA",
+	[227] = "MSG_SOCIAL",
+	[228] = "MSG_FADE_BEGIN",
+	[229] = "MSG_MUSIC_EVENT",
+	[230] = "MSG_MUSIC_PUSH_EVENT",
+	[231] = "MSG_MUSIC_POP_EVENT",
+	[232] = "MSG_PLAYER_DIED",
+	[233] = "MSG_PLAYER_RESPAWN",
+	[234] = "MSG_FORGET_DRAWABLES",
+	[235] = "MSG_RESET_ABILITIES",
+	[236] = "MSG_RATE_CHANGE",
+	[237] = "MSG_REPORT_CREATURE_CMD",
+	[238] = "MSG_VOTE",
+	[239] = "MSG_STAT_MULTIPLIERS",
+	[240] = "MSG_GAUNTLET",
+	[241] = "MSG_INVENTORY_FAIL",
+}
+
+local sizes = {
+	[0] = 0,
+	[1] = 5,
+	[2] = 0,
+	[3] = 0,
+	[6] = 4,
+	[8] = 4,
+	[12] = 9,
+	[14] = 97,
+	[15] = 0,
+	[16] = 5,
+	[17] = 19,
+	[18] = 5,
+	[19] = 1,
+	[20] = 0,
+	[21] = 0,
+	[31] = 1,
+	[32] = 153,
+	[35] = 4,
+	[39] = 2,
+	[40] = 4,
+	[41] = 0,
+	[43] = 40,
+	[44] = 6,
+	[45] = 128,
+	[46] = 2,
+	[47] = 8,
+	[48] = 11,
+	[49] = 2,
+	[50] = 2,
+	[51] = 2,
+	[52] = 2,
+	[53] = 2,
+	[54] = 2,
+	[55] = 2,
+	[56] = 2,
+	[57] = 3,
+	[58] = 2,
+	[59] = 2,
+	[60] = 2,
+	[61] = 5,
+	[62] = 2,
+	[65] = 4,
+	[66] = 4,
+	[67] = 2,
+	[68] = 6,
+	[69] = 4,
+	[70] = 3,
+	[71] = 1,
+	[72] = 13,
+	[73] = 4,
+	[74] = 4,
+	[75] = 4,
+	[76] = 8,
+	[77] = 4,
+	[78] = 10,
+	[79] = 6,
+	[80] = 6,
+	[81] = 10,
+	[82] = 10,
+	[83] = 6,
+	[84] = 6,
+	[86] = 7,
+	[87] = 7,
+	[88] = 7,
+	[89] = 7,
+	[90] = 6,
+	[91] = 1,
+	[94] = 3,
+	[95] = 3,
+	[96] = 2,
+	[97] = 2,
+	[100] = 4,
+	[101] = 6,
+	[103] = 6,
+	[104] = 7,
+	[106] = 6,
+	[107] = 6,
+	[110] = 5,
+	[111] = 2,
+	[112] = 1,
+	[113] = 0,
+	[114] = 2,
+	[115] = 2,
+	[116] = 2,
+	[117] = 2,
+	[118] = 2,
+	[119] = 2,
+	[120] = 3,
+	[121] = 21,
+	[122] = 1,
+	[123] = 2,
+	[124] = 8,
+	[125] = 4,
+	[126] = 11,
+	[127] = 2,
+	[128] = 3,
+	[129] = 4,
+	[130] = 4,
+	[131] = 4,
+	[132] = 4,
+	[133] = 4,
+	[134] = 4,
+	[135] = 4,
+	[136] = 4,
+	[137] = 4,
+	[138] = 4,
+	[139] = 4,
+	[140] = 8,
+	[141] = 8,
+	[142] = 8,
+	[143] = 8,
+	[144] = 8,
+	[145] = 8,
+	[146] = 4,
+	[147] = 5,
+	[148] = 8,
+	[149] = 8,
+	[150] = 4,
+	[151] = 1,
+	[152] = 8,
+	[153] = 4,
+	[154] = 4,
+	[155] = 1,
+	[156] = 1,
+	[157] = 1,
+	[158] = 6,
+	[159] = 5,
+	[160] = 4,
+	[161] = 8,
+	[162] = 10,
+	[163] = 4,
+	[165] = 9,
+	[166] = 3,
+	[167] = 3,
+	[172] = 4,
+	[173] = 0,
+	[174] = 2,
+	[175] = 19,
+	[176] = 48,
+	[177] = 59,
+	[178] = 3,
+	[179] = 3,
+	[180] = 3,
+	[181] = 13,
+	[182] = 0,
+	[183] = 0,
+	[184] = 87,
+	[186] = 1,
+	[188] = 20,
+	[189] = 1,
+	[190] = 0,
+	[191] = 0,
+	[192] = 0,
+	[195] = 11,
+	[197] = 0,
+	[198] = 0,
+	[199] = 0,
+	[200] = 0,
+	[205] = 2,
+	[206] = 2,
+	[207] = 2,
+	[210] = 6,
+	[211] = 12,
+	[215] = 4,
+	[216] = 5,
+	[217] = 3,
+	[221] = 6,
+	[222] = 6,
+	[223] = 5,
+	[228] = 2,
+	[232] = 2,
+	[233] = 8,
+	[234] = 4,
+	[235] = 1,
+	[236] = 1,
+	[239] = 16,
+}
+
+local sizes_cli = {
+	[170] = 0,
+	[171] = 4,
+}
+
+local sizes_srv = {
+	[170] = 4,
+	[171] = 4,
+}
+
+-- message fields: {field, offset, size}; offsets are relative to the message payload
+local layouts = {
+	[1] = { -- MsgServerAccept
+		{ProtoField.uint32("nox.server_accept.id", "ID", base.DEC), 0, 4},
+		{ProtoField.uint8("nox.server_accept.xorkey", "XorKey", base.DEC), 4, 1},
+	},
+	[12] = { -- MsgDiscover
+		{ProtoField.bytes("nox.server_discover.unk0", "Unk0"), 0, 5},
+		{ProtoField.uint32("nox.server_discover.token", "Token", base.DEC), 5, 4},
+	},
+	[19] = { -- MsgServerError
+		{ProtoField.uint8("nox.server_error.err", "Err", base.DEC), 0, 1},
+	},
+	[31] = { -- MsgAccept
+		{ProtoField.uint8("nox.accepted.id", "ID", base.DEC), 0, 1},
+	},
+	[35] = { -- MsgSpeed
+		{ProtoField.int32("nox.speed.speed", "Speed", base.DEC), 0, 4},
+	},
+	[39] = { -- MsgTimestamp
+		{ProtoField.uint16("nox.timestamp.t", "T", base.DEC), 0, 2},
+	},
+	[40] = { -- MsgFullTimestamp
+		{ProtoField.uint32("nox.full_timestamp.t", "T", base.DEC), 0, 4},
+	},
+	[44] = { -- MsgJoinData
+		{ProtoField.uint16("nox.join_data.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.join_data.unk2", "Unk2", base.DEC), 2, 4},
+	},
+	[49] = { -- MsgDestroyObject
+		{ProtoField.uint16("nox.destroy_object.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[50] = { -- MsgObjectOutOfSight
+		{ProtoField.uint16("nox.object_out_of_sight.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[51] = { -- MsgObjectInShadows
+		{ProtoField.uint16("nox.object_in_shadows.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[52] = { -- MsgObjectFriendAdd
+		{ProtoField.uint16("nox.object_friend_add.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[53] = { -- MsgObjectFriendRemove
+		{ProtoField.uint16("nox.object_friend_remove.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[54] = { -- MsgResetFriends
+		{ProtoField.uint16("nox.reset_friends.unk0", "Unk0", base.DEC), 0, 2},
+	},
+	[55] = { -- MsgEnableObject
+		{ProtoField.uint16("nox.enable_object.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[56] = { -- MsgDisableObject
+		{ProtoField.uint16("nox.disable_object.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[58] = { -- MsgWallDestroy
+		{ProtoField.uint16("nox.destroy_wall.id", "ID", base.DEC), 0, 2},
+	},
+	[59] = { -- MsgWallOpen
+		{ProtoField.uint8("nox.open_wall.pos_x", "Pos.X", base.DEC), 0, 1},
+		{ProtoField.uint8("nox.open_wall.pos_y", "Pos.Y", base.DEC), 1, 1},
+	},
+	[60] = { -- MsgWallClose
+		{ProtoField.uint8("nox.close_wall.pos_x", "Pos.X", base.DEC), 0, 1},
+		{ProtoField.uint8("nox.close_wall.pos_y", "Pos.Y", base.DEC), 1, 1},
+	},
+	[61] = { -- MsgWallMagic
+		{ProtoField.uint8("nox.change_or_add_wall_magic.pos_x", "Pos.X", base.DEC), 0, 1},
+		{ProtoField.uint8("nox.change_or_add_wall_magic.pos_y", "Pos.Y", base.DEC), 1, 1},
+		{ProtoField.uint8("nox.change_or_add_wall_magic.dir", "Dir", base.DEC), 2, 1},
+		{ProtoField.uint8("nox.change_or_add_wall_magic.material", "Material", base.DEC), 3, 1},
+		{ProtoField.uint8("nox.change_or_add_wall_magic.variant", "Variant", base.DEC), 4, 1},
+	},
+	[62] = { -- MsgWallMagicRemove
+		{ProtoField.uint8("nox.remove_wall_magic.pos_x", "Pos.X", base.DEC), 0, 1},
+		{ProtoField.uint8("nox.remove_wall_magic.pos_y", "Pos.Y", base.DEC), 1, 1},
+	},
+	[65] = { -- MsgReportHealth
+		{ProtoField.uint16("nox.report_health.health", "Health", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.report_health.max", "Max", base.DEC), 2, 2},
+	},
+	[66] = { -- MsgReportHealthDelta
+		{ProtoField.uint16("nox.report_health_delta.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.int16("nox.report_health_delta.delta", "Delta", base.DEC), 2, 2},
+	},
+	[67] = { -- MsgReportPlayerHealth
+		{ProtoField.uint8("nox.report_player_health.player", "Player", base.DEC), 0, 1},
+		{ProtoField.uint8("nox.report_player_health.percent", "Percent", base.DEC), 1, 1},
+	},
+	[68] = { -- MsgReportItemHealth
+		{ProtoField.uint16("nox.report_item_health.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.report_item_health.health", "Health", base.DEC), 2, 2},
+		{ProtoField.uint16("nox.report_item_health.max", "Max", base.DEC), 4, 2},
+	},
+	[69] = { -- MsgReportMana
+		{ProtoField.uint16("nox.report_mana.mana", "Mana", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.report_mana.max", "Max", base.DEC), 2, 2},
+	},
+	[70] = { -- MsgReportPoison
+		{ProtoField.uint16("nox.report_poison.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint8("nox.report_poison.level", "Level", base.DEC), 2, 1},
+	},
+	[71] = { -- MsgReportStamina
+		{ProtoField.uint8("nox.report_stamina.stamina", "Stamina", base.DEC), 0, 1},
+	},
+	[72] = { -- MsgReportStats
+		{ProtoField.uint16("nox.report_stats.health", "Health", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.report_stats.mana", "Mana", base.DEC), 2, 2},
+		{ProtoField.uint16("nox.report_stats.weight", "Weight", base.DEC), 4, 2},
+		{ProtoField.uint16("nox.report_stats.speed", "Speed", base.DEC), 6, 2},
+		{ProtoField.uint16("nox.report_stats.strength", "Strength", base.DEC), 8, 2},
+		{ProtoField.uint16("nox.report_stats.maxweight", "MaxWeight", base.DEC), 10, 2},
+		{ProtoField.uint8("nox.report_stats.level", "Level", base.DEC), 12, 1},
+	},
+	[73] = { -- MsgReportArmorValue
+		{ProtoField.uint32("nox.report_armor_value.value", "Value", base.DEC), 0, 4},
+	},
+	[74] = { -- MsgReportGold
+		{ProtoField.uint32("nox.report_gold.gold", "Gold", base.DEC), 0, 4},
+	},
+	[75] = { -- MsgReportPickup
+		{ProtoField.uint16("nox.report_pickup.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.report_pickup.type", "Type", base.DEC), 2, 2},
+	},
+	[76] = { -- MsgReportModifiablePickup
+		{ProtoField.uint16("nox.report_modifiable_pickup.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.report_modifiable_pickup.type", "Type", base.DEC), 2, 2},
+		{ProtoField.bytes("nox.report_modifiable_pickup.mods", "Mods"), 4, 4},
+	},
+	[77] = { -- MsgReportDrop
+		{ProtoField.uint16("nox.report_drop.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.report_drop.unk2", "Unk2", base.DEC), 2, 2},
+	},
+	[78] = { -- MsgReportLesson
+		{ProtoField.uint16("nox.report_lesson.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.int32("nox.report_lesson.lessons", "Lessons", base.DEC), 2, 4},
+		{ProtoField.uint32("nox.report_lesson.unk6", "Unk6", base.DEC), 6, 4},
+	},
+	[79] = { -- MsgReportMundaneArmorEquip
+		{ProtoField.uint16("nox.report_mundane_armor_equip.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_mundane_armor_equip.slot", "Slot", base.DEC), 2, 4},
+	},
+	[80] = { -- MsgReportMundaneWeaponEquip
+		{ProtoField.uint16("nox.report_mundane_weapon_equip.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_mundane_weapon_equip.slot", "Slot", base.DEC), 2, 4},
+	},
+	[81] = { -- MsgReportModifiableWeaponEquip
+		{ProtoField.uint16("nox.report_modifiable_weapon_equip.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_modifiable_weapon_equip.slot", "Slot", base.DEC), 2, 4},
+		{ProtoField.bytes("nox.report_modifiable_weapon_equip.mods", "Mods"), 6, 4},
+	},
+	[82] = { -- MsgReportModifiableArmorEquip
+		{ProtoField.uint16("nox.report_modifiable_armor_equip.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_modifiable_armor_equip.slot", "Slot", base.DEC), 2, 4},
+		{ProtoField.bytes("nox.report_modifiable_armor_equip.mods", "Mods"), 6, 4},
+	},
+	[83] = { -- MsgReportArmorDequip
+		{ProtoField.uint16("nox.report_armor_dequip.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_armor_dequip.slot", "Slot", base.DEC), 2, 4},
+	},
+	[84] = { -- MsgReportWeaponDequip
+		{ProtoField.uint16("nox.report_weapon_dequip.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_weapon_dequip.slot", "Slot", base.DEC), 2, 4},
+	},
+	[86] = { -- MsgReportFlagBallWinner
+		{ProtoField.uint16("nox.report_flag_ball_winner.unk0", "Unk0", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_flag_ball_winner.unk2", "Unk2", base.DEC), 2, 4},
+		{ProtoField.uint8("nox.report_flag_ball_winner.unk6", "Unk6", base.DEC), 6, 1},
+	},
+	[87] = { -- MsgReportFlagWinner
+		{ProtoField.uint16("nox.report_flag_winner.unk0", "Unk0", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_flag_winner.unk2", "Unk2", base.DEC), 2, 4},
+		{ProtoField.uint8("nox.report_flag_winner.unk6", "Unk6", base.DEC), 6, 1},
+	},
+	[88] = { -- MsgReportDeathmatchWinner
+		{ProtoField.uint16("nox.report_deathmatch_winner.unk0", "Unk0", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_deathmatch_winner.unk2", "Unk2", base.DEC), 2, 4},
+		{ProtoField.uint8("nox.report_deathmatch_winner.unk6", "Unk6", base.DEC), 6, 1},
+	},
+	[89] = { -- MsgReportDeathmatchTeamWinner
+		{ProtoField.uint16("nox.report_deathmatch_team_winner.unk0", "Unk0", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_deathmatch_team_winner.unk2", "Unk2", base.DEC), 2, 4},
+		{ProtoField.uint8("nox.report_deathmatch_team_winner.unk6", "Unk6", base.DEC), 6, 1},
+	},
+	[90] = { -- MsgReportEnchantment
+		{ProtoField.uint16("nox.report_enchantment.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_enchantment.enchants", "Enchants", base.DEC), 2, 4},
+	},
+	[91] = { -- MsgReportItemEnchantment
+		{ProtoField.uint8("nox.report_item_enchantment.unk0", "Unk0", base.DEC), 0, 1},
+	},
+	[94] = { -- MsgReportZPlus
+		{ProtoField.uint16("nox.report_z_plus.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint8("nox.report_z_plus.value", "Value", base.DEC), 2, 1},
+	},
+	[95] = { -- MsgReportZMinus
+		{ProtoField.uint16("nox.report_z_minus.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint8("nox.report_z_minus.value", "Value", base.DEC), 2, 1},
+	},
+	[96] = { -- MsgReportEquip
+		{ProtoField.uint16("nox.report_equip.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[97] = { -- MsgReportDequip
+		{ProtoField.uint16("nox.report_dequip.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[100] = { -- MsgReportCharges
+		{ProtoField.uint16("nox.report_charges.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint8("nox.report_charges.charges", "Charges", base.DEC), 2, 1},
+		{ProtoField.uint8("nox.report_charges.max", "Max", base.DEC), 3, 1},
+	},
+	[101] = { -- MsgReportXStatus
+		{ProtoField.uint16("nox.report_x_status.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_x_status.value", "Value", base.DEC), 2, 4},
+	},
+	[103] = { -- MsgReportModifier
+		{ProtoField.uint16("nox.report_modifier.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_modifier.value", "Value", base.DEC), 2, 4},
+	},
+	[104] = { -- MsgReportStatModifier
+		{ProtoField.uint16("nox.report_stat_modifier.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint8("nox.report_stat_modifier.unk2", "Unk2", base.DEC), 2, 1},
+		{ProtoField.uint32("nox.report_stat_modifier.unk3", "Unk3", base.DEC), 3, 4},
+	},
+	[106] = { -- MsgReportClientStatus
+		{ProtoField.uint16("nox.report_client_status.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_client_status.value", "Value", base.DEC), 2, 4},
+	},
+	[107] = { -- MsgReportAnimationFrame
+		{ProtoField.uint16("nox.report_animation_frame.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.report_animation_frame.value", "Value", base.DEC), 2, 4},
+	},
+	[110] = { -- MsgReportExperience
+		{ProtoField.uint8("nox.report_experience.level", "Level", base.DEC), 0, 1},
+		{ProtoField.float("nox.report_experience.exp", "Exp"), 1, 4},
+	},
+	[111] = { -- MsgReportSpellAward
+		{ProtoField.uint8("nox.report_spell_award.spell", "Spell", base.DEC), 0, 1},
+		{ProtoField.uint8("nox.report_spell_award.level", "Level", base.DEC), 1, 1},
+	},
+	[112] = { -- MsgReportSpellStart
+		{ProtoField.uint8("nox.report_spell_start.spell", "Spell", base.DEC), 0, 1},
+	},
+	[114] = { -- MsgTryDrop
+		{ProtoField.uint16("nox.try_drop.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[115] = { -- MsgTryGet
+		{ProtoField.uint16("nox.try_get.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[116] = { -- MsgTryUse
+		{ProtoField.uint16("nox.try_use.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[117] = { -- MsgTryEquip
+		{ProtoField.uint16("nox.try_equip.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[118] = { -- MsgTryDequip
+		{ProtoField.uint16("nox.try_dequip.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[119] = { -- MsgTryTarget
+		{ProtoField.uint16("nox.try_target.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[120] = { -- MsgTryCreatureCommand
+		{ProtoField.uint16("nox.try_creature_command.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint8("nox.try_creature_command.command", "Command", base.DEC), 2, 1},
+	},
+	[121] = { -- MsgTrySpell
+		{ProtoField.uint32("nox.try_spell.spells_0", "Spells[0]", base.DEC), 0, 4},
+		{ProtoField.uint32("nox.try_spell.spells_1", "Spells[1]", base.DEC), 4, 4},
+		{ProtoField.uint32("nox.try_spell.spells_2", "Spells[2]", base.DEC), 8, 4},
+		{ProtoField.uint32("nox.try_spell.spells_3", "Spells[3]", base.DEC), 12, 4},
+		{ProtoField.uint32("nox.try_spell.spells_4", "Spells[4]", base.DEC), 16, 4},
+		{ProtoField.uint8("nox.try_spell.flags", "Flags", base.DEC), 20, 1},
+	},
+	[122] = { -- MsgTryAbility
+		{ProtoField.uint8("nox.try_ability.ability", "Ability", base.DEC), 0, 1},
+	},
+	[123] = { -- MsgTryCollide
+		{ProtoField.uint16("nox.try_collide.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[125] = { -- MsgFxPlasma
+		{ProtoField.uint16("nox.fx_plasma.source", "Source", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.fx_plasma.target", "Target", base.DEC), 2, 2},
+	},
+	[127] = { -- MsgFxSummonCancel
+		{ProtoField.uint16("nox.fx_summon_cancel.netcode", "NetCode", base.DEC), 0, 2},
+	},
+	[128] = { -- MsgFxShield
+		{ProtoField.uint16("nox.fx_shield.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint8("nox.fx_shield.dir", "Dir", base.DEC), 2, 1},
+	},
+	[151] = { -- MsgFxJiggle
+		{ProtoField.uint8("nox.fx_jiggle.val", "Val", base.DEC), 0, 1},
+	},
+	[155] = { -- MsgFxGeneratingMap
+		{ProtoField.uint8("nox.fx_generating_map.progress", "Progress", base.DEC), 0, 1},
+	},
+	[156] = { -- MsgFxAssemblingMap
+		{ProtoField.uint8("nox.fx_assembling_map.progress", "Progress", base.DEC), 0, 1},
+	},
+	[157] = { -- MsgFxPopulatingMap
+		{ProtoField.uint8("nox.fx_populating_map.progress", "Progress", base.DEC), 0, 1},
+	},
+	[158] = { -- MsgFxDurationSpell
+		{ProtoField.uint16("nox.fx_duration_spell.spell", "Spell", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.fx_duration_spell.netcode", "NetCode", base.DEC), 2, 2},
+		{ProtoField.uint16("nox.fx_duration_spell.duration", "Duration", base.DEC), 4, 2},
+	},
+	[159] = { -- MsgFxDeltaZSpellStart
+		{ProtoField.uint8("nox.fx_deltaz_spell_start.spell", "Spell", base.DEC), 0, 1},
+		{ProtoField.uint16("nox.fx_deltaz_spell_start.source", "Source", base.DEC), 1, 2},
+		{ProtoField.uint16("nox.fx_deltaz_spell_start.target", "Target", base.DEC), 3, 2},
+	},
+	[165] = { -- MsgNewAlias
+		{ProtoField.uint8("nox.new_alias.alias_alias", "Alias.Alias", base.DEC), 0, 1},
+		{ProtoField.uint16("nox.new_alias.id_id", "ID.ID", base.DEC), 1, 2},
+		{ProtoField.uint16("nox.new_alias.id_type", "ID.Type", base.DEC), 3, 2},
+		{ProtoField.uint32("nox.new_alias.deadline", "Deadline", base.DEC), 5, 4},
+	},
+	[172] = { -- MsgMouse
+		{ProtoField.uint16("nox.mouse.x", "X", base.DEC), 0, 2},
+		{ProtoField.uint16("nox.mouse.y", "Y", base.DEC), 2, 2},
+	},
+	[186] = { -- MsgMapSendAbort
+		{ProtoField.uint8("nox.map_send_abort.code", "Code", base.DEC), 0, 1},
+	},
+	[189] = { -- MsgSysopResult
+		{ProtoField.uint8("nox.sysop_result.result", "Result", base.DEC), 0, 1},
+	},
+	[205] = { -- MsgAbilityAward
+		{ProtoField.uint8("nox.report_ability_award.ability", "Ability", base.DEC), 0, 1},
+		{ProtoField.uint8("nox.report_ability_award.level", "Level", base.DEC), 1, 1},
+	},
+	[228] = { -- MsgFadeBegin
+		{ProtoField.uint8("nox.fade_begin.out", "Out", base.DEC), 0, 1},
+		{ProtoField.uint8("nox.fade_begin.menu", "Menu", base.DEC), 1, 1},
+	},
+	[233] = { -- MsgPlayerRespawn
+		{ProtoField.uint16("nox.player_respawn.netcode", "NetCode", base.DEC), 0, 2},
+		{ProtoField.uint32("nox.player_respawn.unk2", "Unk2", base.DEC), 2, 4},
+		{ProtoField.uint8("nox.player_respawn.unk6", "Unk6", base.DEC), 6, 1},
+		{ProtoField.uint8("nox.player_respawn.unk7", "Unk7", base.DEC), 7, 1},
+	},
+	[234] = { -- MsgForgetDrawables
+		{ProtoField.uint32("nox.forget_drawables.unk0", "Unk0", base.DEC), 0, 4},
+	},
+	[236] = { -- MsgRateChange
+		{ProtoField.uint8("nox.rate_change.rate", "Rate", base.DEC), 0, 1},
+	},
+}
+
+local layouts_cli = {
+	[171] = { -- MsgImportantAckCli
+		{ProtoField.uint32("nox.important_ack_cli.id", "ID", base.DEC), 0, 4},
+	},
+}
+
+local layouts_srv = {
+	[170] = { -- MsgImportantSrv
+		{ProtoField.uint32("nox.important_srv.id", "ID", base.DEC), 0, 4},
+	},
+	[171] = { -- MsgImportantAckSrv
+		{ProtoField.uint32("nox.important_ack_srv.ts", "TS", base.DEC), 0, 4},
+	},
+}
+
+local hdr_sid = ProtoField.uint8("nox.sid", "Stream ID", base.DEC, nil, 0x7f)
+local hdr_reliable = ProtoField.bool("nox.reliable", "Reliable", 8, nil, 0x80)
+local hdr_seq = ProtoField.uint8("nox.seq", "Seq", base.DEC)
+local msg_op = ProtoField.uint8("nox.op", "Opcode", base.DEC, ops)
+local msg_data = ProtoField.bytes("nox.data", "Data")
+
+local fields = {hdr_sid, hdr_reliable, hdr_seq, msg_op, msg_data}
+for _, t in ipairs({layouts, layouts_cli, layouts_srv}) do
+	for _, l in pairs(t) do
+		for _, f in ipairs(l) do
+			table.insert(fields, f[1])
+		end
+	end
+end
+nox.fields = fields
+
+local function pick(side, both, op)
+	local v = side[op]
+	if v == nil then
+		v = both[op]
+	end
+	return v
+end
+
+function nox.dissector(buf, pinfo, tree)
+	local n = buf:len()
+	if n < 2 then
+		return 0
+	end
+	local key = nox.prefs.xor
+	if key ~= 0 then
+		local ba = buf:bytes()
+		for i = 0, n - 1 do
+			ba:set_index(i, bit.bxor(ba:get_index(i), key))
+		end
+		buf = ba:tvb("Decrypted")
+	end
+	pinfo.cols.protocol = "NOX"
+	-- side-specific tables are named after the side that receives the message
+	local to_client = pinfo.src_port == nox.prefs.port
+	local side_sizes, side_layouts = sizes_srv, layouts_srv
+	if to_client then
+		side_sizes, side_layouts = sizes_cli, layouts_cli
+	end
+	local t = tree:add(nox, buf())
+	t:add(hdr_sid, buf(0, 1))
+	t:add(hdr_reliable, buf(0, 1))
+	t:add(hdr_seq, buf(1, 1))
+	local names = {}
+	local off = 2
+	while off < n do
+		local op = buf(off, 1):uint()
+		local sz = pick(side_sizes, sizes, op)
+		if sz == nil or off + 1 + sz > n then
+			sz = n - off - 1
+		end
+		local name = ops[op] or ("Op(" .. op .. ")")
+		table.insert(names, name)
+		local mt = t:add(buf(off, 1 + sz), name)
+		mt:add(msg_op, buf(off, 1))
+		local l = pick(side_layouts, layouts, op)
+		if l ~= nil then
+			for _, f in ipairs(l) do
+				if f[2] + f[3] <= sz then
+					mt:add_le(f[1], buf(off + 1 + f[2], f[3]))
+				end
+			end
+		elseif sz > 0 then
+			mt:add(msg_data, buf(off + 1, sz))
+		end
+		off = off + 1 + sz
+	end
+	pinfo.cols.info = table.concat(names, ", ")
+	return n
+end
+
+local udp_port = DissectorTable.get("udp.port")
+local cur_port = nil
+
+function nox.init()
+	if cur_port ~= nil then
+		udp_port:remove(cur_port, nox)
+	end
+	cur_port = nox.prefs.port
+	udp_port:add(cur_port, nox)
+end
@@ -0,0 +1,406 @@
+// Package wireshark generates Wireshark Lua dissector for Nox network protocol.
+//
+// The dissector is generated from messages registered in netmsg, so it must be regenerated when messages are added.
+package wireshark
+
+//go:generate go run ../../cmd/noxtools net wireshark nox.lua
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/opennox/libs/common"
+	_ "github.com/opennox/libs/noxnet"
+	_ "github.com/opennox/libs/noxnet/discover"
+	_ "github.com/opennox/libs/noxnet/mapsend"
+	"github.com/opennox/libs/noxnet/netmsg"
+	_ "github.com/opennox/libs/noxnet/netxfer"
+)
+
+// Field describes a field in the fixed message layout.
+type Field struct {
+	// Name is a Go field path, for example "Pos.X".
+	Name string
+	Kind reflect.Kind
+	// Offset of the field in the message payload (after the opcode).
+	Offset int
+	Size   int
+}
+
+// Layout returns fields of a fixed-size message, in the order of the payload.
+//
+// Fields are read from the message struct and checked by encoding a probe message.
+// It returns false if the message is dynamic, or if the layout doesn't match the encoded message.
+func Layout(r netmsg.Registration) ([]Field, bool) {
+	m := r.New()
+	if _, ok := m.(netmsg.ComplexMessage); ok {
+		return nil, false
+	}
+	var fields []Field
+	if !appendFields(&fields, r.Type, "", 0) {
+		return nil, false
+	}
+	size := 0
+	if len(fields) != 0 {
+		last := fields[len(fields)-1]
+		size = last.Offset + last.Size
+	}
+	if m.EncodeSize() != size {
+		return nil, false
+	}
+	if !checkLayout(r, fields, size) {
+		return nil, false
+	}
+	return fields, true
+}
+
+func appendFields(out *[]Field, rt reflect.Type, prefix string, off int) bool {
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			return false
+		}
+		name := prefix + f.Name
+		if f.Anonymous {
+			name = prefix
+		}
+		n, ok := appendField(out, f.Type, name, off)
+		if !ok {
+			return false
+		}
+		off += n
+	}
+	return true
+}
+
+func appendField(out *[]Field, rt reflect.Type, name string, off int) (int, bool) {
+	switch k := rt.Kind(); k {
+	case reflect.Bool, reflect.Uint8, reflect.Int8,
+		reflect.Uint16, reflect.Int16,
+		reflect.Uint32, reflect.Int32, reflect.Float32,
+		reflect.Uint64, reflect.Int64, reflect.Float64:
+		n := int(rt.Size())
+		*out = append(*out, Field{Name: name, Kind: k, Offset: off, Size: n})
+		return n, true
+	case reflect.Array:
+		if rt.Elem().Kind() == reflect.Uint8 {
+			*out = append(*out, Field{Name: name, Kind: k, Offset: off, Size: rt.Len()})
+			return rt.Len(), true
+		}
+		start := off
+		for i := range rt.Len() {
+			n, ok := appendField(out, rt.Elem(), fmt.Sprintf("%s[%d]", name, i), off)
+			if !ok {
+				return 0, false
+			}
+			off += n
+		}
+		return off - start, true
+	case reflect.Struct:
+		start := len(*out)
+		if name != "" {
+			name += "."
+		}
+		if !appendFields(out, rt, name, off) {
+			return 0, false
+		}
+		n := 0
+		for _, f := range (*out)[start:] {
+			n += f.Size
+		}
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// checkLayout fills message fields with a pattern and checks that the message encodes to the same bytes.
+func checkLayout(r netmsg.Registration, fields []Field, size int) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	exp := make([]byte, size)
+	for i := range exp {
+		exp[i] = byte(i*7 + 1)
+	}
+	m := r.New()
+	v := reflect.ValueOf(m).Elem()
+	for _, f := range fields {
+		fv := fieldByPath(v, f.Name)
+		if !fv.IsValid() {
+			return false
+		}
+		data := exp[f.Offset : f.Offset+f.Size]
+		switch f.Kind {
+		case reflect.Bool:
+			data[0] = 1
+			fv.SetBool(true)
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fv.SetUint(readUint(data))
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fv.SetInt(int64(readUint(data)))
+		case reflect.Float32:
+			// avoid NaN patterns
+			binary.LittleEndian.PutUint32(data, math.Float32bits(float32(f.Offset+1)))
+			fv.SetFloat(float64(float32(f.Offset + 1)))
+		case reflect.Float64:
+			binary.LittleEndian.PutUint64(data, math.Float64bits(float64(f.Offset+1)))
+			fv.SetFloat(float64(f.Offset + 1))
+		case reflect.Array:
+			reflect.Copy(fv, reflect.ValueOf(data))
+		default:
+			return false
+		}
+	}
+	got := make([]byte, size)
+	n, err := m.Encode(got)
+	return err == nil && n == size && bytes.Equal(exp, got)
+}
+
+func readUint(data []byte) uint64 {
+	var buf [8]byte
+	copy(buf[:], data)
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+// fieldByPath finds a field by its path. Path elements of embedded structs are omitted, thus the search is recursive.
+func fieldByPath(v reflect.Value, path string) reflect.Value {
+	for path != "" {
+		name, rest, _ := strings.Cut(path, ".")
+		ind := -1
+		if i := strings.IndexByte(name, '['); i >= 0 {
+			fmt.Sscanf(name[i:], "[%d]", &ind)
+			name = name[:i]
+		}
+		if name == "" {
+			// array element of embedded array
+			v = v.Index(ind)
+		} else {
+			v = v.FieldByName(name)
+			if !v.IsValid() {
+				return v
+			}
+			if ind >= 0 {
+				v = v.Index(ind)
+			}
+		}
+		path = rest
+	}
+	return v
+}
+
+// Options for the dissector generator.
+type Options struct {
+	// Port is the default game port. Default is common.GamePort.
+	Port int
+}
+
+// Generate writes Wireshark Lua dissector for all registered messages.
+func Generate(w io.Writer, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	port := opts.Port
+	if port <= 0 {
+		port = common.GamePort
+	}
+	var (
+		buf    bytes.Buffer
+		layout [3]bytes.Buffer // both, client, server
+		sizes  [3]bytes.Buffer
+	)
+	buf.WriteString(luaHeader)
+	fmt.Fprintf(&buf, "nox.prefs.port = Pref.uint(\"UDP port\", %d, \"Game server port\")\n", port)
+	buf.WriteString("nox.prefs.xor = Pref.uint(\"XOR key\", 0, \"Key for decrypting packets after the server accept message\")\n\n")
+
+	buf.WriteString("local ops = {\n")
+	for i := range 256 {
+		op := netmsg.Op(i)
+		if name := op.String(); !strings.HasPrefix(name, "Op(") {
+			fmt.Fprintf(&buf, "\t[%d] = %q,\n", i, name)
+		}
+	}
+	buf.WriteString("}\n\n")
+
+	for i := range 256 {
+		if n := netmsg.Op(i).Len(); n >= 0 {
+			fmt.Fprintf(&sizes[0], "\t[%d] = %d,\n", i, n)
+		}
+	}
+	for _, r := range netmsg.Registered() {
+		fields, ok := Layout(r)
+		if !ok {
+			continue
+		}
+		side, suffix := 0, ""
+		switch {
+		case r.Client:
+			side, suffix = 1, "_cli"
+		case r.Server:
+			side, suffix = 2, "_srv"
+		}
+		if side != 0 && len(fields) != 0 {
+			last := fields[len(fields)-1]
+			fmt.Fprintf(&sizes[side], "\t[%d] = %d,\n", int(r.Op), last.Offset+last.Size)
+		} else if side != 0 {
+			fmt.Fprintf(&sizes[side], "\t[%d] = 0,\n", int(r.Op))
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		prefix := "nox." + strings.ToLower(strings.TrimPrefix(r.Op.String(), "MSG_")) + suffix + "."
+		fmt.Fprintf(&layout[side], "\t[%d] = { -- %s\n", int(r.Op), r.Type.Name())
+		for _, f := range fields {
+			abbr := prefix + strings.NewReplacer(".", "_", "[", "_", "]", "").Replace(strings.ToLower(f.Name))
+			fmt.Fprintf(&layout[side], "\t\t{%s, %d, %d},\n", protoField(f, abbr), f.Offset, f.Size)
+		}
+		layout[side].WriteString("\t},\n")
+	}
+	for i, name := range []string{"", "_cli", "_srv"} {
+		fmt.Fprintf(&buf, "local sizes%s = {\n", name)
+		buf.Write(sizes[i].Bytes())
+		buf.WriteString("}\n\n")
+	}
+	buf.WriteString("-- message fields: {field, offset, size}; offsets are relative to the message payload\n")
+	for i, name := range []string{"", "_cli", "_srv"} {
+		fmt.Fprintf(&buf, "local layouts%s = {\n", name)
+		buf.Write(layout[i].Bytes())
+		buf.WriteString("}\n\n")
+	}
+	buf.WriteString(luaDissector)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func protoField(f Field, abbr string) string {
+	var typ string
+	switch f.Kind {
+	case reflect.Bool, reflect.Uint8:
+		typ = "uint8"
+	case reflect.Int8:
+		typ = "int8"
+	case reflect.Uint16:
+		typ = "uint16"
+	case reflect.Int16:
+		typ = "int16"
+	case reflect.Uint32:
+		typ = "uint32"
+	case reflect.Int32:
+		typ = "int32"
+	case reflect.Uint64:
+		typ = "uint64"
+	case reflect.Int64:
+		typ = "int64"
+	case reflect.Float32:
+		return fmt.Sprintf("ProtoField.float(%q, %q)", abbr, f.Name)
+	case reflect.Float64:
+		return fmt.Sprintf("ProtoField.double(%q, %q)", abbr, f.Name)
+	default:
+		return fmt.Sprintf("ProtoField.bytes(%q, %q)", abbr, f.Name)
+	}
+	return fmt.Sprintf("ProtoField.%s(%q, %q, base.DEC)", typ, abbr, f.Name)
+}
+
+const luaHeader = `-- Code generated by "noxtools net wireshark"; DO NOT EDIT.
+
+-- Wireshark dissector for Nox network protocol.
+-- Copy this file to Wireshark plugins directory (for example, ~/.local/lib/wireshark/plugins).
+
+local nox = Proto("nox", "Nox")
+
+`
+
+const luaDissector = `local hdr_sid = ProtoField.uint8("nox.sid", "Stream ID", base.DEC, nil, 0x7f)
+local hdr_reliable = ProtoField.bool("nox.reliable", "Reliable", 8, nil, 0x80)
+local hdr_seq = ProtoField.uint8("nox.seq", "Seq", base.DEC)
+local msg_op = ProtoField.uint8("nox.op", "Opcode", base.DEC, ops)
+local msg_data = ProtoField.bytes("nox.data", "Data")
+
+local fields = {hdr_sid, hdr_reliable, hdr_seq, msg_op, msg_data}
+for _, t in ipairs({layouts, layouts_cli, layouts_srv}) do
+	for _, l in pairs(t) do
+		for _, f in ipairs(l) do
+			table.insert(fields, f[1])
+		end
+	end
+end
+nox.fields = fields
+
+local function pick(side, both, op)
+	local v = side[op]
+	if v == nil then
+		v = both[op]
+	end
+	return v
+end
+
+function nox.dissector(buf, pinfo, tree)
+	local n = buf:len()
+	if n < 2 then
+		return 0
+	end
+	local key = nox.prefs.xor
+	if key ~= 0 then
+		local ba = buf:bytes()
+		for i = 0, n - 1 do
+			ba:set_index(i, bit.bxor(ba:get_index(i), key))
+		end
+		buf = ba:tvb("Decrypted")
+	end
+	pinfo.cols.protocol = "NOX"
+	-- side-specific tables are named after the side that receives the message
+	local to_client = pinfo.src_port == nox.prefs.port
+	local side_sizes, side_layouts = sizes_srv, layouts_srv
+	if to_client then
+		side_sizes, side_layouts = sizes_cli, layouts_cli
+	end
+	local t = tree:add(nox, buf())
+	t:add(hdr_sid, buf(0, 1))
+	t:add(hdr_reliable, buf(0, 1))
+	t:add(hdr_seq, buf(1, 1))
+	local names = {}
+	local off = 2
+	while off < n do
+		local op = buf(off, 1):uint()
+		local sz = pick(side_sizes, sizes, op)
+		if sz == nil or off + 1 + sz > n then
+			sz = n - off - 1
+		end
+		local name = ops[op] or ("Op(" .. op .. ")")
+		table.insert(names, name)
+		local mt = t:add(buf(off, 1 + sz), name)
+		mt:add(msg_op, buf(off, 1))
+		local l = pick(side_layouts, layouts, op)
+		if l ~= nil then
+			for _, f in ipairs(l) do
+				if f[2] + f[3] <= sz then
+					mt:add_le(f[1], buf(off + 1 + f[2], f[3]))
+				end
+			end
+		elseif sz > 0 then
+			mt:add(msg_data, buf(off + 1, sz))
+		end
+		off = off + 1 + sz
+	end
+	pinfo.cols.info = table.concat(names, ", ")
+	return n
+end
+
+local udp_port = DissectorTable.get("udp.port")
+local cur_port = nil
+
+function nox.init()
+	if cur_port ~= nil then
+		udp_port:remove(cur_port, nox)
+	end
+	cur_port = nox.prefs.port
+	udp_port:add(cur_port, nox)
+end
+`
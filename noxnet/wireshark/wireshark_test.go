@@ -0,0 +1,20 @@
+package wireshark
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/shoenig/test/must"
+)
+
+func TestGenerated(t *testing.T) {
+	var buf bytes.Buffer
+	err := Generate(&buf, nil)
+	must.NoError(t, err)
+	exp, err := os.ReadFile("nox.lua")
+	must.NoError(t, err)
+	if !bytes.Equal(exp, buf.Bytes()) {
+		t.Fatal("nox.lua is out of date, run go generate")
+	}
+}
@@ -0,0 +1,471 @@
+package noxnet
+
+import (
+	"image"
+	"slices"
+	"sync"
+
+	"github.com/opennox/libs/maps"
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+// WorldOptions configures the World.
+type WorldOptions struct {
+	// IsComplex checks if objects of a given type are complex. Complex objects carry additional state in updates.
+	// If not set, every type is treated as simple. This is wrong for real servers: updates of complex objects
+	// will be misparsed, so it must be set from the object type list used by the server.
+	IsComplex func(typ uint16) bool
+	// OnObject is called when an object is added or updated.
+	OnObject func(o WorldObject, added bool)
+	// OnObjectRemove is called when an object is destroyed or goes out of sight.
+	OnObjectRemove func(o WorldObject)
+	// OnWall is called when the wall state changes.
+	OnWall func(w WorldWall)
+	// OnMap is called when the server switches the map. All the state is reset at this point.
+	OnMap func(name string)
+}
+
+// WorldObject is the last known state of an object visible to the client.
+type WorldObject struct {
+	NetCode   NetCode
+	Type      uint16
+	Pos       image.Point
+	Complex   *ComplexObjectUpdate
+	InShadows bool
+	Disabled  bool
+	Friend    bool
+	// Updated is the frame of the last update.
+	Updated Timestamp
+}
+
+// WorldPlayer is a player announced by the server.
+type WorldPlayer struct {
+	NetCode NetCode
+	Name    string
+	Class   byte
+}
+
+// WorldWall is the state of the wall changed by the server.
+// Walls that were never changed are not tracked.
+type WorldWall struct {
+	Pos  maps.WallPos
+	Open bool
+	// Magic is set if the wall has a magic effect applied.
+	Magic *MsgWallMagic
+}
+
+// WorldSelf is the state of the client's own player.
+type WorldSelf struct {
+	NetCode   NetCode
+	Health    uint16
+	MaxHealth uint16
+	Mana      uint16
+	MaxMana   uint16
+}
+
+// WorldSnapshot is a copy of the World state at a given frame.
+type WorldSnapshot struct {
+	Frame Timestamp
+	Map   string
+	Self  WorldSelf
+	// Objects visible to the client, sorted by the net code.
+	Objects []WorldObject
+	// Players sorted by the net code.
+	Players []WorldPlayer
+	// Walls sorted by the position.
+	Walls []WorldWall
+	// DestroyedWalls is a list of destroyed wall IDs, in order of destruction.
+	DestroyedWalls []uint16
+}
+
+type worldAlias struct {
+	id       UpdateObjectID
+	deadline Timestamp
+}
+
+// NewWorld creates a new client-side world model.
+func NewWorld(opts *WorldOptions) *World {
+	if opts == nil {
+		opts = &WorldOptions{}
+	}
+	w := &World{opts: *opts}
+	if w.opts.IsComplex == nil {
+		w.opts.IsComplex = objectTypeIsComplex
+	}
+	w.dec.IsClient = true
+	w.reset()
+	return w
+}
+
+// World tracks objects, players and walls visible to the client.
+//
+// It consumes messages received from the server, in order, and keeps the last known state.
+// It decodes MsgUpdateStream itself, since aliases from MsgNewAlias are required to decode it correctly.
+// Other messages in the same packet can only be decoded after that, so HandleRest returns them to the caller.
+type World struct {
+	opts WorldOptions
+	dec  netmsg.State
+
+	mu        sync.RWMutex
+	mapName   string
+	self      WorldSelf
+	aliases   map[byte]worldAlias
+	objects   map[NetCode]*WorldObject
+	players   map[NetCode]*WorldPlayer
+	walls     map[maps.WallPos]*WorldWall
+	destroyed []uint16
+	notify    []func()
+}
+
+func (w *World) reset() {
+	w.dec = netmsg.State{Options: w.dec.Options}
+	w.mapName = ""
+	w.aliases = make(map[byte]worldAlias)
+	w.objects = make(map[NetCode]*WorldObject)
+	w.players = make(map[NetCode]*WorldPlayer)
+	w.walls = make(map[maps.WallPos]*WorldWall)
+	w.destroyed = nil
+}
+
+// Frame returns the last frame reported by the server.
+func (w *World) Frame() Timestamp {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return Timestamp(w.dec.Frame())
+}
+
+// Self returns the state of the client's own player.
+func (w *World) Self() WorldSelf {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.self
+}
+
+// Object returns the last known state of an object.
+func (w *World) Object(id NetCode) (WorldObject, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	o := w.objects[id]
+	if o == nil {
+		return WorldObject{}, false
+	}
+	return *o, true
+}
+
+// ObjectsIn returns objects inside a given rectangle, sorted by the net code.
+func (w *World) ObjectsIn(r image.Rectangle) []WorldObject {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var out []WorldObject
+	for _, o := range w.objects {
+		if o.Pos.In(r) {
+			out = append(out, *o)
+		}
+	}
+	slices.SortFunc(out, func(a, b WorldObject) int {
+		return int(a.NetCode) - int(b.NetCode)
+	})
+	return out
+}
+
+// Snapshot returns a copy of the current state.
+func (w *World) Snapshot() WorldSnapshot {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	s := WorldSnapshot{
+		Frame:          Timestamp(w.dec.Frame()),
+		Map:            w.mapName,
+		Self:           w.self,
+		Objects:        make([]WorldObject, 0, len(w.objects)),
+		Players:        make([]WorldPlayer, 0, len(w.players)),
+		Walls:          make([]WorldWall, 0, len(w.walls)),
+		DestroyedWalls: slices.Clone(w.destroyed),
+	}
+	for _, o := range w.objects {
+		s.Objects = append(s.Objects, *o)
+	}
+	slices.SortFunc(s.Objects, func(a, b WorldObject) int {
+		return int(a.NetCode) - int(b.NetCode)
+	})
+	for _, p := range w.players {
+		s.Players = append(s.Players, *p)
+	}
+	slices.SortFunc(s.Players, func(a, b WorldPlayer) int {
+		return int(a.NetCode) - int(b.NetCode)
+	})
+	for _, wl := range w.walls {
+		s.Walls = append(s.Walls, *wl)
+	}
+	slices.SortFunc(s.Walls, func(a, b WorldWall) int {
+		if a.Pos.Y != b.Pos.Y {
+			return int(a.Pos.Y) - int(b.Pos.Y)
+		}
+		return int(a.Pos.X) - int(b.Pos.X)
+	})
+	return s
+}
+
+// Handle updates the world state from a server message. It returns false if the message was ignored.
+//
+// Change callbacks are called before Handle returns, after the state is updated.
+// Messages that follow an undecoded MSG_UPDATE_STREAM are handled, but not returned. See HandleRest.
+func (w *World) Handle(m netmsg.Message) bool {
+	ok, _ := w.HandleRest(m)
+	return ok
+}
+
+// HandleRest is like Handle, but also returns messages that followed an undecoded MSG_UPDATE_STREAM in the packet.
+// They are already handled by the World, in order. The caller should process them as if they were received separately.
+func (w *World) HandleRest(m netmsg.Message) (bool, []netmsg.Message) {
+	w.mu.Lock()
+	ok, rest := w.handleRest(m)
+	notify := w.notify
+	w.notify = nil
+	w.mu.Unlock()
+	for _, fnc := range notify {
+		fnc()
+	}
+	return ok, rest
+}
+
+func (w *World) handleRest(m netmsg.Message) (bool, []netmsg.Message) {
+	switch m := m.(type) {
+	case *MsgSeqImportant:
+		if m.Msg == nil {
+			return false, nil
+		}
+		return w.handleRest(m.Msg)
+	case *netmsg.Unknown:
+		if m.Op != netmsg.MSG_UPDATE_STREAM {
+			return false, nil
+		}
+		return w.handleUpdateStream(m.Data)
+	}
+	return w.handle(m), nil
+}
+
+func (w *World) handle(m netmsg.Message) bool {
+	switch m := m.(type) {
+	case *MsgSeqImportant:
+		if m.Msg == nil {
+			return false
+		}
+		return w.handle(m.Msg)
+	case *MsgUpdateStream:
+		w.handleUpdate(m)
+		return true
+	case *MsgUseMap:
+		w.reset()
+		w.mapName = m.MapName.Value
+		m.UpdateFrame(&w.dec)
+		if fnc := w.opts.OnMap; fnc != nil {
+			name := w.mapName
+			w.notify = append(w.notify, func() { fnc(name) })
+		}
+		return true
+	case *MsgJoinData:
+		w.self.NetCode = m.NetCode
+		return true
+	case *MsgFullTimestamp:
+		m.UpdateFrame(&w.dec)
+		return true
+	case *MsgTimestamp:
+		m.UpdateFrame(&w.dec)
+		return true
+	case *MsgNewAlias:
+		w.aliases[m.Alias.Alias] = worldAlias{id: m.ID, deadline: m.Deadline}
+		return true
+	case *MsgSimpleObject:
+		w.updateObject(m.NetCode, m.Type, m.Pos, nil)
+		return true
+	case *MsgComplexObject:
+		c := m.Complex
+		w.updateObject(m.NetCode, m.Type, m.Pos, &c)
+		return true
+	case *MsgDestroyObject:
+		w.removeObject(m.NetCode)
+		return true
+	case *MsgObjectOutOfSight:
+		w.removeObject(m.NetCode)
+		return true
+	case *MsgObjectInShadows:
+		w.changeObject(m.NetCode, func(o *WorldObject) { o.InShadows = true })
+		return true
+	case *MsgEnableObject:
+		w.changeObject(m.NetCode, func(o *WorldObject) { o.Disabled = false })
+		return true
+	case *MsgDisableObject:
+		w.changeObject(m.NetCode, func(o *WorldObject) { o.Disabled = true })
+		return true
+	case *MsgObjectFriendAdd:
+		w.changeObject(m.NetCode, func(o *WorldObject) { o.Friend = true })
+		return true
+	case *MsgObjectFriendRemove:
+		w.changeObject(m.NetCode, func(o *WorldObject) { o.Friend = false })
+		return true
+	case *MsgResetFriends:
+		for id, o := range w.objects {
+			if o.Friend {
+				w.changeObject(id, func(o *WorldObject) { o.Friend = false })
+			}
+		}
+		return true
+	case *MsgForgetDrawables:
+		for id := range w.objects {
+			w.removeObject(id)
+		}
+		return true
+	case *MsgWallOpen:
+		w.changeWall(m.Pos, func(wl *WorldWall) { wl.Open = true })
+		return true
+	case *MsgWallClose:
+		w.changeWall(m.Pos, func(wl *WorldWall) { wl.Open = false })
+		return true
+	case *MsgWallMagic:
+		mg := *m
+		w.changeWall(m.Pos, func(wl *WorldWall) { wl.Magic = &mg })
+		return true
+	case *MsgWallMagicRemove:
+		w.changeWall(m.Pos, func(wl *WorldWall) { wl.Magic = nil })
+		return true
+	case *MsgWallDestroy:
+		w.destroyed = append(w.destroyed, m.ID)
+		return true
+	case *MsgNewPlayer:
+		w.players[m.NetCode] = &WorldPlayer{
+			NetCode: m.NetCode,
+			Name:    m.PlayerName,
+			Class:   m.PlayerClass,
+		}
+		return true
+	case *MsgReportHealth:
+		w.self.Health, w.self.MaxHealth = m.Health, m.Max
+		return true
+	case *MsgReportMana:
+		w.self.Mana, w.self.MaxMana = m.Mana, m.Max
+		return true
+	}
+	return false
+}
+
+// handleUpdateStream decodes MsgUpdateStream with the alias table, followed by any other messages in the packet.
+// It returns the messages that follow the update.
+func (w *World) handleUpdateStream(data []byte) (bool, []netmsg.Message) {
+	var u MsgUpdateStream
+	n, err := u.DecodeResolved(data, worldResolver{w})
+	if err != nil {
+		return false, nil
+	}
+	w.handleUpdate(&u)
+	var rest []netmsg.Message
+	for data = data[n:]; len(data) != 0; data = data[n:] {
+		var m netmsg.Message
+		m, n, err = w.dec.DecodeNext(data)
+		if err != nil {
+			break
+		}
+		_, more := w.handleRest(m)
+		rest = append(rest, m)
+		rest = append(rest, more...)
+	}
+	return true, rest
+}
+
+func (w *World) handleUpdate(u *MsgUpdateStream) {
+	if id, ok := w.resolve(u.ID); ok {
+		w.updateObject(id.ID, id.Type, u.Pos, nil)
+	}
+	for _, obj := range u.Objects {
+		id, ok := w.resolve(obj.ID)
+		if !ok {
+			continue // unknown alias
+		}
+		w.updateObject(id.ID, id.Type, obj.Pos, obj.Complex)
+	}
+}
+
+func (w *World) resolve(id UpdateID) (UpdateObjectID, bool) {
+	switch id := id.(type) {
+	case *UpdateObjectID:
+		return *id, true
+	case *UpdateAlias:
+		a, ok := w.aliases[id.Alias]
+		if !ok || (a.deadline != 0 && Timestamp(w.dec.Frame()) > a.deadline) {
+			return UpdateObjectID{}, false
+		}
+		return a.id, true
+	}
+	return UpdateObjectID{}, false
+}
+
+func (w *World) updateObject(id NetCode, typ uint16, pos image.Point, c *ComplexObjectUpdate) {
+	o := w.objects[id]
+	added := o == nil
+	if added {
+		o = &WorldObject{NetCode: id}
+		w.objects[id] = o
+	}
+	o.Type = typ
+	o.Pos = pos
+	if c != nil {
+		cc := *c
+		o.Complex = &cc
+	}
+	o.InShadows = false
+	o.Updated = Timestamp(w.dec.Frame())
+	if fnc := w.opts.OnObject; fnc != nil {
+		cur := *o
+		w.notify = append(w.notify, func() { fnc(cur, added) })
+	}
+}
+
+func (w *World) changeObject(id NetCode, fnc func(o *WorldObject)) {
+	o := w.objects[id]
+	if o == nil {
+		return
+	}
+	fnc(o)
+	if cb := w.opts.OnObject; cb != nil {
+		cur := *o
+		w.notify = append(w.notify, func() { cb(cur, false) })
+	}
+}
+
+func (w *World) removeObject(id NetCode) {
+	o := w.objects[id]
+	if o == nil {
+		return
+	}
+	delete(w.objects, id)
+	if fnc := w.opts.OnObjectRemove; fnc != nil {
+		cur := *o
+		w.notify = append(w.notify, func() { fnc(cur) })
+	}
+}
+
+func (w *World) changeWall(pos maps.WallPos, fnc func(wl *WorldWall)) {
+	wl := w.walls[pos]
+	if wl == nil {
+		wl = &WorldWall{Pos: pos}
+		w.walls[pos] = wl
+	}
+	fnc(wl)
+	if cb := w.opts.OnWall; cb != nil {
+		cur := *wl
+		w.notify = append(w.notify, func() { cb(cur) })
+	}
+}
+
+// worldResolver resolves aliases for MsgUpdateStream. World lock must be held.
+type worldResolver struct {
+	w *World
+}
+
+func (r worldResolver) AliasType(alias byte) (uint16, bool) {
+	id, ok := r.w.resolve(&UpdateAlias{Alias: alias})
+	return id.Type, ok
+}
+
+func (r worldResolver) IsComplex(typ uint16) bool {
+	return r.w.opts.IsComplex(typ)
+}
@@ -0,0 +1,105 @@
+package noxnet
+
+import (
+	"image"
+	"testing"
+
+	"github.com/shoenig/test/must"
+
+	"github.com/opennox/libs/binenc"
+	"github.com/opennox/libs/maps"
+	"github.com/opennox/libs/noxnet/netmsg"
+)
+
+func TestWorld(t *testing.T) {
+	var (
+		added   []NetCode
+		removed []NetCode
+		walls   []WorldWall
+	)
+	w := NewWorld(&WorldOptions{
+		IsComplex: func(typ uint16) bool { return typ == 0x20 },
+		OnObject: func(o WorldObject, add bool) {
+			if add {
+				added = append(added, o.NetCode)
+			}
+		},
+		OnObjectRemove: func(o WorldObject) {
+			removed = append(removed, o.NetCode)
+		},
+		OnWall: func(wl WorldWall) {
+			walls = append(walls, wl)
+		},
+	})
+	for _, m := range []netmsg.Message{
+		&MsgUseMap{MapName: binenc.String{Value: "estate"}, T: 100},
+		&MsgJoinData{NetCode: 1},
+		&MsgNewPlayer{NetCode: 1, PlayerInfo: PlayerInfo{PlayerName: "Jack", PlayerClass: 2}},
+		&MsgNewAlias{Alias: UpdateAlias{5}, ID: UpdateObjectID{ID: 1, Type: 0x1}},
+		&MsgNewAlias{Alias: UpdateAlias{7}, ID: UpdateObjectID{ID: 2, Type: 0x30}},
+		&MsgSeqImportant{ID: 1, Msg: &MsgNewAlias{Alias: UpdateAlias{8}, ID: UpdateObjectID{ID: 3, Type: 0x20}}},
+		&MsgFullTimestamp{T: 105},
+	} {
+		must.True(t, w.Handle(m), must.Sprintf("%T", m))
+	}
+
+	packet := []byte{
+		byte(netmsg.MSG_UPDATE_STREAM),
+		0x05,                   // observer alias
+		100, 0, 200, 0, 0x0, 0, // pos, flags, unk5
+		0x00, 0xff, 0x10, 0x00, 0x20, 0x00, 0x2c, 0x01, 0x90, 0x01, 1, 2, 3, // full ID, absolute pos, complex
+		0x07, 10, 20, // simple alias, relative pos
+		0x08, 1, 2, 4, 5, 6, // complex alias, relative pos
+		0, 0, 0,
+	}
+	packet, err := netmsg.Append(packet, &MsgObjectInShadows{ObjectCode{NetCode: 2}})
+	must.NoError(t, err)
+	var st netmsg.State
+	st.IsClient = true
+	m, n, err := st.DecodeNext(packet)
+	must.NoError(t, err)
+	must.EqOp(t, len(packet), n)
+	ok, rest := w.HandleRest(m)
+	must.True(t, ok)
+	must.Eq(t, []netmsg.Message{&MsgObjectInShadows{ObjectCode{NetCode: 2}}}, rest)
+
+	must.Eq(t, []NetCode{1, 0x10, 2, 3}, added)
+	s := w.Snapshot()
+	must.EqOp(t, "estate", s.Map)
+	must.EqOp(t, Timestamp(105), s.Frame)
+	must.EqOp(t, NetCode(1), s.Self.NetCode)
+	must.Eq(t, []WorldPlayer{{NetCode: 1, Name: "Jack", Class: 2}}, s.Players)
+	must.Eq(t, []WorldObject{
+		{NetCode: 1, Type: 0x1, Pos: image.Pt(100, 200), Updated: 105},
+		{NetCode: 2, Type: 0x30, Pos: image.Pt(110, 220), InShadows: true, Updated: 105},
+		{NetCode: 3, Type: 0x20, Pos: image.Pt(101, 202), Complex: &ComplexObjectUpdate{4, 5, 6}, Updated: 105},
+		{NetCode: 0x10, Type: 0x20, Pos: image.Pt(300, 400), Complex: &ComplexObjectUpdate{1, 2, 3}, Updated: 105},
+	}, s.Objects)
+	must.Len(t, 2, w.ObjectsIn(image.Rect(100, 200, 105, 210)))
+
+	for _, m := range []netmsg.Message{
+		&MsgObjectOutOfSight{ObjectCode{NetCode: 2}},
+		&MsgDestroyObject{ObjectCode{NetCode: 3}},
+		&MsgWallOpen{WallRef{Pos: maps.WallPos{X: 3, Y: 4}}},
+		&MsgWallMagic{Pos: maps.WallPos{X: 1, Y: 2}, Material: 1},
+		&MsgWallDestroy{ID: 7},
+	} {
+		must.True(t, w.Handle(m), must.Sprintf("%T", m))
+	}
+	must.Eq(t, []NetCode{2, 3}, removed)
+	must.Len(t, 2, walls)
+	s = w.Snapshot()
+	must.Len(t, 2, s.Objects)
+	must.Eq(t, []WorldWall{
+		{Pos: maps.WallPos{X: 1, Y: 2}, Magic: &MsgWallMagic{Pos: maps.WallPos{X: 1, Y: 2}, Material: 1}},
+		{Pos: maps.WallPos{X: 3, Y: 4}, Open: true},
+	}, s.Walls)
+	must.Eq(t, []uint16{7}, s.DestroyedWalls)
+
+	// new map resets the state
+	must.True(t, w.Handle(&MsgUseMap{MapName: binenc.String{Value: "bunker"}, T: 200}))
+	s = w.Snapshot()
+	must.EqOp(t, "bunker", s.Map)
+	must.SliceEmpty(t, s.Objects)
+	must.SliceEmpty(t, s.Walls)
+}